@@ -1,72 +1,146 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/middleware/cors"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
 
 	"photo-go/config"
 	"photo-go/internal/api"
-	"photo-go/internal/core"
+	v1 "photo-go/internal/api/v1"
+	"photo-go/internal/app"
 	"photo-go/internal/database"
 	"photo-go/pkg/logger"
-	"photo-go/pkg/utils"
 )
 
+const migrateUsage = "usage: main migrate up | down [steps] | status"
+
 func main() {
+	// Subcommand: main migrate up|down|status, chạy migration versioned rồi thoát
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
 	logger.Info("Starting application")
 	// Load config
 	cfg := config.Settings
 
 	// Init GORM
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPass, cfg.DBName)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := app.OpenDB()
 	if err != nil {
 		logger.Fatal(err, "Failed to connect to DB")
 	}
-	logger.Info("Connected to DB")
-	if err := database.AutoMigrate(db); err != nil {
+	if err := app.MigrateDB(context.Background(), db); err != nil {
 		logger.Fatal(err, "DB migration failed")
 	}
 	logger.Info("DB migration success")
 
-	// Init MinIO
-	minioClient, err := utils.NewMinioClient(
-		cfg.MinioEndpoint,
-		cfg.MinioAccessKey,
-		cfg.MinioSecretKey,
-		cfg.MinioBucket,
-		false, // useSSL
-	)
+	// Init storage, core, workspace, job queue
+	deps, err := app.NewDependencies(db)
 	if err != nil {
-		logger.Fatal(err, "Failed to init Minio client")
+		logger.Fatal(err, "Failed to init dependencies")
+	}
+	mediaService := api.NewMediaService(deps)
+	api.RegisterJobHandlers(deps.Queue, mediaService)
+	// Memory queue luôn chạy worker trong process; Postgres queue có thể tách sang cmd/worker
+	if cfg.JobBackend == "memory" || !cfg.DisableEmbeddedWorker {
+		deps.Queue.Start(context.Background())
+		defer deps.Queue.Stop()
+		logger.Info("Embedded workers started")
 	}
-	logger.Info("Minio client initialized")
 
-	// Init core
-	videoCore := core.NewFFMPEGVideoProcessor()
-	imageCore := core.NewDefaultImageProcessor()
-	logger.Info("Core processors initialized")
+	// Upload tus dở quá UPLOAD_EXPIRY bị dọn định kỳ
+	uploadService := api.NewUploadService(deps, mediaService)
+	go uploadService.RunCleanup(context.Background(), time.Hour)
+	// Media trong thùng rác quá TRASH_RETENTION được enqueue job purge
+	go mediaService.RunTrashPurge(context.Background(), time.Hour)
 
-	// Init Fiber
-	app := fiber.New()
+	// Init Fiber, body được stream để chunk upload lớn không phải nằm trọn trong bộ nhớ
+	app := fiber.New(fiber.Config{StreamRequestBody: true})
 	logger.Info("Fiber app initialized")
 
 	// CORS middleware với cấu hình từ config (truyền slice trực tiếp)
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: []string{"*"},
-		AllowMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders: []string{"Origin", "Content-Type", "Accept", "Authorization", "Cache-Control"},
+		AllowOrigins:  []string{"*"},
+		AllowMethods:  []string{"GET", "POST", "PUT", "PATCH", "HEAD", "DELETE", "OPTIONS"},
+		AllowHeaders:  append([]string{"Origin", "Content-Type", "Accept", "Authorization", "Cache-Control"}, v1.TusHeaders...),
+		ExposeHeaders: v1.TusHeaders,
 	}))
 
 	// Register API v1 routes (truyền các thành phần cần thiết, khởi tạo service/repo bên trong route v1)
-	api.RegisterV1Routes(app, db, videoCore, imageCore, minioClient)
+	api.RegisterV1Routes(app, deps, mediaService, uploadService)
 	logger.Info("API routes registered")
 
 	// Start server
 	logger.Info("Starting server on port %s", cfg.Port)
 	app.Listen(cfg.Port)
 }
+
+// runMigrate áp (up), rollback (down, mặc định 1 bước) hoặc liệt kê (status) migration versioned
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+	db, err := app.OpenDB()
+	if err != nil {
+		logger.Fatal(err, "Failed to connect to DB")
+	}
+	m, err := database.NewMigrator(db)
+	if err != nil {
+		logger.Fatal(err, "Load migrations failed")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	switch args[0] {
+	case "up":
+		n, err := m.Up(ctx)
+		if err != nil {
+			logger.Fatal(err, "Migrate up failed")
+		}
+		logger.Info("Applied %d migrations", n)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				os.Exit(2)
+			}
+		}
+		n, err := m.Down(ctx, steps)
+		if err != nil {
+			logger.Fatal(err, "Migrate down failed")
+		}
+		logger.Info("Rolled back %d migrations", n)
+	case "status":
+		status, err := m.Status(ctx)
+		if err != nil {
+			logger.Fatal(err, "Migrate status failed")
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt > 0 {
+				applied = time.Unix(s.AppliedAt, 0).UTC().Format(time.RFC3339)
+			}
+			if s.Modified {
+				applied += " (modified)"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		w.Flush()
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"os/signal"
+	"syscall"
+
+	"photo-go/config"
+	"photo-go/internal/api"
+	"photo-go/internal/app"
+	"photo-go/pkg/logger"
+)
+
+// Worker độc lập: chỉ chạy job queue Postgres, không mở HTTP server
+func main() {
+	logger.Info("Starting worker")
+	cfg := config.Settings
+	if cfg.JobBackend != "postgres" {
+		logger.Fatalf("cmd/worker requires JOB_BACKEND=postgres, got %q", cfg.JobBackend)
+	}
+
+	db, err := app.OpenDB()
+	if err != nil {
+		logger.Fatal(err, "Failed to connect to DB")
+	}
+	if err := app.MigrateDB(context.Background(), db); err != nil {
+		logger.Fatal(err, "DB migration failed")
+	}
+
+	deps, err := app.NewDependencies(db)
+	if err != nil {
+		logger.Fatal(err, "Failed to init dependencies")
+	}
+	mediaService := api.NewMediaService(deps)
+	api.RegisterJobHandlers(deps.Queue, mediaService)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	deps.Queue.Start(ctx)
+	logger.Info("Worker started with %d workers", cfg.WorkerConcurrency)
+
+	<-ctx.Done()
+	logger.Info("Shutting down worker")
+	deps.Queue.Stop()
+	logger.Info("Worker stopped")
+}
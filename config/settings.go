@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 )
 
 type LogLevel string
@@ -19,6 +20,27 @@ const (
 	FATAL LogLevel = "FATAL"
 )
 
+// QualityRung là một bậc trong quality ladder HLS (bitrate tính bằng kbps)
+type QualityRung struct {
+	Name         string `json:"NAME"`
+	Width        int    `json:"WIDTH" description:"0 = keep source aspect ratio"`
+	Height       int    `json:"HEIGHT"`
+	VideoBitrate int    `json:"VIDEO_BITRATE"`
+	MaxRate      int    `json:"MAX_RATE" description:"default: 107% of VIDEO_BITRATE"`
+	BufSize      int    `json:"BUF_SIZE" description:"default: 150% of MAX_RATE"`
+	AudioBitrate int    `json:"AUDIO_BITRATE" default:"128"`
+	Profile      string `json:"PROFILE" default:"main"`
+	Level        string `json:"LEVEL"`
+}
+
+// ImageVariant là một kích thước derivative sinh sẵn khi upload ảnh
+type ImageVariant struct {
+	Name    string `json:"NAME"`
+	Width   int    `json:"WIDTH" description:"bounding box, 0 = unbounded"`
+	Height  int    `json:"HEIGHT" description:"bounding box, 0 = unbounded"`
+	Quality int    `json:"QUALITY" default:"85" description:"JPEG only"`
+}
+
 type _Setting struct {
 	CORSAllowOrigins []string `json:"CORS_ALLOW_ORIGINS"`
 	CORSAllowHeaders []string `json:"CORS_ALLOW_HEADERS"`
@@ -35,13 +57,71 @@ type _Setting struct {
 	DBUser          string `json:"DB_USER"`
 	DBPass          string `json:"DB_PASS"`
 	DBName          string `json:"DB_NAME"`
+	DBMigrate       string `json:"DB_MIGRATE" default:"versioned" description:"versioned | auto | none: schema update at startup, auto = gorm AutoMigrate (dev only), none = run 'migrate up' separately"`
 	MinioEndpoint   string `json:"MINIO_ENDPOINT"`
 	MinioAccessKey  string `json:"MINIO_ACCESS_KEY"`
 	MinioSecretKey  string `json:"MINIO_SECRET_KEY"`
 	MinioBucket     string `json:"MINIO_BUCKET"`
+	MinioRegion     string `json:"MINIO_REGION" description:"region used when the bucket is created at startup"`
+
+	MinioVersioning           bool `json:"MINIO_VERSIONING" default:"false" description:"enable object versioning on the bucket at startup"`
+	MinioTempExpiryDays       int  `json:"MINIO_TEMP_EXPIRY_DAYS" default:"0" description:"lifecycle rule expiring temporary upload objects after N days, 0 = leave bucket lifecycle unchanged"`
+	MinioNoncurrentExpiryDays int  `json:"MINIO_NONCURRENT_EXPIRY_DAYS" default:"0" description:"lifecycle rule removing noncurrent versions after N days (with versioning), 0 = disabled"`
+
+	StorageBackend  string `json:"STORAGE_BACKEND" default:"minio" description:"minio | local | memory: where media objects are stored"`
+	StorageLocalDir string `json:"STORAGE_LOCAL_DIR" default:"data/storage" description:"local backend only"`
+
+	UploadConcurrency int `json:"UPLOAD_CONCURRENCY" default:"8"`
+
+	UploadStore    string `json:"UPLOAD_STORE" default:"storage" description:"storage | local: where resumable upload chunks are kept until complete, storage = STORAGE_BACKEND"`
+	UploadLocalDir string `json:"UPLOAD_LOCAL_DIR" description:"local store only, default: <os temp dir>/photo-go-uploads"`
+	UploadMaxSize  int64  `json:"UPLOAD_MAX_SIZE" default:"10737418240" description:"10 GiB"`
+	UploadExpiry   int    `json:"UPLOAD_EXPIRY" default:"86400" description:"24 hours of inactivity before an unfinished upload is removed"`
+
+	PresignUploadExpiry int   `json:"PRESIGN_UPLOAD_EXPIRY" default:"3600" description:"1 hour to PUT and complete a presigned upload"`
+	PresignPartSize     int64 `json:"PRESIGN_PART_SIZE" default:"67108864" description:"64 MiB, larger files get multipart part URLs"`
+
+	TrashRetention int `json:"TRASH_RETENTION" default:"2592000" description:"30 days before deleted media and its objects are purged, restorable until then"`
+
+	QualityLadder    []QualityRung `json:"QUALITY_LADDER" description:"empty = built-in ladder 360p..1080p"`
+	TranscodeMode    string        `json:"TRANSCODE_MODE" default:"single_pass" description:"single_pass | per_rendition"`
+	MaxVideoDuration int           `json:"MAX_VIDEO_DURATION" default:"0" description:"seconds, 0 = unlimited"`
+
+	PreviewInterval      float64 `json:"PREVIEW_INTERVAL" default:"5" description:"seconds between frames of the seek bar sprite"`
+	PreviewMaxFrames     int     `json:"PREVIEW_MAX_FRAMES" default:"300" description:"interval is stretched for long videos to stay under this"`
+	PreviewWidth         int     `json:"PREVIEW_WIDTH" default:"160" description:"width of one sprite tile"`
+	PreviewSpriteColumns int     `json:"PREVIEW_SPRITE_COLUMNS" default:"10"`
+	PreviewSpriteRows    int     `json:"PREVIEW_SPRITE_ROWS" default:"10"`
+
+	ImageVariants  []ImageVariant `json:"IMAGE_VARIANTS" description:"empty = thumb 320, small 640, medium 1280, large 2048"`
+	MaxImagePixels int64          `json:"MAX_IMAGE_PIXELS" default:"100000000" description:"reject larger images (decompression bomb guard)"`
+
+	ImageMetadataPolicy string `json:"IMAGE_METADATA_POLICY" default:"strip_gps" description:"strip_gps | strip_all | keep_all, applies to served originals and derivatives"`
 
-	DefaultPageSize   int `json:"DEFAULT_PAGE_SIZE"`
-	DefaultPageNumber int `json:"DEFAULT_PAGE_NUMBER"`
+	ImageTransformMaxSize    int      `json:"IMAGE_TRANSFORM_MAX_SIZE" default:"4096" description:"max w/h for on-the-fly transforms"`
+	ImageTransformFormats    []string `json:"IMAGE_TRANSFORM_FORMATS" description:"default: jpeg, png, webp"`
+	ImageTransformSigningKey string   `json:"IMAGE_TRANSFORM_SIGNING_KEY" description:"non-empty = transform URLs must carry a valid HMAC-SHA256 sig"`
+
+	HLSDelivery      string `json:"HLS_DELIVERY" default:"proxy" description:"proxy | presign: segment URLs in playlists point to the API or to presigned MinIO URLs"`
+	HLSPresignExpiry int    `json:"HLS_PRESIGN_EXPIRY" default:"14400" description:"4 hours"`
+
+	JobBackend            string `json:"JOB_BACKEND" default:"postgres" description:"postgres | memory"`
+	DisableEmbeddedWorker bool   `json:"DISABLE_EMBEDDED_WORKER" description:"run workers only in cmd/worker"`
+	WorkerConcurrency     int    `json:"WORKER_CONCURRENCY" default:"2"`
+	JobQueueSize          int    `json:"JOB_QUEUE_SIZE" default:"100" description:"memory backend only"`
+	JobMaxAttempts        int    `json:"JOB_MAX_ATTEMPTS" default:"5"`
+	JobBackoffBase        int    `json:"JOB_BACKOFF_BASE" default:"10" description:"10 seconds"`
+	JobBackoffMax         int    `json:"JOB_BACKOFF_MAX" default:"3600" description:"1 hour"`
+	JobVisibilityTimeout  int    `json:"JOB_VISIBILITY_TIMEOUT" default:"300" description:"5 minutes"`
+	JobPollInterval       int    `json:"JOB_POLL_INTERVAL" default:"1" description:"1 second"`
+
+	WorkspaceRoot         string `json:"WORKSPACE_ROOT" description:"default: <os temp dir>/photo-go"`
+	WorkspaceMaxBytes     int64  `json:"WORKSPACE_MAX_BYTES" default:"0" description:"0 = unlimited"`
+	WorkspaceMinFreeBytes int64  `json:"WORKSPACE_MIN_FREE_BYTES" default:"1073741824"`
+
+	DefaultPageSize   int `json:"DEFAULT_PAGE_SIZE" default:"20"`
+	DefaultPageNumber int `json:"DEFAULT_PAGE_NUMBER" default:"1"`
+	MaxPageSize       int `json:"MAX_PAGE_SIZE" default:"100"`
 
 	LogLevel LogLevel `json:"LOG_LEVEL"`
 }
@@ -94,6 +174,122 @@ func init() {
 	if Settings.LogLevel == "" {
 		Settings.LogLevel = INFO
 	}
+	if Settings.UploadConcurrency <= 0 {
+		Settings.UploadConcurrency = 8
+	}
+	if Settings.StorageBackend == "" {
+		Settings.StorageBackend = "minio"
+	}
+	if Settings.StorageLocalDir == "" {
+		Settings.StorageLocalDir = filepath.Join("data", "storage")
+	}
+	if Settings.UploadStore == "" {
+		Settings.UploadStore = "storage"
+	}
+	if Settings.UploadLocalDir == "" {
+		Settings.UploadLocalDir = filepath.Join(os.TempDir(), "photo-go-uploads")
+	}
+	if Settings.UploadMaxSize <= 0 {
+		Settings.UploadMaxSize = 10 << 30
+	}
+	if Settings.UploadExpiry <= 0 {
+		Settings.UploadExpiry = 86400
+	}
+	if Settings.TrashRetention <= 0 {
+		Settings.TrashRetention = 2592000
+	}
+	if Settings.PresignUploadExpiry <= 0 {
+		Settings.PresignUploadExpiry = 3600
+	}
+	if Settings.PresignPartSize <= 0 {
+		Settings.PresignPartSize = 64 << 20
+	}
+	if Settings.DBMigrate == "" {
+		Settings.DBMigrate = "versioned"
+	}
+	if Settings.JobBackend == "" {
+		Settings.JobBackend = "postgres"
+	}
+	if Settings.WorkerConcurrency <= 0 {
+		Settings.WorkerConcurrency = 2
+	}
+	if Settings.JobQueueSize <= 0 {
+		Settings.JobQueueSize = 100
+	}
+	if Settings.JobMaxAttempts <= 0 {
+		Settings.JobMaxAttempts = 5
+	}
+	if Settings.JobBackoffBase <= 0 {
+		Settings.JobBackoffBase = 10
+	}
+	if Settings.JobBackoffMax <= 0 {
+		Settings.JobBackoffMax = 3600
+	}
+	if Settings.JobVisibilityTimeout <= 0 {
+		Settings.JobVisibilityTimeout = 300
+	}
+	if Settings.JobPollInterval <= 0 {
+		Settings.JobPollInterval = 1
+	}
+	if Settings.TranscodeMode == "" {
+		Settings.TranscodeMode = "single_pass"
+	}
+	if Settings.PreviewInterval <= 0 {
+		Settings.PreviewInterval = 5
+	}
+	if Settings.PreviewMaxFrames <= 0 {
+		Settings.PreviewMaxFrames = 300
+	}
+	if Settings.PreviewWidth <= 0 {
+		Settings.PreviewWidth = 160
+	}
+	if Settings.PreviewSpriteColumns <= 0 {
+		Settings.PreviewSpriteColumns = 10
+	}
+	if Settings.PreviewSpriteRows <= 0 {
+		Settings.PreviewSpriteRows = 10
+	}
+	if len(Settings.ImageVariants) == 0 {
+		Settings.ImageVariants = []ImageVariant{
+			{Name: "thumb", Width: 320, Height: 320},
+			{Name: "small", Width: 640, Height: 640},
+			{Name: "medium", Width: 1280, Height: 1280},
+			{Name: "large", Width: 2048, Height: 2048},
+		}
+	}
+	if Settings.MaxImagePixels <= 0 {
+		Settings.MaxImagePixels = 100_000_000
+	}
+	if Settings.ImageMetadataPolicy == "" {
+		Settings.ImageMetadataPolicy = "strip_gps"
+	}
+	if Settings.ImageTransformMaxSize <= 0 {
+		Settings.ImageTransformMaxSize = 4096
+	}
+	if len(Settings.ImageTransformFormats) == 0 {
+		Settings.ImageTransformFormats = []string{"jpeg", "png", "webp"}
+	}
+	if Settings.HLSDelivery == "" {
+		Settings.HLSDelivery = "proxy"
+	}
+	if Settings.HLSPresignExpiry <= 0 {
+		Settings.HLSPresignExpiry = 14400
+	}
+	if Settings.DefaultPageSize <= 0 {
+		Settings.DefaultPageSize = 20
+	}
+	if Settings.DefaultPageNumber <= 0 {
+		Settings.DefaultPageNumber = 1
+	}
+	if Settings.MaxPageSize <= 0 {
+		Settings.MaxPageSize = 100
+	}
+	if Settings.WorkspaceRoot == "" {
+		Settings.WorkspaceRoot = filepath.Join(os.TempDir(), "photo-go")
+	}
+	if Settings.WorkspaceMinFreeBytes == 0 {
+		Settings.WorkspaceMinFreeBytes = 1 << 30
+	}
 
 	fmt.Println("================================================")
 	fmt.Println("   Finished Settings")
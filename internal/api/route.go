@@ -3,17 +3,53 @@ package api
 import (
 	v1 "photo-go/internal/api/v1"
 	"photo-go/internal/core"
-	"photo-go/pkg/utils"
+	"photo-go/internal/events"
+	"photo-go/internal/jobs"
+	"photo-go/internal/workspace"
+	"photo-go/pkg/storage"
 
 	"github.com/gofiber/fiber/v3"
 	"gorm.io/gorm"
 )
 
+// Dependencies gom các thành phần dùng chung được khởi tạo ở main
+type Dependencies struct {
+	DB         *gorm.DB
+	VideoCore  core.VideoProcessor
+	ImageCore  core.ImageProcessor
+	Prober     core.Prober
+	Storage    storage.Storage
+	Workspaces *workspace.Manager
+	Queue      jobs.Queue
+	Events     events.Broker
+	Chunks     v1.ChunkStore
+}
+
+// NewMediaService khởi tạo service/repo media từ dependencies, dùng chung cho API và worker
+func NewMediaService(d Dependencies) *v1.MediaService {
+	repo := v1.NewGormMediaRepository(d.DB)
+	return v1.NewMediaService(d.VideoCore, d.ImageCore, d.Prober, repo, d.Storage, d.Workspaces, d.Queue, d.Events)
+}
+
+// NewUploadService khởi tạo service upload resumable (tus) và presigned upload, file hoàn chỉnh được chuyển cho mediaService
+func NewUploadService(d Dependencies, mediaService *v1.MediaService) *v1.UploadService {
+	return v1.NewUploadService(v1.NewGormUploadRepository(d.DB), v1.NewGormPresignRepository(d.DB), d.Chunks, mediaService, d.Workspaces)
+}
+
+// RegisterJobHandlers đăng ký handler cho từng loại job vào queue
+func RegisterJobHandlers(q jobs.Queue, mediaService *v1.MediaService) {
+	q.Handle(jobs.TypeProcessMedia, mediaService.ProcessMediaJob)
+	q.HandleFailure(jobs.TypeProcessMedia, mediaService.ProcessMediaFailed)
+	q.Handle(jobs.TypePurgeMedia, mediaService.PurgeMediaJob)
+}
+
 // Đăng ký tất cả route version 1 vào app
-func RegisterV1Routes(app *fiber.App, db *gorm.DB, videoCore core.VideoProcessor, imageCore core.ImageProcessor, minioClient *utils.MinioClient) {
-	repo := v1.NewGormMediaRepository(db)
-	mediaService := v1.NewMediaService(videoCore, imageCore, repo, minioClient)
-	handler := v1.NewMediaHandler(mediaService)
+func RegisterV1Routes(app *fiber.App, d Dependencies, mediaService *v1.MediaService, uploadService *v1.UploadService) {
+	handler := v1.NewMediaHandler(mediaService, d.Workspaces)
+	jobHandler := v1.NewJobHandler(d.Queue)
+	uploadHandler := v1.NewUploadHandler(uploadService)
 	v1Group := app.Group("/v1")
 	handler.RegisterRoutes(v1Group)
+	jobHandler.RegisterRoutes(v1Group)
+	uploadHandler.RegisterRoutes(v1Group)
 }
@@ -0,0 +1,75 @@
+package v1
+
+import (
+	"errors"
+	"photo-go/internal/jobs"
+	"photo-go/pkg/logger"
+	"photo-go/pkg/types"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type JobHandler struct {
+	Queue jobs.Queue
+}
+
+func NewJobHandler(q jobs.Queue) *JobHandler {
+	return &JobHandler{Queue: q}
+}
+
+func (h *JobHandler) RegisterRoutes(r fiber.Router) {
+	r.Get("/jobs/:id", h.Get)
+	r.Delete("/jobs/:id", h.Cancel)
+}
+
+// Get trả về trạng thái, stage và phần trăm tiến độ của job
+func (h *JobHandler) Get(c fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).SendString("Invalid job id")
+	}
+	job, err := h.Queue.Get(c, uint(id))
+	if err != nil {
+		if errors.Is(err, jobs.ErrNotFound) {
+			return c.Status(404).SendString("Job not found")
+		}
+		logger.Error(err, "Get job failed: %d", id)
+		return c.Status(500).SendString(err.Error())
+	}
+	return c.JSON(toJobDTO(job))
+}
+
+// Cancel hủy job: job đang chờ bị hủy ngay, job đang chạy bị kill ffmpeg qua context
+func (h *JobHandler) Cancel(c fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).SendString("Invalid job id")
+	}
+	job, err := h.Queue.Cancel(c, uint(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, jobs.ErrNotFound):
+			return c.Status(404).SendString("Job not found")
+		case errors.Is(err, jobs.ErrAlreadyFinished):
+			return c.Status(409).SendString("Job already finished")
+		}
+		logger.Error(err, "Cancel job failed: %d", id)
+		return c.Status(500).SendString(err.Error())
+	}
+	logger.Info("Cancel requested for job %d", id)
+	return c.Status(fiber.StatusAccepted).JSON(toJobDTO(job))
+}
+
+func toJobDTO(job *jobs.Job) types.JobDTO {
+	return types.JobDTO{
+		ID:       job.ID,
+		Type:     string(job.Type),
+		MediaID:  job.MediaID,
+		State:    string(job.State),
+		Stage:    job.Stage,
+		Progress: job.Progress,
+		Attempts: job.Attempts,
+		Error:    job.Error,
+	}
+}
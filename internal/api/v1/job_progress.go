@@ -0,0 +1,70 @@
+package v1
+
+import (
+	"context"
+	"photo-go/internal/events"
+	"photo-go/internal/jobs"
+	"photo-go/pkg/logger"
+	"sync"
+	"time"
+)
+
+// Các stage của job xử lý media, hiển thị qua API job status
+const (
+	stageDownloading = "downloading"
+	stageProbing     = "probing"
+	stageTranscoding = "transcoding"
+	stagePreviews    = "generating previews"
+	stageUploading   = "uploading"
+	stageRetrying    = "retrying"
+	stageDone        = "done"
+)
+
+// progressInterval giới hạn tần suất ghi tiến độ xuống queue khi stage không đổi
+const progressInterval = time.Second
+
+// jobProgress ghi tiến độ job xuống queue và phát event cho SSE,
+// bỏ bớt các update dày đặc từ ffmpeg/upload
+type jobProgress struct {
+	queue   jobs.Queue
+	events  events.Broker
+	jobID   uint
+	mediaID uint
+
+	mu        sync.Mutex
+	stage     string
+	updatedAt time.Time
+}
+
+func newJobProgress(queue jobs.Queue, broker events.Broker, job *jobs.Job) *jobProgress {
+	return &jobProgress{queue: queue, events: broker, jobID: job.ID, mediaID: job.MediaID}
+}
+
+// report lưu stage và phần trăm tổng của job. Lỗi khi lưu chỉ được log.
+func (p *jobProgress) report(ctx context.Context, stage string, percent float64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	if stage == p.stage && time.Since(p.updatedAt) < progressInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.stage, p.updatedAt = stage, time.Now()
+	p.mu.Unlock()
+
+	if percent > 100 {
+		percent = 100
+	}
+	if p.queue != nil {
+		if err := p.queue.UpdateProgress(ctx, p.jobID, stage, percent); err != nil && ctx.Err() == nil {
+			logger.Error(err, "Update progress failed for job %d", p.jobID)
+		}
+	}
+	if p.events != nil {
+		e := events.Event{Type: events.TypeProgress, MediaID: p.mediaID, JobID: p.jobID, Stage: stage, Progress: percent}
+		if err := p.events.Publish(ctx, e); err != nil {
+			logger.Error(err, "Publish progress failed for media %d", p.mediaID)
+		}
+	}
+}
@@ -1,25 +1,58 @@
 package v1
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"photo-go/internal/core"
+	"photo-go/internal/database"
+	"photo-go/internal/events"
+	"photo-go/internal/jobs"
+	"photo-go/internal/workspace"
 	"photo-go/pkg/logger"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
 )
 
+// Cache-Control cho HLS: segment không bao giờ đổi nội dung, playlist cache ngắn
+// vì có thể chứa presigned URL hết hạn
+const (
+	immutableCacheControl = "public, max-age=31536000, immutable"
+	playlistCacheControl  = "public, max-age=60"
+)
+
+// sseKeepAlive là chu kỳ gửi comment giữ kết nối SSE qua proxy và phát hiện client đã ngắt
+const sseKeepAlive = 15 * time.Second
+
 type MediaHandler struct {
-	Service *MediaService
+	Service    *MediaService
+	Workspaces *workspace.Manager
 }
 
-func NewMediaHandler(s *MediaService) *MediaHandler {
-	return &MediaHandler{Service: s}
+func NewMediaHandler(s *MediaService, w *workspace.Manager) *MediaHandler {
+	return &MediaHandler{Service: s, Workspaces: w}
 }
 
 func (h *MediaHandler) RegisterRoutes(r fiber.Router) {
 	r.Post("/media/upload", h.Upload)
+	r.Get("/media", h.List)
 	r.Get("/media/:id", h.Get)
-	r.Get("/media/stream/:id", h.StreamHLS)
+	r.Delete("/media/:id", h.Delete)
+	r.Post("/media/:id/restore", h.Restore)
+	r.Get("/media/:id/events", h.Events)
+	r.Get("/media/:id/image", h.Image)
+	r.Get("/media/stream/:id/*", h.StreamHLS)
 }
 
+// Upload nhận diện loại file: video được lưu và tạo job xử lý nền (202),
+// ảnh được xử lý ngay và trả về MediaDTO (201)
 func (h *MediaHandler) Upload(c fiber.Ctx) error {
 	logger.Info("Received upload request")
 	file, err := c.FormFile("file")
@@ -27,29 +60,304 @@ func (h *MediaHandler) Upload(c fiber.Ctx) error {
 		logger.Warn("Missing file in upload request")
 		return c.Status(400).SendString("Missing file")
 	}
-	filePath := "/tmp/" + file.Filename
+	ws, err := h.Workspaces.Acquire(c, "upload", file.Size)
+	if err != nil {
+		logger.Error(err, "Acquire workspace failed: %s", file.Filename)
+		if errors.Is(err, workspace.ErrInsufficientSpace) {
+			return c.Status(fiber.StatusInsufficientStorage).SendString("Insufficient storage")
+		}
+		return c.Status(500).SendString("Workspace error")
+	}
+	defer ws.Release()
+	filePath := ws.Path(file.Filename)
 	if err := c.SaveFile(file, filePath); err != nil {
 		logger.Error(err, "Save file error: %s", filePath)
 		return c.Status(500).SendString("Save file error")
 	}
-	qualities := []string{"360p", "480p", "720p"}
-	logger.Info("Processing video: %s", filePath)
-	path, err := h.Service.UploadAndProcessVideo(c, filePath, qualities)
+	tags := strings.Split(c.FormValue("tags"), ",")
+	media, job, err := h.Service.Ingest(c, filePath, file.Filename, file.Size, tags)
+	if err != nil {
+		logger.Error(err, "Ingest failed: %s", filePath)
+		switch {
+		case errors.Is(err, core.ErrUnsupportedMedia):
+			return c.Status(fiber.StatusUnsupportedMediaType).SendString("Unsupported media format")
+		case errors.Is(err, ErrInvalidMedia):
+			return c.Status(fiber.StatusUnprocessableEntity).SendString(err.Error())
+		case errors.Is(err, workspace.ErrInsufficientSpace):
+			return c.Status(fiber.StatusInsufficientStorage).SendString("Insufficient storage")
+		case errors.Is(err, jobs.ErrQueueFull):
+			return c.Status(fiber.StatusServiceUnavailable).SendString("Processing queue is full")
+		}
+		return c.Status(500).SendString(err.Error())
+	}
+	return sendIngested(c, media, job)
+}
+
+// sendIngested trả về media vừa ingest: 201 kèm media khi ảnh đã xử lý xong, 202 kèm job khi video chờ xử lý
+func sendIngested(c fiber.Ctx, media *database.Media, job *jobs.Job) error {
+	if job == nil {
+		// ảnh đã xử lý xong trong request
+		logger.Info("Upload processed: media %d", media.ID)
+		return c.Status(fiber.StatusCreated).JSON(ToMediaDTO(media))
+	}
+	logger.Info("Upload accepted: media %d, job %d", media.ID, job.ID)
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"media_id": media.ID,
+		"job_id":   job.ID,
+		"status":   media.Status,
+	})
+}
+
+// Events stream tiến độ xử lý media qua Server-Sent Events cho tới khi media ready/failed
+func (h *MediaHandler) Events(c fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).SendString("Invalid media id")
+	}
+	// stream writer chạy sau khi handler return nên không dùng c làm context
+	ctx, cancel := context.WithCancel(context.Background())
+	current, ch, err := h.Service.SubscribeEvents(ctx, uint(id))
+	if err != nil {
+		cancel()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).SendString("Media not found")
+		}
+		logger.Error(err, "Subscribe events failed: %d", id)
+		return c.Status(500).SendString(err.Error())
+	}
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+	return c.SendStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		if err := writeEvent(w, current); err != nil || current.Terminal() {
+			return
+		}
+		keepAlive := time.NewTicker(sseKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case e, ok := <-ch:
+				if !ok {
+					return
+				}
+				if err := writeEvent(w, e); err != nil || e.Terminal() {
+					return
+				}
+			case <-keepAlive.C:
+				if _, err := w.WriteString(": ping\n\n"); err != nil {
+					return
+				}
+				if err := w.Flush(); err != nil {
+					logger.Debug("SSE client disconnected: media %d", id)
+					return
+				}
+			}
+		}
+	})
+}
+
+// writeEvent ghi một event SSE với tên event là loại event, data là JSON
+func writeEvent(w *bufio.Writer, e events.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// List trả về danh sách media có lọc, sắp xếp và phân trang.
+// Query: page, size, cursor, type, status, created_from, created_to (unix hoặc RFC3339), tag, sort,
+// trashed=true để xem thùng rác.
+func (h *MediaHandler) List(c fiber.Ctx) error {
+	sort, err := ParseMediaSort(c.Query("sort"))
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+	p := ListMediaParams{
+		Filter: MediaFilter{
+			Type:    c.Query("type"),
+			Status:  c.Query("status"),
+			Tag:     strings.ToLower(strings.TrimSpace(c.Query("tag"))),
+			Trashed: fiber.Query[bool](c, "trashed"),
+		},
+		Sort:   sort,
+		Page:   fiber.Query[int](c, "page"),
+		Size:   fiber.Query[int](c, "size"),
+		Cursor: c.Query("cursor"),
+	}
+	if p.Filter.CreatedFrom, err = parseTimeQuery(c.Query("created_from")); err != nil {
+		return c.Status(400).SendString("Invalid created_from")
+	}
+	if p.Filter.CreatedTo, err = parseTimeQuery(c.Query("created_to")); err != nil {
+		return c.Status(400).SendString("Invalid created_to")
+	}
+	list, err := h.Service.ListMedia(p)
+	if err != nil {
+		if errors.Is(err, ErrInvalidQuery) {
+			return c.Status(400).SendString(err.Error())
+		}
+		logger.Error(err, "List media failed")
+		return c.Status(500).SendString(err.Error())
+	}
+	return c.JSON(list)
+}
+
+// parseTimeQuery nhận unix timestamp hoặc RFC3339, rỗng trả về 0
+func parseTimeQuery(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return v, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
 	if err != nil {
-		logger.Error(err, "Error processing video: %s", filePath)
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+// Image trả ảnh gốc, variant sinh sẵn (?variant=thumb) hoặc derivative sinh theo yêu cầu
+// (?w=400&h=300&fit=cover&fmt=webp&q=80), derivative được cache trên storage
+func (h *MediaHandler) Image(c fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.Status(400).SendString("Invalid media id")
+	}
+	query, err := url.ParseQuery(string(c.Request().URI().QueryString()))
+	if err != nil {
+		return c.Status(400).SendString("Invalid query")
+	}
+	// body được fasthttp đọc sau khi handler return nên không dùng c làm context
+	var file *StreamFile
+	if HasTransformParams(query) {
+		if err := VerifyImageQuery(uint(id), query); err != nil {
+			return c.Status(403).SendString(err.Error())
+		}
+		file, err = h.transformImage(uint(id), query)
+	} else {
+		file, err = h.Service.OpenImage(context.Background(), uint(id), query.Get("variant"))
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, ErrStreamNotFound):
+			return c.Status(404).SendString("Image not found")
+		case errors.Is(err, ErrInvalidQuery):
+			return c.Status(400).SendString(err.Error())
+		case errors.Is(err, workspace.ErrInsufficientSpace):
+			return c.Status(fiber.StatusServiceUnavailable).SendString("Insufficient storage")
+		}
+		logger.Error(err, "Open image failed: media %d", id)
 		return c.Status(500).SendString(err.Error())
 	}
-	logger.Info("Upload and process video success: %s", path)
-	return c.JSON(fiber.Map{"stream_url": "/media/stream/" + path})
+	c.Set("Content-Type", file.ContentType)
+	c.Set("Cache-Control", immutableCacheControl)
+	if file.ETag != "" {
+		c.Set("ETag", fmt.Sprintf("%q", file.ETag))
+	}
+	return c.SendStream(file.Body, int(file.Size))
 }
 
+func (h *MediaHandler) transformImage(id uint, query url.Values) (*StreamFile, error) {
+	media, err := h.Service.GetMedia(id)
+	if err != nil {
+		return nil, err
+	}
+	t, err := ParseImageTransform(query, media.Container)
+	if err != nil {
+		return nil, err
+	}
+	file, hit, err := h.Service.TransformImage(context.Background(), id, t)
+	if err == nil {
+		logger.Debug("Image transform %s for media %d (cache hit: %v)", t.Key(), id, hit)
+	}
+	return file, err
+}
+
+// Get trả về MediaDTO đầy đủ của media
 func (h *MediaHandler) Get(c fiber.Ctx) error {
-	// TODO: Lấy media theo id, trả về link hoặc stream
-	return c.SendStatus(501)
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.Status(400).SendString("Invalid media id")
+	}
+	media, err := h.Service.GetMedia(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).SendString("Media not found")
+		}
+		logger.Error(err, "Get media failed: %d", id)
+		return c.Status(500).SendString(err.Error())
+	}
+	return c.JSON(ToMediaDTO(media))
+}
+
+// Delete chuyển media vào thùng rác, trả về media kèm deleted_at và purge_at
+func (h *MediaHandler) Delete(c fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.Status(400).SendString("Invalid media id")
+	}
+	media, err := h.Service.DeleteMedia(c, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).SendString("Media not found")
+		}
+		logger.Error(err, "Delete media failed: %d", id)
+		return c.Status(500).SendString(err.Error())
+	}
+	return c.JSON(ToMediaDTO(media))
+}
+
+// Restore đưa media ra khỏi thùng rác, 409 khi media đã bắt đầu bị purge
+func (h *MediaHandler) Restore(c fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.Status(400).SendString("Invalid media id")
+	}
+	media, err := h.Service.RestoreMedia(c, uint(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.Status(404).SendString("Media not found")
+		case errors.Is(err, ErrMediaPurging):
+			return c.Status(fiber.StatusConflict).SendString(err.Error())
+		}
+		logger.Error(err, "Restore media failed: %d", id)
+		return c.Status(500).SendString(err.Error())
+	}
+	return c.JSON(ToMediaDTO(media))
 }
 
+// StreamHLS trả master playlist, rendition playlist và segment của video từ storage.
+// Đường dẫn rỗng trả về master playlist.
 func (h *MediaHandler) StreamHLS(c fiber.Ctx) error {
-	// id := c.Params("id") // Chưa dùng, comment lại để tránh lỗi
-	// TODO: Lấy path m3u8 từ DB, redirect hoặc serve file MinIO
-	return c.SendStatus(501)
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.Status(400).SendString("Invalid media id")
+	}
+	// body được fasthttp đọc sau khi handler return nên không dùng c làm context
+	file, err := h.Service.OpenStreamFile(context.Background(), uint(id), c.Params("*"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, ErrStreamNotFound) {
+			return c.Status(404).SendString("Stream not found")
+		}
+		logger.Error(err, "Open stream failed: media %d, %s", id, c.Params("*"))
+		return c.Status(500).SendString(err.Error())
+	}
+	c.Set("Content-Type", file.ContentType)
+	if file.Playlist {
+		c.Set("Cache-Control", playlistCacheControl)
+	} else {
+		c.Set("Cache-Control", immutableCacheControl)
+		if file.ETag != "" {
+			c.Set("ETag", fmt.Sprintf("%q", file.ETag))
+		}
+	}
+	// SendStream tự đóng body sau khi ghi xong
+	return c.SendStream(file.Body, int(file.Size))
 }
@@ -0,0 +1,238 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"photo-go/config"
+	"photo-go/internal/core"
+	"photo-go/internal/database"
+	"photo-go/pkg/logger"
+	"photo-go/pkg/storage"
+	"photo-go/pkg/types"
+	"time"
+)
+
+// imageSpaceFactor ước lượng dung lượng workspace cho các derivative so với ảnh gốc
+const imageSpaceFactor = 2
+
+// posterVariant là tên variant poster của video, phục vụ qua cùng endpoint với variant ảnh
+const posterVariant = "poster"
+
+// UploadAndProcessImage lưu ảnh gốc và các derivative theo IMAGE_VARIANTS lên storage dưới img/<mediaID>/,
+// tạo Media type image ở trạng thái ready. Ảnh được xử lý đồng bộ trong request.
+func (s *MediaService) UploadAndProcessImage(ctx context.Context, filePath string, size int64, format core.Format, tags []string) (*database.Media, error) {
+	info, err := s.Prober.Probe(ctx, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMedia, err)
+	}
+	if err := validateImage(info); err != nil {
+		return nil, err
+	}
+	exif, err := core.ReadEXIF(filePath)
+	if err != nil {
+		logger.Warn("Invalid EXIF ignored: %s: %v", filePath, err)
+	}
+	now := time.Now().Unix()
+	media := &database.Media{
+		Type:         string(types.MediaTypeImage),
+		Status:       string(types.MediaStatusProcessing),
+		OriginalSize: size,
+		Container:    format.Name,
+		Width:        info.Width,
+		Height:       info.Height,
+		Tags:         NormalizeTags(tags),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	applyEXIF(media, exif)
+	if err := s.Repo.Create(media); err != nil {
+		logger.Error(err, "DB create media failed")
+		return nil, err
+	}
+	if err := s.processImage(ctx, media, filePath, format, exif); err != nil {
+		s.markFailed(media, err)
+		return nil, err
+	}
+	logger.Info("Image ready: %d (%d variants)", media.ID, len(media.Variants))
+	return media, nil
+}
+
+func (s *MediaService) processImage(ctx context.Context, media *database.Media, filePath string, format core.Format, exif *core.EXIF) (err error) {
+	ws, err := s.Workspaces.Acquire(ctx, fmt.Sprintf("image-%d", media.ID), media.OriginalSize*imageSpaceFactor)
+	if err != nil {
+		return err
+	}
+	defer ws.Release()
+
+	policy := core.MetadataPolicy(config.Settings.ImageMetadataPolicy)
+	prefix := imagePrefix(media.ID)
+	media.OriginalPath = path.Join(prefix, "original"+format.Ext)
+	// ảnh gốc phục vụ cho client cũng phải theo policy, variant sinh từ bản đã lọc này.
+	// Luôn ghi lại kể cả khi không đọc được EXIF: vị trí có thể nằm trong EXIF hỏng hoặc XMP/IPTC.
+	sanitized := ws.Path("original" + format.Ext)
+	rewritten, err := core.SanitizeMetadata(filePath, sanitized, format.Name, policy, exif)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMedia, err)
+	}
+	if rewritten {
+		filePath = sanitized
+	}
+	if err := s.Storage.PutFile(ctx, media.OriginalPath, filePath, storage.PutOptions{}); err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		// media bị đánh dấu failed, không giữ ảnh gốc và variant đã upload dưới prefix của nó
+		if _, rmErr := s.Storage.DeletePrefix(context.WithoutCancel(ctx), prefix+"/"); rmErr != nil {
+			logger.Error(rmErr, "Cleanup image objects failed: %s", prefix)
+		}
+	}()
+
+	outFormat, ext := "jpeg", ".jpg"
+	if format.Name == "png" || format.Name == "gif" {
+		// giữ kênh alpha
+		outFormat, ext = "png", ".png"
+	}
+	outputDir := filepath.Join(ws.Dir, "variants")
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return err
+	}
+	var variants database.JSONList[database.ImageVariant]
+	for i, v := range config.Settings.ImageVariants {
+		// không sinh variant không nhỏ hơn ảnh gốc, trừ variant đầu tiên để luôn có thumbnail
+		if i > 0 && fitsWithin(media.Width, media.Height, v.Width, v.Height) {
+			continue
+		}
+		name := v.Name + ext
+		res, err := s.ImageCore.ProcessImage(ctx, filePath, filepath.Join(outputDir, name), core.ImageOptions{
+			Width:        v.Width,
+			Height:       v.Height,
+			Fit:          core.FitInside,
+			AutoOrient:   true,
+			KeepMetadata: policy != core.MetadataStripAll,
+			Format:       outFormat,
+			Quality:      v.Quality,
+		})
+		if err != nil {
+			return fmt.Errorf("%w: variant %s: %v", ErrInvalidMedia, v.Name, err)
+		}
+		st, err := os.Stat(filepath.Join(outputDir, name))
+		if err != nil {
+			return err
+		}
+		variants = append(variants, database.ImageVariant{
+			Name:   v.Name,
+			Path:   path.Join(prefix, name),
+			Width:  res.Width,
+			Height: res.Height,
+			Size:   st.Size(),
+			Format: res.Format,
+		})
+	}
+	if len(variants) > 0 {
+		// variant đầu tiên là nhỏ nhất, đủ để tính placeholder
+		s.applyPlaceholder(media, filepath.Join(outputDir, path.Base(variants[0].Path)))
+	}
+	if err := s.uploadDir(ctx, outputDir, prefix, "", nil); err != nil {
+		return err
+	}
+	media.Path = media.OriginalPath
+	media.Variants = variants
+	return s.setStatus(media, types.MediaStatusReady, "")
+}
+
+// OpenImage mở ảnh gốc hoặc một variant đã sinh sẵn của media ảnh, hoặc poster của video
+func (s *MediaService) OpenImage(ctx context.Context, mediaID uint, variant string) (*StreamFile, error) {
+	media, err := s.Repo.FindByID(mediaID)
+	if err != nil {
+		return nil, err
+	}
+	if media.Status != string(types.MediaStatusReady) || media.DeletedAt != 0 {
+		return nil, ErrStreamNotFound
+	}
+	objectName := media.Path
+	if variant == "" || variant == "original" {
+		// file gốc của video không phục vụ qua endpoint ảnh
+		if media.Type != string(types.MediaTypeImage) {
+			return nil, ErrStreamNotFound
+		}
+	} else {
+		objectName = ""
+		for _, v := range media.Variants {
+			if v.Name == variant {
+				objectName = v.Path
+				break
+			}
+		}
+		if objectName == "" {
+			return nil, ErrStreamNotFound
+		}
+	}
+	body, info, err := s.Storage.Get(ctx, objectName, nil)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			return nil, ErrStreamNotFound
+		}
+		return nil, err
+	}
+	return &StreamFile{Body: body, Size: info.Size, ContentType: storage.ContentTypeFor(objectName), ETag: info.ETag}, nil
+}
+
+func imagePrefix(mediaID uint) string {
+	return fmt.Sprintf("img/%d", mediaID)
+}
+
+// applyPlaceholder tính BlurHash và màu chủ đạo từ ảnh local, lỗi chỉ được log vì placeholder không bắt buộc
+func (s *MediaService) applyPlaceholder(media *database.Media, imagePath string) {
+	p, err := core.ComputePlaceholder(imagePath)
+	if err != nil {
+		logger.Warn("Compute placeholder failed: media %d: %v", media.ID, err)
+		return
+	}
+	media.BlurHash = p.BlurHash
+	media.Colors = p.Colors
+}
+
+// applyEXIF lưu các trường EXIF lên media, kích thước đổi sang chiều hiển thị theo Orientation
+func applyEXIF(media *database.Media, e *core.EXIF) {
+	if e == nil {
+		return
+	}
+	media.CameraMake = e.Make
+	media.CameraModel = e.Model
+	media.LensModel = e.LensModel
+	media.ExposureTime = e.ExposureTime
+	media.FNumber = e.FNumber
+	media.ISO = e.ISO
+	media.FocalLength = e.FocalLength
+	media.Orientation = e.Orientation
+	if !e.CapturedAt.IsZero() {
+		media.CapturedAt = e.CapturedAt.Unix()
+	}
+	if e.Orientation >= 5 {
+		media.Width, media.Height = media.Height, media.Width
+	}
+	if g := e.GPS; g != nil {
+		media.Latitude, media.Longitude, media.Altitude = &g.Latitude, &g.Longitude, &g.Altitude
+	}
+}
+
+func validateImage(info *core.MediaInfo) error {
+	if info.Width <= 0 || info.Height <= 0 {
+		return fmt.Errorf("%w: unknown image dimensions", ErrInvalidMedia)
+	}
+	if max := config.Settings.MaxImagePixels; int64(info.Width)*int64(info.Height) > max {
+		return fmt.Errorf("%w: %dx%d exceeds %d pixels", ErrInvalidMedia, info.Width, info.Height, max)
+	}
+	return nil
+}
+
+// fitsWithin trả về true nếu ảnh w x h đã nằm gọn trong khung maxW x maxH (0 = không giới hạn)
+func fitsWithin(w, h, maxW, maxH int) bool {
+	return (maxW == 0 || w <= maxW) && (maxH == 0 || h <= maxH)
+}
@@ -0,0 +1,145 @@
+package v1
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"photo-go/config"
+	"photo-go/internal/database"
+	"photo-go/pkg/types"
+	"strings"
+)
+
+// ErrInvalidQuery trả về khi tham số danh sách (sort, cursor, ...) không hợp lệ
+var ErrInvalidQuery = errors.New("invalid query")
+
+// mediaSortColumns map tên sort trên API sang cột trong DB, mọi cột đều là int64
+var mediaSortColumns = map[string]string{
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"size":       "original_size",
+}
+
+// MediaSort là cách sắp xếp danh sách media, luôn kèm id để thứ tự ổn định
+type MediaSort struct {
+	Field string
+	Desc  bool
+}
+
+// ParseMediaSort đọc sort dạng "created_at" hoặc "-created_at" (giảm dần); rỗng = mới nhất trước
+func ParseMediaSort(s string) (MediaSort, error) {
+	if s == "" {
+		return MediaSort{Field: "created_at", Desc: true}, nil
+	}
+	sort := MediaSort{Field: strings.TrimPrefix(s, "-"), Desc: strings.HasPrefix(s, "-")}
+	if _, ok := mediaSortColumns[sort.Field]; !ok {
+		return sort, fmt.Errorf("%w: unknown sort %q", ErrInvalidQuery, sort.Field)
+	}
+	return sort, nil
+}
+
+func (s MediaSort) String() string {
+	if s.Desc {
+		return "-" + s.Field
+	}
+	return s.Field
+}
+
+// Column trả về cột DB tương ứng với Field
+func (s MediaSort) Column() string {
+	return mediaSortColumns[s.Field]
+}
+
+func (s MediaSort) value(m *database.Media) int64 {
+	switch s.Field {
+	case "updated_at":
+		return m.UpdatedAt
+	case "size":
+		return m.OriginalSize
+	default:
+		return m.CreatedAt
+	}
+}
+
+// MediaCursor là vị trí của media cuối cùng trên trang trước, dùng cho keyset pagination.
+// Khác offset, cursor không bị lệch khi có upload mới chen vào đầu danh sách.
+type MediaCursor struct {
+	Sort  string `json:"s"`
+	Value int64  `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+// Encode mã hóa cursor thành chuỗi opaque cho client
+func (c MediaCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeMediaCursor giải mã cursor và kiểm tra nó được tạo với cùng cách sort
+func DecodeMediaCursor(s string, sort MediaSort) (*MediaCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	var c MediaCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == 0 {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	if c.Sort != sort.String() {
+		return nil, fmt.Errorf("%w: cursor was created with sort %q", ErrInvalidQuery, c.Sort)
+	}
+	return &c, nil
+}
+
+// ListMediaParams là tham số danh sách đã parse từ query string
+type ListMediaParams struct {
+	Filter MediaFilter
+	Sort   MediaSort
+	Page   int
+	Size   int
+	Cursor string // có cursor thì bỏ qua Page
+}
+
+// ListMedia trả về một trang media. Trang kế tiếp lấy bằng NextCursor trong response.
+func (s *MediaService) ListMedia(p ListMediaParams) (*types.MediaListDTO, error) {
+	cfg := config.Settings
+	if p.Size <= 0 {
+		p.Size = cfg.DefaultPageSize
+	}
+	if p.Size > cfg.MaxPageSize {
+		p.Size = cfg.MaxPageSize
+	}
+	if p.Page <= 0 {
+		p.Page = cfg.DefaultPageNumber
+	}
+	// lấy dư một bản ghi để biết còn trang sau hay không
+	q := MediaQuery{Filter: p.Filter, Sort: p.Sort, Limit: p.Size + 1}
+	if p.Cursor != "" {
+		after, err := DecodeMediaCursor(p.Cursor, p.Sort)
+		if err != nil {
+			return nil, err
+		}
+		q.After = after
+	} else {
+		q.Offset = (p.Page - 1) * p.Size
+	}
+	ms, total, err := s.Repo.List(q)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &types.MediaListDTO{Items: make([]types.MediaDTO, 0, len(ms)), Total: total, Size: p.Size}
+	if p.Cursor == "" {
+		out.Page = p.Page
+	}
+	if len(ms) > p.Size {
+		ms = ms[:p.Size]
+		last := &ms[len(ms)-1]
+		out.NextCursor = MediaCursor{Sort: p.Sort.String(), Value: p.Sort.value(last), ID: last.ID}.Encode()
+	}
+	for i := range ms {
+		out.Items = append(out.Items, ToMediaDTO(&ms[i]))
+	}
+	return out, nil
+}
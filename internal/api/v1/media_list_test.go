@@ -0,0 +1,39 @@
+package v1
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseMediaSort tests sort parsing and the default order
+func TestParseMediaSort(t *testing.T) {
+	s, err := ParseMediaSort("")
+	require.NoError(t, err)
+	assert.Equal(t, MediaSort{Field: "created_at", Desc: true}, s)
+
+	s, err = ParseMediaSort("size")
+	require.NoError(t, err)
+	assert.Equal(t, "original_size", s.Column())
+	assert.Equal(t, "size", s.String())
+
+	_, err = ParseMediaSort("-id; DROP TABLE media")
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+}
+
+// TestMediaCursor tests the cursor round trip and that it is bound to its sort
+func TestMediaCursor(t *testing.T) {
+	sort := MediaSort{Field: "created_at", Desc: true}
+	c := MediaCursor{Sort: sort.String(), Value: 1700000000, ID: 42}
+
+	got, err := DecodeMediaCursor(c.Encode(), sort)
+	require.NoError(t, err)
+	assert.Equal(t, c, *got)
+
+	_, err = DecodeMediaCursor(c.Encode(), MediaSort{Field: "size"})
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+	_, err = DecodeMediaCursor("not-a-cursor", sort)
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+}
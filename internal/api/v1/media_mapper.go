@@ -0,0 +1,148 @@
+package v1
+
+import (
+	"fmt"
+	"net/url"
+	"photo-go/config"
+	"photo-go/internal/core"
+	"photo-go/internal/database"
+	"photo-go/pkg/types"
+	"strings"
+)
+
+// StreamURL là URL phát HLS của media qua API
+func StreamURL(mediaID uint) string {
+	return streamFileURL(mediaID, core.MasterPlaylistName)
+}
+
+// ImageURL là URL ảnh gốc (variant rỗng) hoặc variant đã sinh sẵn của media ảnh
+func ImageURL(mediaID uint, variant string) string {
+	if variant == "" {
+		return fmt.Sprintf("/v1/media/%d/image", mediaID)
+	}
+	return fmt.Sprintf("/v1/media/%d/image?variant=%s", mediaID, url.QueryEscape(variant))
+}
+
+func streamFileURL(mediaID uint, name string) string {
+	return fmt.Sprintf("/v1/media/stream/%d/%s", mediaID, name)
+}
+
+// ToMediaDTO là nơi duy nhất map database.Media sang response, mọi endpoint dùng chung
+func ToMediaDTO(m *database.Media) types.MediaDTO {
+	dto := types.MediaDTO{
+		ID:            m.ID,
+		Type:          types.MediaType(m.Type),
+		Status:        types.MediaStatus(m.Status),
+		FailureReason: m.FailureReason,
+		Size:          m.OriginalSize,
+		Tags:          make([]string, 0, len(m.Tags)),
+		BlurHash:      m.BlurHash,
+		Colors:        m.Colors,
+		Thumbnails:    []types.ThumbnailDTO{},
+		Renditions:    make([]types.RenditionDTO, 0, len(m.Renditions)),
+		DeletedAt:     m.DeletedAt,
+		PurgeAt:       PurgeAt(m),
+		CreatedAt:     m.CreatedAt,
+		UpdatedAt:     m.UpdatedAt,
+	}
+	for _, t := range m.Tags {
+		dto.Tags = append(dto.Tags, t.Tag)
+	}
+	if m.Container != "" || m.Width > 0 {
+		dto.Metadata = &types.MediaMetadata{
+			Container:     m.Container,
+			Duration:      m.Duration,
+			Width:         m.Width,
+			Height:        m.Height,
+			FrameRate:     m.FrameRate,
+			VideoCodec:    m.VideoCodec,
+			AudioCodec:    m.AudioCodec,
+			Bitrate:       m.Bitrate,
+			AudioChannels: m.AudioChannels,
+			Rotation:      m.Rotation,
+			CameraMake:    m.CameraMake,
+			CameraModel:   m.CameraModel,
+			LensModel:     m.LensModel,
+			ExposureTime:  m.ExposureTime,
+			FNumber:       m.FNumber,
+			ISO:           m.ISO,
+			FocalLength:   m.FocalLength,
+			CapturedAt:    m.CapturedAt,
+		}
+		if core.MetadataPolicy(config.Settings.ImageMetadataPolicy) == core.MetadataKeepAll {
+			dto.Metadata.Latitude, dto.Metadata.Longitude, dto.Metadata.Altitude = m.Latitude, m.Longitude, m.Altitude
+		}
+	}
+	if dto.Status != types.MediaStatusReady || m.DeletedAt != 0 {
+		// media trong thùng rác không còn được phục vụ
+		return dto
+	}
+	switch dto.Type {
+	case types.MediaTypeVideo:
+		dto.URL = StreamURL(m.ID)
+	case types.MediaTypeImage:
+		dto.URL = ImageURL(m.ID, "")
+	}
+	for _, v := range m.Variants {
+		dto.Thumbnails = append(dto.Thumbnails, types.ThumbnailDTO{
+			Name:   v.Name,
+			URL:    ImageURL(m.ID, v.Name),
+			Width:  v.Width,
+			Height: v.Height,
+		})
+	}
+	if m.PreviewTrack != "" {
+		dto.PreviewTrack = streamFileURL(m.ID, m.PreviewTrack)
+	}
+	for _, r := range m.Renditions {
+		dto.Renditions = append(dto.Renditions, types.RenditionDTO{
+			Name:      r.Name,
+			URL:       streamFileURL(m.ID, r.Playlist),
+			Width:     r.Width,
+			Height:    r.Height,
+			Bandwidth: r.Bandwidth,
+			Codecs:    r.Codecs,
+		})
+	}
+	return dto
+}
+
+// maxTags và maxTagLength giới hạn tag người dùng gắn cho một media
+const (
+	maxTags      = 20
+	maxTagLength = 64
+)
+
+// NormalizeTags chuẩn hóa tag (trim, lowercase), bỏ trùng và bỏ tag rỗng/quá dài
+func NormalizeTags(tags []string) []database.MediaTag {
+	seen := map[string]bool{}
+	out := make([]database.MediaTag, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || len(t) > maxTagLength || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, database.MediaTag{Tag: t})
+		if len(out) == maxTags {
+			break
+		}
+	}
+	return out
+}
+
+// toRenditionRecords chuyển rendition từ core sang bản ghi lưu trên Media
+func toRenditionRecords(renditions []core.Rendition) database.JSONList[database.Rendition] {
+	out := make(database.JSONList[database.Rendition], 0, len(renditions))
+	for _, r := range renditions {
+		out = append(out, database.Rendition{
+			Name:      r.Name,
+			Playlist:  r.Playlist,
+			Width:     r.Width,
+			Height:    r.Height,
+			Bandwidth: r.Bandwidth,
+			Codecs:    r.Codecs,
+		})
+	}
+	return out
+}
@@ -1,15 +1,52 @@
 package v1
 
 import (
+	"fmt"
 	"photo-go/internal/database"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 type MediaRepository interface {
 	Create(media *database.Media) error
+	Update(media *database.Media) error
 	FindByID(id uint) (*database.Media, error)
-	List(offset, limit int) ([]database.Media, error)
+	// List trả về một trang media theo q cùng tổng số media khớp bộ lọc (không tính cursor)
+	List(q MediaQuery) ([]database.Media, int64, error)
+	// Trash xóa mềm media, false khi media đã nằm trong thùng rác
+	Trash(id uint, now int64) (bool, error)
+	// Restore đưa media ra khỏi thùng rác, false khi media không bị xóa hoặc đã được enqueue purge
+	Restore(id uint) (bool, error)
+	// ListPurgeable trả về tối đa limit media bị xóa trước deletedBefore,
+	// chưa enqueue purge hoặc enqueue trước queuedBefore (job bị mất)
+	ListPurgeable(deletedBefore, queuedBefore int64, limit int) ([]database.Media, error)
+	// ClaimPurge đánh dấu media đã enqueue purge lúc now, false khi media vừa được restore
+	// hoặc replica khác đã claim sau queuedBefore
+	ClaimPurge(id uint, queuedBefore, now int64) (bool, error)
+	// ReleasePurge bỏ đánh dấu khi enqueue thất bại, media lại restore được
+	ReleasePurge(id uint) error
+	// Delete xóa hẳn bản ghi media cùng tag
+	Delete(id uint) error
+}
+
+// MediaFilter là các điều kiện lọc danh sách media, trường rỗng bị bỏ qua
+type MediaFilter struct {
+	Type        string
+	Status      string
+	CreatedFrom int64 // unix, bao gồm
+	CreatedTo   int64 // unix, không bao gồm
+	Tag         string
+	Trashed     bool // true chỉ liệt kê media trong thùng rác, mặc định loại bỏ chúng
+}
+
+// MediaQuery là một truy vấn danh sách: lọc, sắp xếp và phân trang theo offset hoặc cursor
+type MediaQuery struct {
+	Filter MediaFilter
+	Sort   MediaSort
+	Offset int
+	Limit  int
+	After  *MediaCursor // keyset: chỉ lấy media đứng sau cursor, bỏ qua Offset
 }
 
 type GormMediaRepository struct {
@@ -24,14 +61,97 @@ func (r *GormMediaRepository) Create(media *database.Media) error {
 	return r.DB.Create(media).Error
 }
 
+// Update ghi toàn bộ media trừ trạng thái thùng rác, để job đang xử lý
+// lưu bản ghi cũ không hủy mất thao tác xóa/restore xảy ra giữa chừng
+func (r *GormMediaRepository) Update(media *database.Media) error {
+	return r.DB.Omit("DeletedAt", "PurgeQueuedAt").Save(media).Error
+}
+
 func (r *GormMediaRepository) FindByID(id uint) (*database.Media, error) {
 	var m database.Media
-	err := r.DB.First(&m, id).Error
+	err := r.DB.Preload("Tags").First(&m, id).Error
 	return &m, err
 }
 
-func (r *GormMediaRepository) List(offset, limit int) ([]database.Media, error) {
+func (r *GormMediaRepository) List(q MediaQuery) ([]database.Media, int64, error) {
+	query := r.DB.Model(&database.Media{})
+	f := q.Filter
+	if f.Trashed {
+		query = query.Where("deleted_at <> 0")
+	} else {
+		query = query.Where("deleted_at = 0")
+	}
+	if f.Type != "" {
+		query = query.Where("type = ?", f.Type)
+	}
+	if f.Status != "" {
+		query = query.Where("status = ?", f.Status)
+	}
+	if f.CreatedFrom > 0 {
+		query = query.Where("created_at >= ?", f.CreatedFrom)
+	}
+	if f.CreatedTo > 0 {
+		query = query.Where("created_at < ?", f.CreatedTo)
+	}
+	if f.Tag != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM media_tags t WHERE t.media_id = media.id AND t.tag = ?)", f.Tag)
+	}
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	col, dir, cmp := q.Sort.Column(), "ASC", ">"
+	if q.Sort.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if q.After != nil {
+		// so sánh theo cặp (cột sort, id) để thứ tự ổn định khi nhiều media trùng giá trị
+		query = query.Where(fmt.Sprintf("(%[1]s %[2]s ?) OR (%[1]s = ? AND id %[2]s ?)", col, cmp), q.After.Value, q.After.Value, q.After.ID)
+	} else if q.Offset > 0 {
+		query = query.Offset(q.Offset)
+	}
+	var ms []database.Media
+	err := query.Preload("Tags").
+		Order(fmt.Sprintf("%s %s, id %s", col, dir, dir)).
+		Limit(q.Limit).
+		Find(&ms).Error
+	return ms, total, err
+}
+
+func (r *GormMediaRepository) Trash(id uint, now int64) (bool, error) {
+	res := r.DB.Model(&database.Media{}).
+		Where("id = ? AND deleted_at = 0", id).
+		UpdateColumns(map[string]any{"deleted_at": now, "purge_queued_at": 0, "updated_at": now})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *GormMediaRepository) Restore(id uint) (bool, error) {
+	res := r.DB.Model(&database.Media{}).
+		Where("id = ? AND deleted_at <> 0 AND purge_queued_at = 0", id).
+		UpdateColumns(map[string]any{"deleted_at": 0, "updated_at": time.Now().Unix()})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *GormMediaRepository) ListPurgeable(deletedBefore, queuedBefore int64, limit int) ([]database.Media, error) {
 	var ms []database.Media
-	err := r.DB.Offset(offset).Limit(limit).Find(&ms).Error
+	err := r.DB.Where("deleted_at <> 0 AND deleted_at < ? AND purge_queued_at < ?", deletedBefore, queuedBefore).
+		Order("deleted_at").Limit(limit).Find(&ms).Error
 	return ms, err
 }
+
+func (r *GormMediaRepository) ClaimPurge(id uint, queuedBefore, now int64) (bool, error) {
+	res := r.DB.Model(&database.Media{}).
+		Where("id = ? AND deleted_at <> 0 AND purge_queued_at < ?", id, queuedBefore).
+		UpdateColumn("purge_queued_at", now)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *GormMediaRepository) ReleasePurge(id uint) error {
+	return r.DB.Model(&database.Media{}).Where("id = ?", id).UpdateColumn("purge_queued_at", 0).Error
+}
+
+func (r *GormMediaRepository) Delete(id uint) error {
+	// media_tags có ON DELETE CASCADE
+	return r.DB.Delete(&database.Media{}, id).Error
+}
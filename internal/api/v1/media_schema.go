@@ -2,12 +2,19 @@ package v1
 
 import (
 	"photo-go/internal/core"
-	"photo-go/pkg/utils"
+	"photo-go/internal/events"
+	"photo-go/internal/jobs"
+	"photo-go/internal/workspace"
+	"photo-go/pkg/storage"
 )
 
 type MediaService struct {
-	VideoCore core.VideoProcessor
-	ImageCore core.ImageProcessor
-	Repo      MediaRepository
-	Minio     *utils.MinioClient
+	VideoCore  core.VideoProcessor
+	ImageCore  core.ImageProcessor
+	Prober     core.Prober
+	Repo       MediaRepository
+	Storage    storage.Storage
+	Workspaces *workspace.Manager
+	Queue      jobs.Queue
+	Events     events.Broker
 }
@@ -2,63 +2,475 @@ package v1
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"photo-go/config"
 	"photo-go/internal/core"
 	"photo-go/internal/database"
+	"photo-go/internal/events"
+	"photo-go/internal/jobs"
+	"photo-go/internal/workspace"
 	"photo-go/pkg/logger"
-	"photo-go/pkg/utils"
+	"photo-go/pkg/storage"
+	"photo-go/pkg/types"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"gorm.io/gorm"
 )
 
-func NewMediaService(v core.VideoProcessor, i core.ImageProcessor, r MediaRepository, m *utils.MinioClient) *MediaService {
+// ErrInvalidMedia trả về khi file upload không phải media hợp lệ hoặc vi phạm giới hạn
+var ErrInvalidMedia = errors.New("invalid media")
+
+// transcodeSpaceFactor ước lượng dung lượng workspace cần cho file gốc cộng output HLS
+const transcodeSpaceFactor = 3
+
+func NewMediaService(v core.VideoProcessor, i core.ImageProcessor, p core.Prober, r MediaRepository, st storage.Storage, w *workspace.Manager, q jobs.Queue, e events.Broker) *MediaService {
 	return &MediaService{
-		VideoCore: v,
-		ImageCore: i,
-		Repo:      r,
-		Minio:     m,
+		VideoCore:  v,
+		ImageCore:  i,
+		Prober:     p,
+		Repo:       r,
+		Storage:    st,
+		Workspaces: w,
+		Queue:      q,
+		Events:     e,
 	}
 }
 
-// UploadAndProcessVideo upload, transcode, lưu DB
-func (s *MediaService) UploadAndProcessVideo(ctx context.Context, filePath string, qualities []string) (string, error) {
-	logger.Info("Start processing video: %s", filePath)
-	// 1. Transcode HLS multi-quality ra thư mục tạm
-	outputDir := "/tmp/hls_output" // TODO: random hóa tránh trùng
-	if err := s.VideoCore.TranscodeToHLS(ctx, filePath, outputDir, qualities); err != nil {
-		logger.Error(err, "TranscodeToHLS failed: %s", filePath)
-		return "", err
-	}
-	logger.Info("TranscodeToHLS success: %s", filePath)
-	// 2. Upload tất cả file .m3u8, .ts lên MinIO
-	for _, q := range qualities {
-		m3u8Path := fmt.Sprintf("%s/%s.m3u8", outputDir, q)
-		objectName := fmt.Sprintf("hls/%d/%s.m3u8", time.Now().UnixNano(), q)
-		logger.Info("Uploading m3u8 to Minio: %s", objectName)
-		if err := s.Minio.Upload(ctx, objectName, m3u8Path); err != nil {
-			logger.Error(err, "Minio upload failed: %s", m3u8Path)
-			return "", err
-		}
-		logger.Info("Uploaded m3u8 to Minio: %s", objectName)
-		// TODO: upload các file .ts tương ứng
+// Ingest nhận diện loại media từ magic bytes rồi chuyển sang pipeline tương ứng.
+// Video được xử lý nền và trả về job, ảnh được xử lý ngay nên job là nil.
+func (s *MediaService) Ingest(ctx context.Context, filePath, filename string, size int64, tags []string) (*database.Media, *jobs.Job, error) {
+	format, err := core.DetectFile(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	logger.Info("Detected %s (%s): %s", format.Type, format.Name, filename)
+	if format.Type == types.MediaTypeImage {
+		media, err := s.UploadAndProcessImage(ctx, filePath, size, format, tags)
+		return media, nil, err
 	}
-	// 3. Lưu DB
+	return s.IngestVideo(ctx, filePath, filename, size, tags)
+}
+
+// IngestVideo lưu file gốc lên storage, tạo Media ở trạng thái pending và enqueue job xử lý nền
+func (s *MediaService) IngestVideo(ctx context.Context, filePath, filename string, size int64, tags []string) (*database.Media, *jobs.Job, error) {
+	return s.ingestVideo(ctx, filename, size, tags, func(objectName string) error {
+		return s.Storage.PutFile(ctx, objectName, filePath, storage.PutOptions{})
+	})
+}
+
+// IngestVideoObject như IngestVideo nhưng file gốc đã nằm trên storage (presigned upload), chỉ copy phía server.
+// Bản copy phải đúng size byte, nếu không trả về ErrUploadMismatch.
+func (s *MediaService) IngestVideoObject(ctx context.Context, srcObject, filename string, size int64, tags []string) (*database.Media, *jobs.Job, error) {
+	return s.ingestVideo(ctx, filename, size, tags, func(objectName string) error {
+		if err := s.Storage.Copy(ctx, srcObject, objectName); err != nil {
+			return err
+		}
+		info, err := s.Storage.Stat(ctx, objectName)
+		if err != nil {
+			return err
+		}
+		if info.Size != size {
+			_ = s.Storage.Delete(context.WithoutCancel(ctx), objectName)
+			return fmt.Errorf("%w: size %d, expected %d", ErrUploadMismatch, info.Size, size)
+		}
+		return nil
+	})
+}
+
+func (s *MediaService) ingestVideo(ctx context.Context, filename string, size int64, tags []string, store func(objectName string) error) (*database.Media, *jobs.Job, error) {
+	now := time.Now().Unix()
 	media := &database.Media{
-		Type:      "video",
-		Path:      "hls/.../master.m3u8", // TODO: sinh master playlist
-		CreatedAt: time.Now().Unix(),
-		UpdatedAt: time.Now().Unix(),
+		Type:         string(types.MediaTypeVideo),
+		Status:       string(types.MediaStatusPending),
+		OriginalSize: size,
+		Tags:         NormalizeTags(tags),
+		CreatedAt:    now,
+		UpdatedAt:    now,
 	}
-	logger.Info("Saving media to DB: %s", media.Path)
 	if err := s.Repo.Create(media); err != nil {
 		logger.Error(err, "DB create media failed")
-		return "", err
+		return nil, nil, err
+	}
+	media.OriginalPath = path.Join(originalPrefix(media.ID), workspace.SanitizeFilename(filename))
+	if err := store(media.OriginalPath); err != nil {
+		s.markFailed(media, err)
+		return nil, nil, err
+	}
+	media.UpdatedAt = time.Now().Unix()
+	if err := s.Repo.Update(media); err != nil {
+		logger.Error(err, "DB update media failed: %d", media.ID)
+		return nil, nil, err
+	}
+	job, err := s.Queue.Enqueue(ctx, jobs.TypeProcessMedia, media.ID)
+	if err != nil {
+		s.markFailed(media, err)
+		return nil, nil, err
+	}
+	logger.Info("Media %d ingested, job %d enqueued", media.ID, job.ID)
+	return media, job, nil
+}
+
+// GetMedia trả về media theo id, gorm.ErrRecordNotFound nếu không tồn tại
+func (s *MediaService) GetMedia(id uint) (*database.Media, error) {
+	return s.Repo.FindByID(id)
+}
+
+// ProcessMediaJob là handler của jobs.TypeProcessMedia: probe, transcode và upload HLS
+func (s *MediaService) ProcessMediaJob(ctx context.Context, job *jobs.Job) error {
+	media, err := s.Repo.FindByID(job.MediaID)
+	if err != nil {
+		logger.Error(err, "Media not found for job %d: %d", job.ID, job.MediaID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// media đã bị purge
+			return jobs.Permanent(err)
+		}
+		return err
 	}
-	logger.Info("Media saved to DB: %s", media.Path)
-	return media.Path, nil
+	// media trong thùng rác vẫn được xử lý để restore dùng được ngay, trừ khi đã bị enqueue purge
+	if media.PurgeQueuedAt != 0 {
+		logger.Info("Media %d is being purged, skip processing job %d", media.ID, job.ID)
+		return jobs.Permanent(ErrMediaDeleted)
+	}
+	progress := newJobProgress(s.Queue, s.Events, job)
+	err = s.processVideo(ctx, media, progress)
+	if err == nil {
+		s.publish(ctx, events.Event{Type: events.TypeReady, MediaID: media.ID, JobID: job.ID, Stage: stageDone, Progress: 100, URL: StreamURL(media.ID)})
+		return nil
+	}
+	if errors.Is(err, ErrInvalidMedia) {
+		err = jobs.Permanent(err)
+	}
+	if jobs.IsCanceled(ctx) {
+		s.markFailed(media, jobs.ErrCanceled)
+		return err
+	}
+	if ctx.Err() != nil {
+		// worker tắt hoặc mất lock: queue trả job lại (không tính lượt) hoặc worker khác đang chạy,
+		// media giữ nguyên trạng thái để client tiếp tục chờ kết quả
+		logger.Warn("Job %d for media %d interrupted: %v", job.ID, media.ID, context.Cause(ctx))
+		return err
+	}
+	if jobs.IsPermanent(err) || job.LastAttempt() {
+		s.markFailed(media, err)
+	} else {
+		// còn lượt retry: trả media về pending, giữ lý do lỗi gần nhất
+		_ = s.setStatus(media, types.MediaStatusPending, err.Error())
+		s.publish(ctx, events.Event{Type: events.TypeProgress, MediaID: media.ID, JobID: job.ID, Stage: stageRetrying, Error: err.Error()})
+	}
+	return err
 }
 
-// UploadAndProcessImage upload, xử lý, lưu DB
-func (s *MediaService) UploadAndProcessImage(ctx context.Context, filePath string) error {
-	// TODO: upload MinIO, gọi ImageCore.ProcessImage, lưu DB
+// ProcessMediaFailed là FailureHandler của jobs.TypeProcessMedia: job kết thúc mà ProcessMediaJob
+// không chạy tới cuối (vd: bị hủy lúc còn chờ) thì media chuyển sang failed để client nhận event kết thúc
+func (s *MediaService) ProcessMediaFailed(ctx context.Context, job *jobs.Job, cause error) {
+	media, err := s.Repo.FindByID(job.MediaID)
+	if err != nil {
+		logger.Error(err, "Media not found for job %d: %d", job.ID, job.MediaID)
+		return
+	}
+	if media.Status == string(types.MediaStatusReady) {
+		return
+	}
+	s.markFailed(media, cause)
+}
+
+// processVideo tải file gốc về workspace riêng, transcode và upload output lên prefix của media
+func (s *MediaService) processVideo(ctx context.Context, media *database.Media, progress *jobProgress) error {
+	logger.Info("Start processing video: media %d", media.ID)
+	if err := s.setStatus(media, types.MediaStatusProcessing, ""); err != nil {
+		return err
+	}
+	ws, err := s.Workspaces.Acquire(ctx, fmt.Sprintf("media-%d", media.ID), media.OriginalSize*transcodeSpaceFactor)
+	if err != nil {
+		return err
+	}
+	defer ws.Release()
+
+	// 1. Tải file gốc về workspace
+	progress.report(ctx, stageDownloading, 0)
+	filePath := ws.Path(path.Base(media.OriginalPath))
+	if err := storage.GetFile(ctx, s.Storage, media.OriginalPath, filePath); err != nil {
+		return err
+	}
+	// 2. Probe metadata để validate và chọn ladder
+	progress.report(ctx, stageProbing, 4)
+	info, err := s.Prober.Probe(ctx, filePath)
+	if err != nil {
+		logger.Error(err, "Probe failed: %s", filePath)
+		return fmt.Errorf("%w: %v", ErrInvalidMedia, err)
+	}
+	if err := validateVideo(info); err != nil {
+		logger.Warn("Video rejected: media %d: %v", media.ID, err)
+		return err
+	}
+	applyMediaInfo(media, info)
+	// 3. Transcode HLS multi-quality ra workspace, sinh master playlist
+	outputDir := filepath.Join(ws.Dir, "hls")
+	progress.report(ctx, stageTranscoding, 5)
+	renditions, err := s.VideoCore.TranscodeToHLS(ctx, filePath, outputDir, core.TranscodeOptions{
+		Source: info,
+		Progress: func(p core.TranscodeProgress) {
+			stage := stageTranscoding
+			if p.Rendition != "" {
+				stage += " " + p.Rendition
+			}
+			progress.report(ctx, stage, 5+p.Percent*0.8)
+		},
+	})
+	if err != nil {
+		logger.Error(err, "TranscodeToHLS failed: %s", filePath)
+		return err
+	}
+	logger.Info("TranscodeToHLS success: media %d (%d renditions)", media.ID, len(renditions))
+	// 4. Poster, sprite sheet và WebVTT cho preview trên thanh seek, lỗi không làm hỏng job
+	progress.report(ctx, stagePreviews, 85)
+	previews, err := s.VideoCore.GeneratePreviews(ctx, filePath, outputDir, core.PreviewOptions{
+		Source:    info,
+		Interval:  config.Settings.PreviewInterval,
+		MaxFrames: config.Settings.PreviewMaxFrames,
+		Width:     config.Settings.PreviewWidth,
+		Columns:   config.Settings.PreviewSpriteColumns,
+		Rows:      config.Settings.PreviewSpriteRows,
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		logger.Warn("Generate previews failed: media %d: %v", media.ID, err)
+	}
+	// 5. Upload toàn bộ thư mục output (playlist, segment, preview) dưới cùng một prefix
+	prefix := hlsPrefix(media.ID)
+	progress.report(ctx, stageUploading, 90)
+	err = s.uploadDir(ctx, outputDir, prefix, core.MasterPlaylistName, func(done, total int) {
+		progress.report(ctx, stageUploading, 90+float64(done)/float64(total)*10)
+	})
+	if err != nil {
+		logger.Error(err, "Upload HLS output failed: %s", outputDir)
+		return err
+	}
+	// 6. Cập nhật DB trỏ tới master playlist và preview
+	media.Path = path.Join(prefix, core.MasterPlaylistName)
+	media.Renditions = toRenditionRecords(renditions)
+	if previews != nil {
+		s.applyPreviews(media, outputDir, previews)
+	}
+	if err := s.setStatus(media, types.MediaStatusReady, ""); err != nil {
+		return err
+	}
+	logger.Info("Media ready: %d (%s)", media.ID, media.Path)
 	return nil
 }
+
+// applyPreviews lưu poster như một variant của media (phục vụ qua endpoint ảnh) cùng WebVTT thumbnail track,
+// placeholder được tính từ poster
+func (s *MediaService) applyPreviews(media *database.Media, outputDir string, p *core.Previews) {
+	posterPath := filepath.Join(outputDir, p.Poster)
+	st, err := os.Stat(posterPath)
+	if err != nil {
+		logger.Warn("Poster missing: media %d: %v", media.ID, err)
+		return
+	}
+	media.Variants = database.JSONList[database.ImageVariant]{{
+		Name:   posterVariant,
+		Path:   path.Join(hlsPrefix(media.ID), p.Poster),
+		Width:  p.PosterWidth,
+		Height: p.PosterHeight,
+		Size:   st.Size(),
+		Format: "jpeg",
+	}}
+	media.PreviewTrack = p.Track
+	s.applyPlaceholder(media, posterPath)
+}
+
+func (s *MediaService) setStatus(media *database.Media, status types.MediaStatus, reason string) error {
+	media.Status = string(status)
+	media.FailureReason = reason
+	media.UpdatedAt = time.Now().Unix()
+	if err := s.Repo.Update(media); err != nil {
+		logger.Error(err, "DB update media status failed: %d -> %s", media.ID, status)
+		return err
+	}
+	return nil
+}
+
+// markFailed lưu lý do lỗi lên media, lỗi khi lưu chỉ được log
+func (s *MediaService) markFailed(media *database.Media, cause error) {
+	logger.Error(cause, "Media %d failed", media.ID)
+	_ = s.setStatus(media, types.MediaStatusFailed, cause.Error())
+	s.publish(context.Background(), events.Event{Type: events.TypeFailed, MediaID: media.ID, Error: cause.Error()})
+}
+
+// SubscribeEvents đăng ký nhận event tiến độ của media cho tới khi ctx bị hủy.
+// Event đầu tiên trả về là trạng thái hiện tại trong DB, để client kết nối muộn không bị lỡ kết quả.
+func (s *MediaService) SubscribeEvents(ctx context.Context, mediaID uint) (events.Event, <-chan events.Event, error) {
+	// subscribe trước khi đọc DB để không lỡ event phát ra giữa hai bước
+	ch, err := s.Events.Subscribe(ctx, mediaID)
+	if err != nil {
+		return events.Event{}, nil, err
+	}
+	media, err := s.Repo.FindByID(mediaID)
+	if err != nil {
+		return events.Event{}, nil, err
+	}
+	current := events.Event{Type: events.TypeProgress, MediaID: media.ID, Stage: media.Status}
+	switch types.MediaStatus(media.Status) {
+	case types.MediaStatusReady:
+		current = events.Event{Type: events.TypeReady, MediaID: media.ID, Stage: stageDone, Progress: 100, URL: ToMediaDTO(media).URL}
+	case types.MediaStatusFailed:
+		current = events.Event{Type: events.TypeFailed, MediaID: media.ID, Error: media.FailureReason}
+	}
+	return current, ch, nil
+}
+
+// publish phát event tiến độ của media, lỗi chỉ được log
+func (s *MediaService) publish(ctx context.Context, e events.Event) {
+	if s.Events == nil {
+		return
+	}
+	if err := s.Events.Publish(ctx, e); err != nil {
+		logger.Error(err, "Publish event failed for media %d", e.MediaID)
+	}
+}
+
+func originalPrefix(mediaID uint) string {
+	return fmt.Sprintf("original/%d", mediaID)
+}
+
+func hlsPrefix(mediaID uint) string {
+	return fmt.Sprintf("hls/%d", mediaID)
+}
+
+// validateVideo kiểm tra file nguồn có video stream và nằm trong giới hạn cho phép
+func validateVideo(info *core.MediaInfo) error {
+	if info.VideoCodec == "" {
+		return fmt.Errorf("%w: no video stream", ErrInvalidMedia)
+	}
+	if info.Duration <= 0 {
+		return fmt.Errorf("%w: unknown duration", ErrInvalidMedia)
+	}
+	if max := config.Settings.MaxVideoDuration; max > 0 && info.Duration > float64(max) {
+		return fmt.Errorf("%w: duration %.0fs exceeds limit %ds", ErrInvalidMedia, info.Duration, max)
+	}
+	return nil
+}
+
+// applyMediaInfo copy metadata đã probe vào bản ghi Media
+func applyMediaInfo(m *database.Media, info *core.MediaInfo) {
+	m.Container = info.Container
+	m.Duration = info.Duration
+	m.Width = info.Width
+	m.Height = info.Height
+	m.FrameRate = info.FrameRate
+	m.VideoCodec = info.VideoCodec
+	m.AudioCodec = info.AudioCodec
+	m.Bitrate = info.Bitrate
+	m.AudioChannels = info.AudioChannels
+	m.Rotation = info.Rotation
+}
+
+// uploadDir upload song song mọi file trong localDir lên storage dưới prefix, onFile (có thể nil) nhận số file đã xong.
+// File last (tương đối với localDir, vd master playlist) được upload sau cùng để client không thấy output dở dang.
+// Nếu có file lỗi, các upload còn lại bị hủy và mọi object của thư mục bị xóa
+// để prefix không bao giờ ở trạng thái dở dang.
+func (s *MediaService) uploadDir(ctx context.Context, localDir, prefix, last string, onFile func(done, total int)) error {
+	var files []string
+	lastFile := filepath.Join(localDir, last)
+	err := filepath.WalkDir(localDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && (last == "" || p != lastFile) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		sem      = make(chan struct{}, config.Settings.UploadConcurrency)
+		objects  = make([]string, 0, len(files))
+		done     atomic.Int32
+		total    = len(files)
+	)
+	if last != "" {
+		total++
+	}
+	for _, file := range files {
+		rel, err := filepath.Rel(localDir, file)
+		if err != nil {
+			return err
+		}
+		objectName := path.Join(prefix, filepath.ToSlash(rel))
+		objects = append(objects, objectName)
+
+		wg.Add(1)
+		go func(objectName, file string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				// file không được upload, ghi lỗi để không upload last trỏ tới object còn thiếu
+				once.Do(func() { firstErr = context.Cause(ctx) })
+				return
+			}
+			if err := s.Storage.PutFile(ctx, objectName, file, derivedPutOptions(objectName)); err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("upload %s: %w", objectName, err)
+					cancel()
+				})
+				return
+			}
+			if onFile != nil {
+				onFile(int(done.Add(1)), total)
+			}
+		}(objectName, file)
+	}
+	wg.Wait()
+	if firstErr == nil && ctx.Err() != nil {
+		// backend bỏ qua ctx (local, memory) vẫn upload xong, nhưng caller đã hủy thì không coi là thành công
+		firstErr = context.Cause(ctx)
+	}
+	if firstErr == nil && last != "" {
+		lastObject := path.Join(prefix, filepath.ToSlash(last))
+		objects = append(objects, lastObject)
+		if err := s.Storage.PutFile(ctx, lastObject, lastFile, derivedPutOptions(lastObject)); err != nil {
+			firstErr = fmt.Errorf("upload %s: %w", lastObject, err)
+		} else if onFile != nil {
+			onFile(total, total)
+		}
+	}
+	if firstErr == nil {
+		logger.Info("Uploaded %d files to storage prefix: %s", len(objects), prefix)
+		return nil
+	}
+	// Dọn object đã upload bằng context riêng vì ctx gốc có thể đã bị hủy
+	if err := s.Storage.Delete(context.WithoutCancel(ctx), objects...); err != nil {
+		logger.Error(err, "Cleanup partially uploaded prefix failed: %s", prefix)
+	}
+	return firstErr
+}
+
+// derivedPutOptions gắn Cache-Control cho output sinh ra từ media: segment, sprite, variant không bao giờ đổi,
+// playlist/WebVTT cache ngắn. Có tác dụng khi client đọc thẳng từ MinIO qua presigned URL.
+func derivedPutOptions(objectName string) storage.PutOptions {
+	switch path.Ext(objectName) {
+	case ".m3u8", ".vtt":
+		return storage.PutOptions{CacheControl: playlistCacheControl}
+	}
+	return storage.PutOptions{CacheControl: immutableCacheControl}
+}
@@ -0,0 +1,36 @@
+package v1
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"photo-go/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUploadDirCanceled tests that a canceled upload fails, skips the last file and leaves nothing behind
+func TestUploadDirCanceled(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"master.m3u8", "720p.m3u8", "720p_000.ts", "720p_001.ts"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o600))
+	}
+	st := storage.NewMemoryStorage()
+	s := &MediaService{Storage: st}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := s.uploadDir(ctx, dir, "hls/1", "master.m3u8", nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	objects, err := st.List(context.Background(), "hls/1/")
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+
+	require.NoError(t, s.uploadDir(context.Background(), dir, "hls/1", "master.m3u8", nil))
+	objects, err = st.List(context.Background(), "hls/1/")
+	require.NoError(t, err)
+	assert.Len(t, objects, 4)
+}
@@ -0,0 +1,121 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"photo-go/config"
+	"photo-go/internal/core"
+	"photo-go/pkg/storage"
+	"photo-go/pkg/types"
+	"strings"
+	"time"
+)
+
+// Cách trả segment trong playlist: qua API hoặc presigned URL thẳng tới MinIO
+const (
+	DeliveryProxy   = "proxy"
+	DeliveryPresign = "presign"
+)
+
+// ErrStreamNotFound trả về khi media chưa ready, không phải video hoặc file HLS không tồn tại
+var ErrStreamNotFound = errors.New("stream not found")
+
+// maxPlaylistSize giới hạn playlist đọc vào bộ nhớ để rewrite
+const maxPlaylistSize = 4 << 20
+
+// StreamFile là một file HLS (playlist đã rewrite hoặc segment) sẵn sàng trả về client
+type StreamFile struct {
+	Body        io.ReadCloser
+	Size        int64
+	ContentType string
+	ETag        string
+	Playlist    bool // playlist hoặc WebVTT đã rewrite, chứa URL có thể hết hạn nên không cache lâu
+}
+
+// ValidateDelivery kiểm tra giá trị HLS_DELIVERY
+func ValidateDelivery(mode string) error {
+	switch mode {
+	case DeliveryProxy, DeliveryPresign:
+		return nil
+	}
+	return fmt.Errorf("unknown HLS delivery %q", mode)
+}
+
+// OpenStreamFile mở file name (tương đối với thư mục HLS) của media.
+// Playlist và WebVTT thumbnail track được rewrite để mọi URI trỏ về API, hoặc segment/sprite trỏ tới presigned URL khi HLS_DELIVERY=presign.
+func (s *MediaService) OpenStreamFile(ctx context.Context, mediaID uint, name string) (*StreamFile, error) {
+	media, err := s.Repo.FindByID(mediaID)
+	if err != nil {
+		return nil, err
+	}
+	if media.Type != string(types.MediaTypeVideo) || media.Status != string(types.MediaStatusReady) || media.DeletedAt != 0 {
+		return nil, ErrStreamNotFound
+	}
+	// Clean theo gốc "/" để loại bỏ "..", không thể thoát khỏi prefix của media
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = core.MasterPlaylistName
+	}
+	body, info, err := s.Storage.Get(ctx, path.Join(hlsPrefix(mediaID), name), nil)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			return nil, ErrStreamNotFound
+		}
+		return nil, err
+	}
+	file := &StreamFile{
+		Body:        body,
+		Size:        info.Size,
+		ContentType: storage.ContentTypeFor(name),
+		ETag:        info.ETag,
+		Playlist:    path.Ext(name) == ".m3u8" || path.Ext(name) == ".vtt",
+	}
+	if !file.Playlist {
+		return file, nil
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, maxPlaylistSize))
+	if err != nil {
+		return nil, err
+	}
+	dir := path.Dir(name)
+	rewrite := core.RewritePlaylist
+	if path.Ext(name) == ".vtt" {
+		// sprite trong thumbnail track cũng phải trỏ tới presigned URL khi bucket private
+		rewrite = core.RewriteThumbnailTrack
+	}
+	data, err = rewrite(data, func(uri string) (string, error) {
+		return s.streamURI(ctx, mediaID, dir, uri)
+	})
+	if err != nil {
+		return nil, err
+	}
+	file.Body = io.NopCloser(strings.NewReader(string(data)))
+	file.Size = int64(len(data))
+	return file, nil
+}
+
+// streamURI chuyển URI tương đối trong playlist thành URL qua API hoặc presigned URL
+func (s *MediaService) streamURI(ctx context.Context, mediaID uint, dir, uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.IsAbs() || strings.HasPrefix(uri, "/") {
+		return uri, nil
+	}
+	rel := path.Join(dir, u.Path)
+	// playlist con luôn đi qua API để segment bên trong cũng được rewrite
+	if config.Settings.HLSDelivery != DeliveryPresign || path.Ext(rel) == ".m3u8" {
+		return streamFileURL(mediaID, rel), nil
+	}
+	expiry := time.Duration(config.Settings.HLSPresignExpiry) * time.Second
+	signed, err := s.Storage.Presign(ctx, http.MethodGet, path.Join(hlsPrefix(mediaID), rel), expiry, nil)
+	if errors.Is(err, storage.ErrUnsupported) {
+		// backend không có URL công khai (local/memory): vẫn trả qua API
+		return streamFileURL(mediaID, rel), nil
+	}
+	return signed, err
+}
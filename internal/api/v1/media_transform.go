@@ -0,0 +1,230 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"photo-go/config"
+	"photo-go/internal/core"
+	"photo-go/internal/database"
+	"photo-go/pkg/logger"
+	"photo-go/pkg/storage"
+	"photo-go/pkg/types"
+	"slices"
+	"strconv"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrInvalidSignature trả về khi transform URL thiếu hoặc sai chữ ký HMAC
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// transformParams là các query param của transform, dùng để phân biệt với ?variant=
+var transformParams = []string{"w", "h", "fit", "fmt", "q"}
+
+// transformGroup gộp các request cùng một derivative chưa có trong cache thành một lần xử lý
+var transformGroup singleflight.Group
+
+// ImageTransform là tham số transform ảnh đã chuẩn hóa
+type ImageTransform struct {
+	Width   int
+	Height  int
+	Fit     core.FitMode
+	Format  string
+	Quality int // 0 với png
+}
+
+// HasTransformParams trả về true nếu query có tham số transform
+func HasTransformParams(q url.Values) bool {
+	for _, k := range transformParams {
+		if q.Has(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseImageTransform đọc và validate tham số transform theo giới hạn trong settings,
+// điền giá trị mặc định để cùng một ảnh output luôn có cùng cache key
+func ParseImageTransform(q url.Values, srcFormat string) (ImageTransform, error) {
+	cfg := config.Settings
+	t := ImageTransform{Fit: core.FitMode(q.Get("fit")), Format: q.Get("fmt")}
+	var err error
+	for _, p := range []struct {
+		name string
+		dst  *int
+		max  int
+	}{{"w", &t.Width, cfg.ImageTransformMaxSize}, {"h", &t.Height, cfg.ImageTransformMaxSize}, {"q", &t.Quality, 100}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		if *p.dst, err = strconv.Atoi(v); err != nil || *p.dst < 1 || *p.dst > p.max {
+			return t, fmt.Errorf("%w: %s must be between 1 and %d", ErrInvalidQuery, p.name, p.max)
+		}
+	}
+	switch t.Fit {
+	case "":
+		t.Fit = core.FitInside
+	case core.FitInside, core.FitContain, core.FitCover, core.FitFill:
+	default:
+		return t, fmt.Errorf("%w: unknown fit %q", ErrInvalidQuery, t.Fit)
+	}
+	if t.Format == "jpg" {
+		t.Format = "jpeg"
+	}
+	if t.Format == "" {
+		t.Format = "jpeg"
+		if srcFormat == "png" || srcFormat == "gif" {
+			t.Format = "png"
+		}
+	}
+	if !slices.Contains(cfg.ImageTransformFormats, t.Format) || !slices.Contains(core.ImageFormats, t.Format) {
+		return t, fmt.Errorf("%w: format %q is not allowed", ErrInvalidQuery, t.Format)
+	}
+	if t.Format == "png" {
+		t.Quality = 0
+	} else if t.Quality == 0 {
+		t.Quality = 85
+	}
+	return t, nil
+}
+
+// Key là tên chuẩn hóa của transform, dùng làm tên object cache
+func (t ImageTransform) Key() string {
+	return fmt.Sprintf("w%d_h%d_%s_q%d.%s", t.Width, t.Height, t.Fit, t.Quality, t.Format)
+}
+
+// SignImageQuery ký query transform (không gồm sig) cho media, client gắn kết quả vào ?sig=
+func SignImageQuery(key string, mediaID uint, q url.Values) string {
+	q = cloneWithout(q, "sig")
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "%d:%s", mediaID, q.Encode())
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyImageQuery kiểm tra ?sig= khi IMAGE_TRANSFORM_SIGNING_KEY được cấu hình
+func VerifyImageQuery(mediaID uint, q url.Values) error {
+	key := config.Settings.ImageTransformSigningKey
+	if key == "" {
+		return nil
+	}
+	sig := q.Get("sig")
+	if sig == "" || !hmac.Equal([]byte(sig), []byte(SignImageQuery(key, mediaID, q))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func cloneWithout(q url.Values, drop string) url.Values {
+	out := url.Values{}
+	for k, v := range q {
+		if k != drop {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// TransformImage trả về derivative theo t, lấy từ cache trên storage nếu đã có,
+// nếu chưa thì sinh từ variant nhỏ nhất đủ lớn (hoặc ảnh gốc) rồi lưu cache
+func (s *MediaService) TransformImage(ctx context.Context, mediaID uint, t ImageTransform) (*StreamFile, bool, error) {
+	media, err := s.Repo.FindByID(mediaID)
+	if err != nil {
+		return nil, false, err
+	}
+	if media.Type != string(types.MediaTypeImage) || media.Status != string(types.MediaStatusReady) || media.DeletedAt != 0 {
+		return nil, false, ErrStreamNotFound
+	}
+	cacheObject := path.Join(imageCachePrefix(media.ID), t.Key())
+	body, info, err := s.Storage.Get(ctx, cacheObject, nil)
+	if err == nil {
+		return &StreamFile{Body: body, Size: info.Size, ContentType: storage.ContentTypeFor(cacheObject), ETag: info.ETag}, true, nil
+	}
+	if !storage.IsNotFound(err) {
+		return nil, false, err
+	}
+
+	v, err, _ := transformGroup.Do(cacheObject, func() (any, error) {
+		return s.renderTransform(context.WithoutCancel(ctx), media, t, cacheObject)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	data := v.([]byte)
+	return &StreamFile{
+		Body:        io.NopCloser(bytes.NewReader(data)),
+		Size:        int64(len(data)),
+		ContentType: storage.ContentTypeFor(cacheObject),
+	}, false, nil
+}
+
+func (s *MediaService) renderTransform(ctx context.Context, media *database.Media, t ImageTransform, cacheObject string) ([]byte, error) {
+	source, size := transformSource(media, t)
+	ws, err := s.Workspaces.Acquire(ctx, fmt.Sprintf("transform-%d", media.ID), size*imageSpaceFactor)
+	if err != nil {
+		return nil, err
+	}
+	defer ws.Release()
+
+	input := ws.Path("source" + path.Ext(source))
+	if err := storage.GetFile(ctx, s.Storage, source, input); err != nil {
+		return nil, err
+	}
+	output := ws.Path(t.Key())
+	// nguồn là ảnh gốc đã lọc metadata theo policy hoặc variant, nên giữ metadata của nguồn là an toàn
+	_, err = s.ImageCore.ProcessImage(ctx, input, output, core.ImageOptions{
+		Width:        t.Width,
+		Height:       t.Height,
+		Fit:          t.Fit,
+		AutoOrient:   true,
+		KeepMetadata: core.MetadataPolicy(config.Settings.ImageMetadataPolicy) != core.MetadataStripAll,
+		Format:       t.Format,
+		Quality:      t.Quality,
+	})
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Storage.Put(ctx, cacheObject, bytes.NewReader(data), int64(len(data)), derivedPutOptions(cacheObject)); err != nil {
+		// vẫn trả ảnh cho client, lần sau sinh lại
+		logger.Error(err, "Cache transformed image failed: %s", cacheObject)
+	}
+	logger.Info("Transformed image cached: %s", cacheObject)
+	return data, nil
+}
+
+// transformSource chọn variant nhỏ nhất vẫn phủ được khung yêu cầu, không có thì dùng ảnh gốc.
+// Variant giữ tỉ lệ ảnh gốc nên kết quả giống hệt khi sinh từ ảnh gốc.
+func transformSource(media *database.Media, t ImageTransform) (string, int64) {
+	best, bestSize := media.OriginalPath, media.OriginalSize
+	if t.Width == 0 && t.Height == 0 {
+		// chỉ đổi định dạng: giữ nguyên độ phân giải gốc
+		return best, bestSize
+	}
+	bestPixels := int64(media.Width) * int64(media.Height)
+	for _, v := range media.Variants {
+		if v.Width < t.Width || v.Height < t.Height {
+			continue
+		}
+		if pixels := int64(v.Width) * int64(v.Height); pixels < bestPixels {
+			best, bestSize, bestPixels = v.Path, v.Size, pixels
+		}
+	}
+	return best, bestSize
+}
+
+func imageCachePrefix(mediaID uint) string {
+	return path.Join(imagePrefix(mediaID), "cache")
+}
@@ -0,0 +1,49 @@
+package v1
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"photo-go/config"
+	"photo-go/internal/core"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseImageTransform tests defaults, normalization and range checks
+func TestParseImageTransform(t *testing.T) {
+	q, _ := url.ParseQuery("w=400&h=300&fit=cover&fmt=jpg")
+	tr, err := ParseImageTransform(q, "jpeg")
+	require.NoError(t, err)
+	assert.Equal(t, ImageTransform{Width: 400, Height: 300, Fit: core.FitCover, Format: "jpeg", Quality: 85}, tr)
+	assert.Equal(t, "w400_h300_cover_q85.jpeg", tr.Key())
+
+	q, _ = url.ParseQuery("w=100&q=50")
+	tr, err = ParseImageTransform(q, "png")
+	require.NoError(t, err)
+	assert.Equal(t, "w100_h0_inside_q0.png", tr.Key())
+
+	for _, raw := range []string{"w=0", "w=100000", "fit=stretch", "fmt=bmp", "q=101"} {
+		q, _ = url.ParseQuery(raw)
+		_, err = ParseImageTransform(q, "jpeg")
+		assert.True(t, errors.Is(err, ErrInvalidQuery), raw)
+	}
+}
+
+// TestVerifyImageQuery tests HMAC signing independent of parameter order
+func TestVerifyImageQuery(t *testing.T) {
+	old := config.Settings.ImageTransformSigningKey
+	config.Settings.ImageTransformSigningKey = "secret"
+	defer func() { config.Settings.ImageTransformSigningKey = old }()
+
+	q, _ := url.ParseQuery("w=400&fmt=webp")
+	q.Set("sig", SignImageQuery("secret", 7, q))
+	reordered, _ := url.ParseQuery("fmt=webp&sig=" + q.Get("sig") + "&w=400")
+	assert.NoError(t, VerifyImageQuery(7, reordered))
+
+	assert.ErrorIs(t, VerifyImageQuery(8, q), ErrInvalidSignature)
+	q.Set("w", "800")
+	assert.ErrorIs(t, VerifyImageQuery(7, q), ErrInvalidSignature)
+}
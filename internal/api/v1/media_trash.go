@@ -0,0 +1,194 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"photo-go/config"
+	"photo-go/internal/database"
+	"photo-go/internal/jobs"
+	"photo-go/pkg/logger"
+	"photo-go/pkg/storage"
+	"photo-go/pkg/types"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrMediaPurging trả về khi restore media đã được enqueue purge
+	ErrMediaPurging = errors.New("media is being purged")
+	// ErrMediaDeleted là lý do lỗi của job xử lý chạy sau khi media đã bị enqueue purge
+	ErrMediaDeleted = errors.New("media was deleted")
+)
+
+const (
+	trashPurgeBatch = 100
+	// purgeRequeueAfter: media đã enqueue purge mà vẫn còn sau khoảng này (job lỗi hết lượt retry
+	// hoặc bị mất) được enqueue lại, purge chạy tiếp từ chỗ dừng
+	purgeRequeueAfter = 24 * time.Hour
+)
+
+// DeleteMedia chuyển media vào thùng rác: ẩn khỏi danh sách và không phục vụ nữa,
+// restore được cho tới khi hết TRASH_RETENTION. Xóa lại media đã trong thùng rác không đổi thời điểm xóa.
+func (s *MediaService) DeleteMedia(ctx context.Context, id uint) (*database.Media, error) {
+	media, err := s.Repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if media.DeletedAt != 0 {
+		return media, nil
+	}
+	now := time.Now().Unix()
+	trashed, err := s.Repo.Trash(id, now)
+	if err != nil {
+		logger.Error(err, "DB trash media failed: %d", id)
+		return nil, err
+	}
+	if !trashed {
+		// request khác vừa xóa cùng media
+		return s.Repo.FindByID(id)
+	}
+	media.DeletedAt, media.PurgeQueuedAt, media.UpdatedAt = now, 0, now
+	logger.Info("Media %d moved to trash", id)
+	return media, nil
+}
+
+// RestoreMedia đưa media ra khỏi thùng rác, ErrMediaPurging nếu job purge đã được enqueue
+func (s *MediaService) RestoreMedia(ctx context.Context, id uint) (*database.Media, error) {
+	media, err := s.Repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if media.DeletedAt == 0 {
+		return media, nil
+	}
+	restored, err := s.Repo.Restore(id)
+	if err != nil {
+		logger.Error(err, "DB restore media failed: %d", id)
+		return nil, err
+	}
+	if !restored {
+		if media, err = s.Repo.FindByID(id); err != nil {
+			return nil, err
+		}
+		if media.DeletedAt != 0 {
+			return nil, ErrMediaPurging
+		}
+		return media, nil
+	}
+	media.DeletedAt, media.UpdatedAt = 0, time.Now().Unix()
+	logger.Info("Media %d restored from trash", id)
+	return media, nil
+}
+
+// PurgeAt là thời điểm media trong thùng rác bị purge, 0 nếu media chưa bị xóa
+func PurgeAt(m *database.Media) int64 {
+	if m.DeletedAt == 0 {
+		return 0
+	}
+	return m.DeletedAt + int64(config.Settings.TrashRetention)
+}
+
+// EnqueueExpiredTrash enqueue job purge cho media nằm trong thùng rác quá TRASH_RETENTION, trả về số job đã enqueue
+func (s *MediaService) EnqueueExpiredTrash(ctx context.Context) (int, error) {
+	now := time.Now()
+	deletedBefore := now.Add(-time.Duration(config.Settings.TrashRetention) * time.Second).Unix()
+	queuedBefore := now.Add(-purgeRequeueAfter).Unix()
+	enqueued := 0
+	for {
+		ms, err := s.Repo.ListPurgeable(deletedBefore, queuedBefore, trashPurgeBatch)
+		if err != nil {
+			return enqueued, err
+		}
+		for i := range ms {
+			// claim trước khi enqueue: từ đây media không restore được nữa
+			claimed, err := s.Repo.ClaimPurge(ms[i].ID, queuedBefore, now.Unix())
+			if err != nil {
+				return enqueued, err
+			}
+			if !claimed {
+				continue
+			}
+			if _, err := s.Queue.Enqueue(ctx, jobs.TypePurgeMedia, ms[i].ID); err != nil {
+				// queue đầy thì dừng lượt này, media được thử lại ở lượt sau
+				_ = s.Repo.ReleasePurge(ms[i].ID)
+				return enqueued, err
+			}
+			enqueued++
+		}
+		if len(ms) < trashPurgeBatch {
+			return enqueued, nil
+		}
+	}
+}
+
+// RunTrashPurge gọi EnqueueExpiredTrash theo chu kỳ cho tới khi ctx bị hủy
+func (s *MediaService) RunTrashPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := s.EnqueueExpiredTrash(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Error(err, "Enqueue trash purge failed")
+		} else if n > 0 {
+			logger.Info("Enqueued purge for %d trashed media", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeMediaJob là handler của jobs.TypePurgeMedia: xóa mọi object của media rồi mới xóa bản ghi,
+// nên job lỗi giữa chừng chạy lại sẽ xóa nốt phần còn lại. Media đã purge hoặc đã restore thì bỏ qua.
+func (s *MediaService) PurgeMediaJob(ctx context.Context, job *jobs.Job) error {
+	media, err := s.Repo.FindByID(job.MediaID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Info("Media %d already purged", job.MediaID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if media.DeletedAt == 0 {
+		logger.Info("Media %d was restored, skip purge", media.ID)
+		return nil
+	}
+	if media.Status == string(types.MediaStatusProcessing) {
+		// job xử lý đang chạy sẽ còn upload object, chờ nó kết thúc rồi retry
+		return fmt.Errorf("media %d is still processing", media.ID)
+	}
+	n, err := purgeMediaObjects(ctx, s.Storage, media.ID)
+	if err != nil {
+		logger.Error(err, "Purge media objects failed: %d", media.ID)
+		return err
+	}
+	if err := s.Repo.Delete(media.ID); err != nil {
+		logger.Error(err, "DB delete media failed: %d", media.ID)
+		return err
+	}
+	logger.Info("Media %d purged, %d objects removed", media.ID, n)
+	return nil
+}
+
+// mediaPrefixes là mọi prefix chứa object của media: file gốc, HLS (rendition, segment, poster, sprite)
+// và ảnh (gốc, variant, cache transform). Có "/" cuối để "hls/1/" không khớp "hls/10/".
+func mediaPrefixes(mediaID uint) []string {
+	return []string{originalPrefix(mediaID) + "/", hlsPrefix(mediaID) + "/", imagePrefix(mediaID) + "/"}
+}
+
+// purgeMediaObjects xóa mọi object của media, trả về số object đã xóa
+func purgeMediaObjects(ctx context.Context, st storage.Storage, mediaID uint) (int, error) {
+	total := 0
+	for _, prefix := range mediaPrefixes(mediaID) {
+		n, err := st.DeletePrefix(ctx, prefix)
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
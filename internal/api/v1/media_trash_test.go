@@ -0,0 +1,47 @@
+package v1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"photo-go/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPurgeMediaObjects tests that purging a media removes every object it owns and nothing of other media
+func TestPurgeMediaObjects(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemoryStorage()
+	for _, name := range []string{
+		"original/1/clip.mp4",
+		"hls/1/master.m3u8",
+		"hls/1/720p/seg_000.ts",
+		"img/1/poster.jpg",
+		"img/1/cache/w400.webp",
+		"hls/10/master.m3u8",
+		"img/10/original.jpg",
+		"uploads/1/00000000000000000000",
+	} {
+		require.NoError(t, st.Put(ctx, name, strings.NewReader("x"), 1, storage.PutOptions{}))
+	}
+
+	n, err := purgeMediaObjects(ctx, st, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+
+	objects, err := st.List(ctx, "")
+	require.NoError(t, err)
+	keys := make([]string, 0, len(objects))
+	for _, o := range objects {
+		keys = append(keys, o.Key)
+	}
+	assert.Equal(t, []string{"hls/10/master.m3u8", "img/10/original.jpg", "uploads/1/00000000000000000000"}, keys)
+
+	// chạy lại sau khi đã xóa hết vẫn thành công
+	n, err = purgeMediaObjects(ctx, st, 1)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
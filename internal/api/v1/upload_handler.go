@@ -0,0 +1,224 @@
+package v1
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"photo-go/config"
+	"photo-go/internal/core"
+	"photo-go/internal/jobs"
+	"photo-go/internal/workspace"
+	"photo-go/pkg/logger"
+	"photo-go/pkg/storage"
+	"photo-go/pkg/types"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// Hằng số của giao thức tus 1.0 (https://tus.io/protocols/resumable-upload)
+const (
+	tusVersion             = "1.0.0"
+	tusExtensions          = "creation,termination,checksum,expiration"
+	tusOffsetContentType   = "application/offset+octet-stream"
+	statusChecksumMismatch = 460
+)
+
+// TusHeaders là các header tus mà client gửi lên hoặc cần đọc được qua CORS
+var TusHeaders = []string{
+	"Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size", "Tus-Checksum-Algorithm",
+	"Upload-Length", "Upload-Offset", "Upload-Metadata", "Upload-Checksum", "Upload-Expires",
+	"Location", "X-Media-Id",
+}
+
+// UploadHandler phục vụ upload resumable theo giao thức tus dưới /uploads
+type UploadHandler struct {
+	Service *UploadService
+}
+
+func NewUploadHandler(s *UploadService) *UploadHandler {
+	return &UploadHandler{Service: s}
+}
+
+func (h *UploadHandler) RegisterRoutes(r fiber.Router) {
+	r.Options("/uploads", h.Options)
+	r.Options("/uploads/:id", h.Options)
+	r.Post("/uploads", h.Create)
+	r.Head("/uploads/:id", h.Head)
+	r.Patch("/uploads/:id", h.Patch)
+	r.Delete("/uploads/:id", h.Delete)
+	r.Post("/media/uploads/presign", h.Presign)
+	r.Post("/media/uploads/:id/complete", h.Complete)
+}
+
+// Options trả về các tính năng tus server hỗ trợ
+func (h *UploadHandler) Options(c fiber.Ctx) error {
+	c.Set("Tus-Resumable", tusVersion)
+	c.Set("Tus-Version", tusVersion)
+	c.Set("Tus-Extension", tusExtensions)
+	c.Set("Tus-Max-Size", strconv.FormatInt(config.Settings.UploadMaxSize, 10))
+	c.Set("Tus-Checksum-Algorithm", strings.Join(ChecksumAlgorithms, ","))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Create tạo upload mới từ Upload-Length và Upload-Metadata (tus creation)
+func (h *UploadHandler) Create(c fiber.Ctx) error {
+	if !tusResumable(c) {
+		return c.Status(fiber.StatusPreconditionFailed).SendString("Unsupported Tus-Resumable version")
+	}
+	length, err := strconv.ParseInt(c.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return c.Status(400).SendString("Invalid Upload-Length")
+	}
+	u, err := h.Service.Create(length, c.Get("Upload-Metadata"))
+	if err != nil {
+		return h.error(c, "", err)
+	}
+	c.Set("Location", c.BaseURL()+c.Path()+"/"+u.ID)
+	c.Set("Upload-Expires", httpTime(u.ExpiresAt))
+	return c.SendStatus(fiber.StatusCreated)
+}
+
+// Head trả về offset hiện tại để client biết cần gửi tiếp từ đâu
+func (h *UploadHandler) Head(c fiber.Ctx) error {
+	if !tusResumable(c) {
+		return c.Status(fiber.StatusPreconditionFailed).SendString("Unsupported Tus-Resumable version")
+	}
+	u, err := h.Service.Get(c.Params("id"))
+	if err != nil {
+		return h.error(c, c.Params("id"), err)
+	}
+	c.Set("Cache-Control", "no-store")
+	c.Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	c.Set("Upload-Length", strconv.FormatInt(u.Length, 10))
+	c.Set("Upload-Expires", httpTime(u.ExpiresAt))
+	if u.Metadata != "" {
+		c.Set("Upload-Metadata", u.Metadata)
+	}
+	if u.MediaID != 0 {
+		c.Set("X-Media-Id", strconv.FormatUint(uint64(u.MediaID), 10))
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// Patch ghi một chunk tại Upload-Offset. Chunk cuối chuyển file vào pipeline xử lý,
+// media id trả về qua header X-Media-Id.
+func (h *UploadHandler) Patch(c fiber.Ctx) error {
+	if !tusResumable(c) {
+		return c.Status(fiber.StatusPreconditionFailed).SendString("Unsupported Tus-Resumable version")
+	}
+	if c.Get("Content-Type") != tusOffsetContentType {
+		return c.Status(fiber.StatusUnsupportedMediaType).SendString("Content-Type must be " + tusOffsetContentType)
+	}
+	offset, err := strconv.ParseInt(c.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return c.Status(400).SendString("Invalid Upload-Offset")
+	}
+	var body io.Reader = c.Request().BodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+	id := c.Params("id")
+	u, err := h.Service.WriteChunk(c, id, offset, body, int64(c.Request().Header.ContentLength()), c.Get("Upload-Checksum"))
+	if u != nil {
+		// kể cả khi lỗi, offset cho client biết phần đã lưu
+		c.Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+		c.Set("Upload-Expires", httpTime(u.ExpiresAt))
+		if u.MediaID != 0 {
+			c.Set("X-Media-Id", strconv.FormatUint(uint64(u.MediaID), 10))
+		}
+	}
+	if err != nil {
+		return h.error(c, id, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Delete hủy upload và xóa các chunk đã nhận (tus termination)
+func (h *UploadHandler) Delete(c fiber.Ctx) error {
+	if !tusResumable(c) {
+		return c.Status(fiber.StatusPreconditionFailed).SendString("Unsupported Tus-Resumable version")
+	}
+	if err := h.Service.Terminate(c, c.Params("id")); err != nil {
+		return h.error(c, c.Params("id"), err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Presign cấp URL để client PUT file thẳng lên MinIO, file lớn được chia thành các part
+func (h *UploadHandler) Presign(c fiber.Ctx) error {
+	var req types.PresignUploadRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(400).SendString("Invalid request body")
+	}
+	dto, err := h.Service.Presign(c, req)
+	if err != nil {
+		return h.error(c, "", err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(dto)
+}
+
+// Complete kiểm object đã upload qua presigned URL rồi chuyển vào pipeline xử lý như POST /media/upload
+func (h *UploadHandler) Complete(c fiber.Ctx) error {
+	var req types.CompleteUploadRequest
+	if len(c.Body()) > 0 {
+		if err := c.Bind().JSON(&req); err != nil {
+			return c.Status(400).SendString("Invalid request body")
+		}
+	}
+	id := c.Params("id")
+	media, job, err := h.Service.CompletePresigned(c, id, req.Parts)
+	if err != nil {
+		return h.error(c, id, err)
+	}
+	return sendIngested(c, media, job)
+}
+
+func (h *UploadHandler) error(c fiber.Ctx, id string, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).SendString("Upload not found")
+	case errors.Is(err, ErrUploadExpired):
+		return c.Status(fiber.StatusGone).SendString("Upload expired")
+	case errors.Is(err, ErrUploadOffset), errors.Is(err, ErrUploadIncomplete):
+		return c.Status(fiber.StatusConflict).SendString(err.Error())
+	case errors.Is(err, ErrUploadLocked):
+		return c.Status(fiber.StatusLocked).SendString(err.Error())
+	case errors.Is(err, ErrUploadTooLarge):
+		return c.Status(fiber.StatusRequestEntityTooLarge).SendString(err.Error())
+	case errors.Is(err, ErrChecksumMismatch):
+		return c.Status(statusChecksumMismatch).SendString("Checksum mismatch")
+	case errors.Is(err, ErrUnsupportedChecksum), errors.Is(err, ErrInvalidQuery):
+		return c.Status(400).SendString(err.Error())
+	case errors.Is(err, core.ErrUnsupportedMedia):
+		return c.Status(fiber.StatusUnsupportedMediaType).SendString("Unsupported media format")
+	case errors.Is(err, ErrInvalidMedia), errors.Is(err, ErrUploadMismatch):
+		return c.Status(fiber.StatusUnprocessableEntity).SendString(err.Error())
+	case errors.Is(err, workspace.ErrInsufficientSpace):
+		return c.Status(fiber.StatusInsufficientStorage).SendString("Insufficient storage")
+	case errors.Is(err, jobs.ErrQueueFull):
+		return c.Status(fiber.StatusServiceUnavailable).SendString("Processing queue is full")
+	case errors.Is(err, storage.ErrUnsupported):
+		return c.Status(fiber.StatusNotImplemented).SendString("Presigned upload is not supported by the storage backend")
+	}
+	logger.Error(err, "Upload %s failed", id)
+	return c.Status(500).SendString(err.Error())
+}
+
+// tusResumable kiểm tra client khai báo đúng phiên bản tus, sai thì caller trả 412 kèm Tus-Version
+func tusResumable(c fiber.Ctx) bool {
+	c.Set("Tus-Resumable", tusVersion)
+	if c.Get("Tus-Resumable") != tusVersion {
+		c.Set("Tus-Version", tusVersion)
+		return false
+	}
+	return true
+}
+
+func httpTime(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format(http.TimeFormat)
+}
@@ -0,0 +1,311 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"photo-go/config"
+	"photo-go/internal/core"
+	"photo-go/internal/database"
+	"photo-go/internal/jobs"
+	"photo-go/internal/workspace"
+	"photo-go/pkg/logger"
+	"photo-go/pkg/storage"
+	"photo-go/pkg/types"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Lỗi khi complete presigned upload
+var (
+	ErrUploadIncomplete = errors.New("upload incomplete")
+	ErrUploadMismatch   = errors.New("uploaded object does not match")
+)
+
+// Giới hạn multipart của S3/MinIO
+const (
+	minPartSize = 5 << 20
+	maxParts    = 10000
+)
+
+// checksumHeader được ký vào presigned PUT để MinIO tự kiểm sha256 khi ghi
+const checksumHeader = "X-Amz-Checksum-Sha256"
+
+// Presign tạo presigned upload: file nhỏ hơn PRESIGN_PART_SIZE nhận một URL PUT,
+// lớn hơn thì mở multipart upload và nhận URL cho từng part
+func (s *UploadService) Presign(ctx context.Context, req types.PresignUploadRequest) (*types.PresignUploadDTO, error) {
+	if req.Filename == "" || req.Size <= 0 {
+		return nil, fmt.Errorf("%w: filename and size are required", ErrInvalidQuery)
+	}
+	if req.Size > config.Settings.UploadMaxSize {
+		return nil, fmt.Errorf("%w: %d > %d", ErrUploadTooLarge, req.Size, config.Settings.UploadMaxSize)
+	}
+	var sum []byte
+	if req.SHA256 != "" {
+		var err error
+		if sum, err = hex.DecodeString(req.SHA256); err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("%w: sha256 must be 64 hex characters", ErrInvalidQuery)
+		}
+	}
+	id, err := newUploadID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	expiry := time.Duration(config.Settings.PresignUploadExpiry) * time.Second
+	u := &database.PresignedUpload{
+		ID:         id,
+		ObjectName: path.Join(incomingRoot, id, workspace.SanitizeFilename(req.Filename)),
+		Filename:   req.Filename,
+		Size:       req.Size,
+		SHA256:     strings.ToLower(req.SHA256),
+		Tags:       req.Tags,
+		ExpiresAt:  now.Add(expiry).Unix(),
+		CreatedAt:  now.Unix(),
+		UpdatedAt:  now.Unix(),
+	}
+	dto := &types.PresignUploadDTO{ID: id, ExpiresAt: u.ExpiresAt}
+
+	partSize, parts := planParts(req.Size, config.Settings.PresignPartSize)
+	if parts == 1 {
+		headers := http.Header{}
+		if sum != nil {
+			headers.Set(checksumHeader, base64.StdEncoding.EncodeToString(sum))
+		}
+		if dto.URL, err = s.Media.Storage.Presign(ctx, http.MethodPut, u.ObjectName, expiry, headers); err != nil {
+			return nil, err
+		}
+		if len(headers) > 0 {
+			dto.Headers = map[string]string{checksumHeader: headers.Get(checksumHeader)}
+		}
+	} else {
+		mp, ok := s.Media.Storage.(storage.Multipart)
+		if !ok {
+			return nil, fmt.Errorf("%w: multipart upload", storage.ErrUnsupported)
+		}
+		if u.MultipartID, err = mp.NewMultipartUpload(ctx, u.ObjectName); err != nil {
+			return nil, err
+		}
+		dto.PartSize = partSize
+		for i := 1; i <= parts; i++ {
+			url, err := mp.PresignPart(ctx, u.ObjectName, u.MultipartID, i, expiry)
+			if err != nil {
+				s.abortPresigned(context.WithoutCancel(ctx), u)
+				return nil, err
+			}
+			dto.Parts = append(dto.Parts, types.PresignPartDTO{PartNumber: i, URL: url})
+		}
+	}
+	if err := s.Presigns.Create(u); err != nil {
+		logger.Error(err, "DB create presigned upload failed")
+		s.abortPresigned(context.WithoutCancel(ctx), u)
+		return nil, err
+	}
+	logger.Info("Presigned upload created: %s (%d bytes, %d parts)", id, req.Size, parts)
+	return dto, nil
+}
+
+// CompletePresigned kiểm object client đã upload (tồn tại, đúng size, đúng sha256 nếu có) rồi chuyển vào pipeline:
+// video được copy phía server và enqueue, ảnh được tải về xử lý ngay. Gọi lại sau khi đã xong trả về media cũ.
+// Cả request giữ khóa của upload: complete đồng thời nhận ErrUploadLocked thay vì ingest cùng object hai lần.
+func (s *UploadService) CompletePresigned(ctx context.Context, id string, parts []types.CompletedPartDTO) (*database.Media, *jobs.Job, error) {
+	unlock, err := s.Presigns.Lock(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+	u, err := s.Presigns.FindByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.MediaID != 0 {
+		media, err := s.Media.GetMedia(u.MediaID)
+		return media, nil, err
+	}
+	if u.ExpiresAt < time.Now().Unix() {
+		return nil, nil, ErrUploadExpired
+	}
+	if u.MultipartID != "" {
+		if err := s.completeMultipart(ctx, u, parts); err != nil {
+			return nil, nil, err
+		}
+	}
+	info, err := s.Media.Storage.Stat(ctx, u.ObjectName)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			return nil, nil, fmt.Errorf("%w: object not found", ErrUploadIncomplete)
+		}
+		return nil, nil, err
+	}
+	if info.Size != u.Size {
+		return nil, nil, fmt.Errorf("%w: size %d, expected %d", ErrUploadMismatch, info.Size, u.Size)
+	}
+	if u.SHA256 != "" {
+		if err := s.verifySHA256(ctx, u, info); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	media, job, err := s.ingestObject(ctx, u)
+	if err != nil {
+		return nil, nil, err
+	}
+	ok, err := s.Presigns.ClaimMedia(u.ID, media.ID)
+	if err != nil {
+		logger.Error(err, "DB update presigned upload failed: %s", u.ID)
+		return nil, nil, err
+	}
+	if !ok {
+		// chỉ xảy ra khi mất khóa giữa chừng (vd: rớt connection), media vừa tạo là bản trùng
+		logger.Warn("Presigned upload %s was completed concurrently, media %d is a duplicate", u.ID, media.ID)
+		return nil, nil, fmt.Errorf("%w: presigned upload %s was completed concurrently", ErrUploadLocked, u.ID)
+	}
+	if err := s.Media.Storage.Delete(context.WithoutCancel(ctx), u.ObjectName); err != nil {
+		logger.Error(err, "Remove incoming object failed: %s", u.ObjectName)
+	}
+	logger.Info("Presigned upload %s completed: media %d", u.ID, media.ID)
+	return media, job, nil
+}
+
+func (s *UploadService) completeMultipart(ctx context.Context, u *database.PresignedUpload, parts []types.CompletedPartDTO) error {
+	if len(parts) == 0 {
+		return fmt.Errorf("%w: parts are required for a multipart upload", ErrInvalidQuery)
+	}
+	mp, ok := s.Media.Storage.(storage.Multipart)
+	if !ok {
+		return fmt.Errorf("%w: multipart upload", storage.ErrUnsupported)
+	}
+	completed := make([]storage.Part, 0, len(parts))
+	for _, p := range parts {
+		completed = append(completed, storage.Part{PartNumber: p.PartNumber, ETag: p.ETag})
+	}
+	sort.Slice(completed, func(i, j int) bool { return completed[i].PartNumber < completed[j].PartNumber })
+	if err := mp.CompleteMultipartUpload(ctx, u.ObjectName, u.MultipartID, completed); err != nil {
+		if errors.Is(err, storage.ErrInvalidPart) {
+			return fmt.Errorf("%w: %v", ErrUploadIncomplete, err)
+		}
+		return err
+	}
+	// multipart đã ghép thành object, lần complete sau (vd: lỗi ingest) chỉ cần kiểm object
+	u.MultipartID = ""
+	u.UpdatedAt = time.Now().Unix()
+	return s.Presigns.Update(u)
+}
+
+// verifySHA256 so sha256 client khai báo với object. PUT một lần đã được MinIO kiểm qua header ký sẵn và
+// trả lại checksum khi stat; multipart chỉ có checksum composite nên phải đọc lại object để tính.
+func (s *UploadService) verifySHA256(ctx context.Context, u *database.PresignedUpload, info storage.ObjectInfo) error {
+	want, err := hex.DecodeString(u.SHA256)
+	if err != nil {
+		return err
+	}
+	if c := info.ChecksumSHA256; c != "" && !strings.Contains(c, "-") {
+		if got, err := base64.StdEncoding.DecodeString(c); err == nil {
+			if !bytes.Equal(got, want) {
+				return fmt.Errorf("%w: sha256 mismatch", ErrUploadMismatch)
+			}
+			return nil
+		}
+	}
+	body, _, err := s.Media.Storage.Get(ctx, u.ObjectName, nil)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), want) {
+		return fmt.Errorf("%w: sha256 mismatch", ErrUploadMismatch)
+	}
+	return nil
+}
+
+// ingestObject nhận diện định dạng từ đầu object rồi chuyển vào pipeline như MediaService.Ingest
+func (s *UploadService) ingestObject(ctx context.Context, u *database.PresignedUpload) (*database.Media, *jobs.Job, error) {
+	body, _, err := s.Media.Storage.Get(ctx, u.ObjectName, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	format, err := core.DetectReader(body)
+	body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	logger.Info("Detected %s (%s): %s", format.Type, format.Name, u.Filename)
+	// presigned URL còn hạn nên object có thể bị PUT đè sau khi complete đã stat,
+	// size được kiểm lại trên bản thực sự được ingest
+	if format.Type == types.MediaTypeVideo {
+		return s.Media.IngestVideoObject(ctx, u.ObjectName, u.Filename, u.Size, u.Tags)
+	}
+	// ảnh cần decode local, tải về workspace như upload thường
+	ws, err := s.Workspaces.Acquire(ctx, "presign-"+u.ID, u.Size)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer ws.Release()
+	filePath := ws.Path(u.Filename)
+	if err := storage.GetFile(ctx, s.Media.Storage, u.ObjectName, filePath); err != nil {
+		return nil, nil, err
+	}
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if fi.Size() != u.Size {
+		return nil, nil, fmt.Errorf("%w: size %d, expected %d", ErrUploadMismatch, fi.Size(), u.Size)
+	}
+	media, err := s.Media.UploadAndProcessImage(ctx, filePath, u.Size, format, u.Tags)
+	return media, nil, err
+}
+
+// purgeExpiredPresigns hủy multipart upload và xóa object tạm của presigned upload đã hết hạn
+func (s *UploadService) purgeExpiredPresigns(ctx context.Context) (int, error) {
+	purged := 0
+	for {
+		us, err := s.Presigns.ListExpired(time.Now().Unix(), uploadCleanupBatch)
+		if err != nil {
+			return purged, err
+		}
+		for i := range us {
+			if err := s.abortPresigned(ctx, &us[i]); err != nil {
+				return purged, err
+			}
+			if err := s.Presigns.Delete(us[i].ID); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+		if len(us) < uploadCleanupBatch {
+			return purged, nil
+		}
+	}
+}
+
+func (s *UploadService) abortPresigned(ctx context.Context, u *database.PresignedUpload) error {
+	if mp, ok := s.Media.Storage.(storage.Multipart); ok && u.MultipartID != "" {
+		if err := mp.AbortMultipartUpload(ctx, u.ObjectName, u.MultipartID); err != nil {
+			return err
+		}
+	}
+	return s.Media.Storage.Delete(ctx, u.ObjectName)
+}
+
+// planParts chọn kích thước part (>= partSize, đủ lớn để không vượt maxParts) và số part cho size byte
+func planParts(size, partSize int64) (int64, int) {
+	partSize = max(partSize, minPartSize, (size+maxParts-1)/maxParts)
+	if size <= partSize {
+		return size, 1
+	}
+	return partSize, int((size + partSize - 1) / partSize)
+}
@@ -0,0 +1,171 @@
+package v1
+
+import (
+	"context"
+	"database/sql/driver"
+	"photo-go/internal/database"
+	"photo-go/pkg/logger"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Số đầu của khóa pg_advisory_lock(int, int) theo loại upload,
+// khóa hai số không trùng không gian với migration lock
+const (
+	uploadLockClass  int32 = 1
+	presignLockClass int32 = 2
+)
+
+type UploadRepository interface {
+	Create(u *database.Upload) error
+	Update(u *database.Upload) error
+	FindByID(id string) (*database.Upload, error)
+	// Advance chuyển offset from -> to và gia hạn upload, false khi offset đã bị request khác ghi trước
+	Advance(id string, from, to, expiresAt int64) (bool, error)
+	// ClaimMedia gán media cho upload, false khi upload đã có media
+	ClaimMedia(id string, mediaID uint) (bool, error)
+	// Lock giữ khóa riêng của upload tới khi gọi unlock, ErrUploadLocked khi request khác đang giữ
+	Lock(ctx context.Context, id string) (unlock func(), err error)
+	Delete(id string) error
+	// ListExpired trả về tối đa limit upload hết hạn trước now
+	ListExpired(now int64, limit int) ([]database.Upload, error)
+}
+
+// PresignRepository lưu các presigned upload đang chờ complete
+type PresignRepository interface {
+	Create(u *database.PresignedUpload) error
+	Update(u *database.PresignedUpload) error
+	FindByID(id string) (*database.PresignedUpload, error)
+	// ClaimMedia gán media cho presigned upload, false khi đã có media
+	ClaimMedia(id string, mediaID uint) (bool, error)
+	// Lock giữ khóa riêng của presigned upload tới khi gọi unlock, ErrUploadLocked khi request khác đang giữ
+	Lock(ctx context.Context, id string) (unlock func(), err error)
+	Delete(id string) error
+	// ListExpired trả về tối đa limit presigned upload hết hạn trước now
+	ListExpired(now int64, limit int) ([]database.PresignedUpload, error)
+}
+
+type GormUploadRepository struct {
+	DB *gorm.DB
+}
+
+func NewGormUploadRepository(db *gorm.DB) *GormUploadRepository {
+	return &GormUploadRepository{DB: db}
+}
+
+func (r *GormUploadRepository) Create(u *database.Upload) error {
+	return r.DB.Create(u).Error
+}
+
+func (r *GormUploadRepository) Update(u *database.Upload) error {
+	return r.DB.Save(u).Error
+}
+
+func (r *GormUploadRepository) FindByID(id string) (*database.Upload, error) {
+	var u database.Upload
+	err := r.DB.First(&u, "id = ?", id).Error
+	return &u, err
+}
+
+func (r *GormUploadRepository) Advance(id string, from, to, expiresAt int64) (bool, error) {
+	res := r.DB.Model(&database.Upload{}).
+		Where("id = ? AND upload_offset = ?", id, from).
+		Updates(map[string]any{"upload_offset": to, "expires_at": expiresAt, "updated_at": time.Now().Unix()})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *GormUploadRepository) ClaimMedia(id string, mediaID uint) (bool, error) {
+	res := r.DB.Model(&database.Upload{}).
+		Where("id = ? AND media_id = 0", id).
+		Updates(map[string]any{"media_id": mediaID, "updated_at": time.Now().Unix()})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *GormUploadRepository) Lock(ctx context.Context, id string) (func(), error) {
+	return advisoryLock(ctx, r.DB, uploadLockClass, id)
+}
+
+func (r *GormUploadRepository) Delete(id string) error {
+	return r.DB.Delete(&database.Upload{}, "id = ?", id).Error
+}
+
+func (r *GormUploadRepository) ListExpired(now int64, limit int) ([]database.Upload, error) {
+	var us []database.Upload
+	err := r.DB.Where("expires_at < ?", now).Order("expires_at").Limit(limit).Find(&us).Error
+	return us, err
+}
+
+type GormPresignRepository struct {
+	DB *gorm.DB
+}
+
+func NewGormPresignRepository(db *gorm.DB) *GormPresignRepository {
+	return &GormPresignRepository{DB: db}
+}
+
+func (r *GormPresignRepository) Create(u *database.PresignedUpload) error {
+	return r.DB.Create(u).Error
+}
+
+func (r *GormPresignRepository) Update(u *database.PresignedUpload) error {
+	return r.DB.Save(u).Error
+}
+
+func (r *GormPresignRepository) FindByID(id string) (*database.PresignedUpload, error) {
+	var u database.PresignedUpload
+	err := r.DB.First(&u, "id = ?", id).Error
+	return &u, err
+}
+
+func (r *GormPresignRepository) ClaimMedia(id string, mediaID uint) (bool, error) {
+	res := r.DB.Model(&database.PresignedUpload{}).
+		Where("id = ? AND media_id = 0", id).
+		Updates(map[string]any{"media_id": mediaID, "updated_at": time.Now().Unix()})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *GormPresignRepository) Lock(ctx context.Context, id string) (func(), error) {
+	return advisoryLock(ctx, r.DB, presignLockClass, id)
+}
+
+func (r *GormPresignRepository) Delete(id string) error {
+	return r.DB.Delete(&database.PresignedUpload{}, "id = ?", id).Error
+}
+
+func (r *GormPresignRepository) ListExpired(now int64, limit int) ([]database.PresignedUpload, error) {
+	var us []database.PresignedUpload
+	err := r.DB.Where("expires_at < ?", now).Order("expires_at").Limit(limit).Find(&us).Error
+	return us, err
+}
+
+// advisoryLock lấy pg_try_advisory_lock(class, hashtext(id)) trên một connection riêng, advisory lock gắn với
+// session nên connection được giữ tới khi unlock. Hai id trùng hash chỉ làm request sau nhận ErrUploadLocked.
+func advisoryLock(ctx context.Context, db *gorm.DB, class int32, id string) (func(), error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ok bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1, hashtext($2))", class, id).Scan(&ok); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !ok {
+		conn.Close()
+		return nil, ErrUploadLocked
+	}
+	return func() {
+		// unlock cả khi ctx đã bị hủy, nếu không connection trả về pool vẫn giữ lock
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1, hashtext($2))", class, id); err != nil {
+			logger.Error(err, "Release upload lock failed: %s", id)
+			// bỏ hẳn connection thay vì trả về pool khi chưa chắc đã unlock
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}, nil
+}
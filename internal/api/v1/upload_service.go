@@ -0,0 +1,377 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"photo-go/config"
+	"photo-go/internal/core"
+	"photo-go/internal/database"
+	"photo-go/internal/workspace"
+	"photo-go/pkg/logger"
+	"strings"
+	"time"
+)
+
+// Lỗi của upload tus, handler map sang status code theo spec
+var (
+	ErrUploadExpired       = errors.New("upload expired")
+	ErrUploadOffset        = errors.New("upload offset mismatch")
+	ErrUploadLocked        = errors.New("upload is being written by another request")
+	ErrUploadTooLarge      = errors.New("upload exceeds maximum size")
+	ErrChecksumMismatch    = errors.New("checksum mismatch")
+	ErrUnsupportedChecksum = errors.New("unsupported checksum algorithm")
+)
+
+// ChecksumAlgorithms là các thuật toán hỗ trợ trong header Upload-Checksum
+var ChecksumAlgorithms = []string{"sha1", "sha256", "md5"}
+
+// uploadCleanupBatch là số upload hết hạn được dọn mỗi lần
+const uploadCleanupBatch = 100
+
+// UploadService lưu upload tus theo chunk, cấp presigned URL để client upload thẳng lên MinIO
+// và chuyển file hoàn chỉnh vào pipeline xử lý media
+type UploadService struct {
+	Repo       UploadRepository
+	Presigns   PresignRepository
+	Store      ChunkStore
+	Media      *MediaService
+	Workspaces *workspace.Manager
+}
+
+func NewUploadService(r UploadRepository, p PresignRepository, s ChunkStore, m *MediaService, w *workspace.Manager) *UploadService {
+	return &UploadService{Repo: r, Presigns: p, Store: s, Media: m, Workspaces: w}
+}
+
+// Create tạo upload mới dài length byte với Upload-Metadata nguyên gốc
+func (s *UploadService) Create(length int64, metadata string) (*database.Upload, error) {
+	if length > config.Settings.UploadMaxSize {
+		return nil, fmt.Errorf("%w: %d > %d", ErrUploadTooLarge, length, config.Settings.UploadMaxSize)
+	}
+	if _, err := ParseUploadMetadata(metadata); err != nil {
+		return nil, err
+	}
+	id, err := newUploadID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	u := &database.Upload{
+		ID:        id,
+		Length:    length,
+		Metadata:  metadata,
+		ExpiresAt: uploadExpiry(now),
+		CreatedAt: now.Unix(),
+		UpdatedAt: now.Unix(),
+	}
+	if err := s.Repo.Create(u); err != nil {
+		logger.Error(err, "DB create upload failed")
+		return nil, err
+	}
+	logger.Info("Upload created: %s (%d bytes)", u.ID, length)
+	return u, nil
+}
+
+// Get trả về upload, gorm.ErrRecordNotFound nếu không tồn tại hoặc ErrUploadExpired nếu đã hết hạn
+func (s *UploadService) Get(id string) (*database.Upload, error) {
+	u, err := s.Repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if u.ExpiresAt < time.Now().Unix() {
+		return u, ErrUploadExpired
+	}
+	return u, nil
+}
+
+// WriteChunk ghi body tiếp nối tại offset. Khi có checksum ("<alg> <base64>"), chunk chỉ được lưu nếu khớp;
+// không có checksum thì phần đã nhận trước khi mất kết nối vẫn được lưu để client resume.
+// Chunk cuối cùng ghép file và gọi MediaService.Ingest, media tạo ra được gán vào Upload.MediaID.
+// Cả request giữ khóa của upload: PATCH đồng thời nhận ErrUploadLocked thay vì ghi đè chunk của nhau.
+func (s *UploadService) WriteChunk(ctx context.Context, id string, offset int64, body io.Reader, size int64, checksum string) (*database.Upload, error) {
+	unlock, err := s.Repo.Lock(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	u, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != u.Offset {
+		return u, fmt.Errorf("%w: got %d, expected %d", ErrUploadOffset, offset, u.Offset)
+	}
+	remaining := u.Length - u.Offset
+	if size > remaining {
+		return u, fmt.Errorf("%w: chunk of %d bytes exceeds remaining %d", ErrUploadTooLarge, size, remaining)
+	}
+	h, expected, err := parseChecksum(checksum)
+	if err != nil {
+		return u, err
+	}
+	if remaining > 0 {
+		reserve := remaining
+		if size >= 0 {
+			reserve = size
+		}
+		n, copyErr := s.receiveChunk(ctx, u, body, reserve, h, expected)
+		if n > 0 {
+			if err := s.advance(u, n); err != nil {
+				return u, err
+			}
+		}
+		if copyErr != nil {
+			return u, copyErr
+		}
+	}
+	if u.Offset == u.Length && u.MediaID == 0 {
+		return u, s.finish(ctx, u)
+	}
+	return u, nil
+}
+
+// receiveChunk nhận body vào file tạm trong workspace rồi chuyển vào ChunkStore, trả về số byte đã lưu
+func (s *UploadService) receiveChunk(ctx context.Context, u *database.Upload, body io.Reader, reserve int64, h hash.Hash, expected []byte) (int64, error) {
+	ws, err := s.Workspaces.Acquire(ctx, "chunk-"+u.ID, reserve)
+	if err != nil {
+		return 0, err
+	}
+	defer ws.Release()
+	tmp := ws.Path("chunk")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	var w io.Writer = f
+	if h != nil {
+		w = io.MultiWriter(f, h)
+	}
+	// đọc dư 1 byte để phát hiện body dài hơn phần còn lại
+	n, copyErr := io.Copy(w, io.LimitReader(body, u.Length-u.Offset+1))
+	if cerr := f.Close(); copyErr == nil {
+		copyErr = cerr
+	}
+	switch {
+	case n > u.Length-u.Offset:
+		return 0, fmt.Errorf("%w: body exceeds remaining %d bytes", ErrUploadTooLarge, u.Length-u.Offset)
+	case h != nil && copyErr != nil:
+		// không kiểm tra được checksum của chunk dở, bỏ cả chunk
+		return 0, copyErr
+	case h != nil && !bytes.Equal(h.Sum(nil), expected):
+		return 0, ErrChecksumMismatch
+	case n == 0:
+		return 0, copyErr
+	}
+	if copyErr != nil {
+		logger.Warn("Upload %s: chunk interrupted after %d bytes: %v", u.ID, n, copyErr)
+	}
+	if err := s.Store.PutChunk(ctx, u.ID, u.Offset, tmp); err != nil {
+		return 0, err
+	}
+	return n, copyErr
+}
+
+func (s *UploadService) advance(u *database.Upload, n int64) error {
+	expiresAt := uploadExpiry(time.Now())
+	ok, err := s.Repo.Advance(u.ID, u.Offset, u.Offset+n, expiresAt)
+	if err != nil {
+		logger.Error(err, "DB advance upload failed: %s", u.ID)
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: upload %s was written concurrently", ErrUploadOffset, u.ID)
+	}
+	u.Offset += n
+	u.ExpiresAt = expiresAt
+	return nil
+}
+
+// finish ghép các chunk vào workspace và chuyển vào pipeline như upload thường.
+// File không phải media hợp lệ thì upload bị xóa luôn; lỗi khác giữ chunk để client gọi lại PATCH cuối.
+func (s *UploadService) finish(ctx context.Context, u *database.Upload) error {
+	meta, _ := ParseUploadMetadata(u.Metadata)
+	filename := meta["filename"]
+	if filename == "" {
+		filename = "upload"
+	}
+	var tags []string
+	if t := meta["tags"]; t != "" {
+		tags = strings.Split(t, ",")
+	}
+
+	ws, err := s.Workspaces.Acquire(ctx, "upload-"+u.ID, u.Length)
+	if err != nil {
+		return err
+	}
+	defer ws.Release()
+	filePath := ws.Path(filename)
+	if err := s.Store.Assemble(ctx, u.ID, u.Length, filePath); err != nil {
+		logger.Error(err, "Assemble upload failed: %s", u.ID)
+		return err
+	}
+	media, job, err := s.Media.Ingest(ctx, filePath, filename, u.Length, tags)
+	if err != nil {
+		if errors.Is(err, core.ErrUnsupportedMedia) || errors.Is(err, ErrInvalidMedia) {
+			s.discard(context.WithoutCancel(ctx), u)
+		}
+		return err
+	}
+	ok, err := s.Repo.ClaimMedia(u.ID, media.ID)
+	if err != nil {
+		logger.Error(err, "DB update upload failed: %s", u.ID)
+		return err
+	}
+	if !ok {
+		// chỉ xảy ra khi mất khóa giữa chừng (vd: rớt connection), media vừa tạo là bản trùng
+		logger.Warn("Upload %s was completed concurrently, media %d is a duplicate", u.ID, media.ID)
+		return fmt.Errorf("%w: upload %s was completed concurrently", ErrUploadOffset, u.ID)
+	}
+	u.MediaID = media.ID
+	// bản ghi giữ tới khi hết hạn để HEAD vẫn trả về media id, chunk thì không cần nữa
+	if err := s.Store.Delete(context.WithoutCancel(ctx), u.ID); err != nil {
+		logger.Error(err, "Delete upload chunks failed: %s", u.ID)
+	}
+	if job != nil {
+		logger.Info("Upload %s completed: media %d, job %d", u.ID, media.ID, job.ID)
+	} else {
+		logger.Info("Upload %s completed: media %d", u.ID, media.ID)
+	}
+	return nil
+}
+
+// Terminate xóa upload cùng toàn bộ chunk (tus termination)
+func (s *UploadService) Terminate(ctx context.Context, id string) error {
+	unlock, err := s.Repo.Lock(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	u, err := s.Repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.Store.Delete(ctx, u.ID); err != nil {
+		return err
+	}
+	if err := s.Repo.Delete(u.ID); err != nil {
+		return err
+	}
+	logger.Info("Upload terminated: %s", u.ID)
+	return nil
+}
+
+// PurgeExpired xóa các upload tus và presigned upload đã hết hạn cùng dữ liệu tạm của chúng, trả về số upload đã xóa
+func (s *UploadService) PurgeExpired(ctx context.Context) (int, error) {
+	purged, err := s.purgeExpiredPresigns(ctx)
+	if err != nil {
+		return purged, err
+	}
+	for {
+		us, err := s.Repo.ListExpired(time.Now().Unix(), uploadCleanupBatch)
+		if err != nil {
+			return purged, err
+		}
+		for i := range us {
+			if err := s.discard(ctx, &us[i]); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+		if len(us) < uploadCleanupBatch {
+			return purged, nil
+		}
+	}
+}
+
+// RunCleanup gọi PurgeExpired theo chu kỳ cho tới khi ctx bị hủy
+func (s *UploadService) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := s.PurgeExpired(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Error(err, "Purge expired uploads failed")
+		} else if n > 0 {
+			logger.Info("Purged %d expired uploads", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *UploadService) discard(ctx context.Context, u *database.Upload) error {
+	if err := s.Store.Delete(ctx, u.ID); err != nil {
+		logger.Error(err, "Delete upload chunks failed: %s", u.ID)
+		return err
+	}
+	return s.Repo.Delete(u.ID)
+}
+
+func newUploadID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func uploadExpiry(now time.Time) int64 {
+	return now.Add(time.Duration(config.Settings.UploadExpiry) * time.Second).Unix()
+}
+
+// ParseUploadMetadata đọc header Upload-Metadata: các cặp "key base64(value)" ngăn cách bởi dấu phẩy, value có thể bỏ trống
+func ParseUploadMetadata(header string) (map[string]string, error) {
+	out := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return out, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, fmt.Errorf("%w: empty metadata key", ErrInvalidQuery)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: metadata %q is not base64", ErrInvalidQuery, key)
+		}
+		out[key] = string(decoded)
+	}
+	return out, nil
+}
+
+// parseChecksum đọc header Upload-Checksum, trả về hash rỗng khi header không có
+func parseChecksum(header string) (hash.Hash, []byte, error) {
+	if header == "" {
+		return nil, nil, nil
+	}
+	alg, value, ok := strings.Cut(header, " ")
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: malformed Upload-Checksum", ErrInvalidQuery)
+	}
+	sum, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: checksum is not base64", ErrInvalidQuery)
+	}
+	switch alg {
+	case "sha1":
+		return sha1.New(), sum, nil
+	case "sha256":
+		return sha256.New(), sum, nil
+	case "md5":
+		return md5.New(), sum, nil
+	}
+	return nil, nil, fmt.Errorf("%w: %s", ErrUnsupportedChecksum, alg)
+}
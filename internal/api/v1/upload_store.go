@@ -0,0 +1,208 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"photo-go/pkg/storage"
+	"strconv"
+)
+
+// ChunkStore giữ dữ liệu của upload tus cho tới khi ghép thành file hoàn chỉnh
+type ChunkStore interface {
+	// PutChunk lưu file local làm chunk bắt đầu tại offset, ghi lại cùng offset sẽ ghi đè
+	PutChunk(ctx context.Context, uploadID string, offset int64, filePath string) error
+	// Assemble ghép length byte đầu tiên của upload ra file dst
+	Assemble(ctx context.Context, uploadID string, length int64, dst string) error
+	// Delete xóa mọi chunk của upload, không lỗi khi upload không còn chunk
+	Delete(ctx context.Context, uploadID string) error
+}
+
+// Backend của ChunkStore theo UPLOAD_STORE
+const (
+	UploadStoreStorage = "storage"
+	UploadStoreLocal   = "local"
+)
+
+// StorageChunkStore lưu mỗi chunk thành một object uploads/<id>/<offset> trên Storage chính
+// vì object MinIO không ghi nối được, chunk dùng chung được giữa nhiều replica
+type StorageChunkStore struct {
+	Storage storage.Storage
+}
+
+func NewStorageChunkStore(st storage.Storage) *StorageChunkStore {
+	return &StorageChunkStore{Storage: st}
+}
+
+// Prefix của object tạm trên Storage: chunk tus và file presigned upload chờ complete
+const (
+	chunkRoot    = "uploads/"
+	incomingRoot = "incoming/"
+)
+
+// TempPrefixes là các prefix chỉ chứa object tạm, bucket lifecycle có thể tự xóa khi job dọn dẹp không chạy
+var TempPrefixes = []string{chunkRoot, incomingRoot}
+
+func chunkPrefix(uploadID string) string {
+	return chunkRoot + uploadID + "/"
+}
+
+func (s *StorageChunkStore) PutChunk(ctx context.Context, uploadID string, offset int64, filePath string) error {
+	// offset đệm 0 để thứ tự key trùng thứ tự byte
+	return s.Storage.PutFile(ctx, fmt.Sprintf("%s%020d", chunkPrefix(uploadID), offset), filePath, storage.PutOptions{})
+}
+
+func (s *StorageChunkStore) Assemble(ctx context.Context, uploadID string, length int64, dst string) error {
+	objects, err := s.Storage.List(ctx, chunkPrefix(uploadID))
+	if err != nil {
+		return err
+	}
+	chunks := make([]uploadChunk, 0, len(objects))
+	for _, o := range objects {
+		offset, err := strconv.ParseInt(path.Base(o.Key), 10, 64)
+		if err != nil {
+			continue
+		}
+		chunks = append(chunks, uploadChunk{Key: o.Key, Offset: offset, Size: o.Size})
+	}
+	reads, err := planChunkReads(chunks, length)
+	if err != nil {
+		return fmt.Errorf("assemble upload %s: %w", uploadID, err)
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, r := range reads {
+		body, _, err := s.Storage.Get(ctx, r.Key, &storage.Range{Offset: r.Skip, Length: r.N})
+		if err != nil {
+			return err
+		}
+		_, err = io.CopyN(f, body, r.N)
+		body.Close()
+		if err != nil {
+			return fmt.Errorf("assemble upload %s: %s: %w", uploadID, r.Key, err)
+		}
+	}
+	return f.Close()
+}
+
+func (s *StorageChunkStore) Delete(ctx context.Context, uploadID string) error {
+	_, err := s.Storage.DeletePrefix(ctx, chunkPrefix(uploadID))
+	return err
+}
+
+// uploadChunk là một chunk đã lưu, uploadChunkRead là phần của chunk cần đọc khi ghép
+type (
+	uploadChunk struct {
+		Key    string
+		Offset int64
+		Size   int64
+	}
+	uploadChunkRead struct {
+		Key  string
+		Skip int64 // bỏ qua đầu chunk đã được chunk trước phủ
+		N    int64
+	}
+)
+
+// planChunkReads chọn phần cần đọc của từng chunk (đã sắp theo offset) để ghép liên tục đủ length byte.
+// Chunk bị ghi lại ở offset nhỏ hơn (client gửi lại sau khi mất kết nối) có thể chồng lên nhau.
+func planChunkReads(chunks []uploadChunk, length int64) ([]uploadChunkRead, error) {
+	var (
+		pos   int64
+		reads []uploadChunkRead
+	)
+	for _, c := range chunks {
+		if pos >= length {
+			break
+		}
+		if c.Offset > pos {
+			return nil, fmt.Errorf("missing bytes %d-%d", pos, c.Offset)
+		}
+		end := min(c.Offset+c.Size, length)
+		if end <= pos {
+			continue
+		}
+		reads = append(reads, uploadChunkRead{Key: c.Key, Skip: pos - c.Offset, N: end - pos})
+		pos = end
+	}
+	if pos < length {
+		return nil, fmt.Errorf("missing bytes %d-%d", pos, length)
+	}
+	return reads, nil
+}
+
+// LocalChunkStore ghi mọi chunk vào một file <dir>/<id>.part theo đúng offset
+type LocalChunkStore struct {
+	Dir string
+}
+
+func NewLocalChunkStore(dir string) (*LocalChunkStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create upload dir: %w", err)
+	}
+	return &LocalChunkStore{Dir: dir}, nil
+}
+
+func (s *LocalChunkStore) partPath(uploadID string) string {
+	return filepath.Join(s.Dir, filepath.Base(uploadID)+".part")
+}
+
+func (s *LocalChunkStore) PutChunk(ctx context.Context, uploadID string, offset int64, filePath string) error {
+	src, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(s.partPath(uploadID), os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(io.NewOffsetWriter(dst, offset), src)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (s *LocalChunkStore) Assemble(ctx context.Context, uploadID string, length int64, dst string) error {
+	part := s.partPath(uploadID)
+	st, err := os.Stat(part)
+	if err != nil {
+		return err
+	}
+	if st.Size() < length {
+		return fmt.Errorf("assemble upload %s: missing bytes %d-%d", uploadID, st.Size(), length)
+	}
+	// hard link tránh copy file lớn, file .part vẫn còn cho tới Delete để có thể ghép lại khi ingest lỗi
+	if st.Size() == length && os.Link(part, dst) == nil {
+		return nil
+	}
+	src, err := os.Open(part)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(f, src, length)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (s *LocalChunkStore) Delete(ctx context.Context, uploadID string) error {
+	if err := os.Remove(s.partPath(uploadID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package v1
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// TestParseUploadMetadata tests decoding of the tus Upload-Metadata header
+func TestParseUploadMetadata(t *testing.T) {
+	meta, err := ParseUploadMetadata("filename Y2xpcC5tcDQ=, tags YSxi,is_private")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"filename": "clip.mp4", "tags": "a,b", "is_private": ""}, meta)
+
+	meta, err = ParseUploadMetadata("")
+	require.NoError(t, err)
+	assert.Empty(t, meta)
+
+	_, err = ParseUploadMetadata("filename not-base64!")
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+}
+
+// TestParseChecksum tests the Upload-Checksum header and unsupported algorithms
+func TestParseChecksum(t *testing.T) {
+	sum := sha1.Sum([]byte("hello"))
+	h, expected, err := parseChecksum("sha1 " + base64.StdEncoding.EncodeToString(sum[:]))
+	require.NoError(t, err)
+	h.Write([]byte("hello"))
+	assert.Equal(t, expected, h.Sum(nil))
+
+	h, _, err = parseChecksum("")
+	require.NoError(t, err)
+	assert.Nil(t, h)
+
+	_, _, err = parseChecksum("crc32 AAAA")
+	assert.True(t, errors.Is(err, ErrUnsupportedChecksum))
+	_, _, err = parseChecksum("sha1")
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+}
+
+// TestPlanChunkReads tests assembling overlapping chunks re-sent after a dropped connection
+func TestPlanChunkReads(t *testing.T) {
+	reads, err := planChunkReads([]uploadChunk{
+		{Key: "0", Offset: 0, Size: 10},
+		{Key: "6", Offset: 6, Size: 10},  // gửi lại từ offset cũ, chồng 4 byte
+		{Key: "12", Offset: 12, Size: 2}, // nằm trọn trong chunk trước
+		{Key: "16", Offset: 16, Size: 10},
+	}, 20)
+	require.NoError(t, err)
+	assert.Equal(t, []uploadChunkRead{
+		{Key: "0", Skip: 0, N: 10},
+		{Key: "6", Skip: 4, N: 6},
+		{Key: "16", Skip: 0, N: 4},
+	}, reads)
+
+	_, err = planChunkReads([]uploadChunk{{Key: "0", Size: 5}, {Key: "8", Offset: 8, Size: 5}}, 13)
+	assert.Error(t, err)
+	_, err = planChunkReads([]uploadChunk{{Key: "0", Size: 5}}, 6)
+	assert.Error(t, err)
+}
+
+// TestPlanParts tests splitting presigned uploads into multipart parts within S3 limits
+func TestPlanParts(t *testing.T) {
+	size, n := planParts(10<<20, 64<<20)
+	assert.Equal(t, int64(10<<20), size)
+	assert.Equal(t, 1, n)
+
+	size, n = planParts(130<<20, 64<<20)
+	assert.Equal(t, int64(64<<20), size)
+	assert.Equal(t, 3, n)
+
+	// part size nhỏ hơn giới hạn S3 được nâng lên 5 MiB
+	size, n = planParts(12<<20, 1<<20)
+	assert.Equal(t, int64(minPartSize), size)
+	assert.Equal(t, 3, n)
+
+	// file rất lớn tăng part size để không vượt 10000 part
+	size, n = planParts(1<<40, 64<<20)
+	assert.LessOrEqual(t, n, maxParts)
+	assert.GreaterOrEqual(t, size*int64(n), int64(1<<40))
+}
+
+// TestLocalChunkStore tests writing chunks at offsets, assembling and deleting
+func TestLocalChunkStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalChunkStore(filepath.Join(dir, "uploads"))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	put := func(offset int64, data string) {
+		p := filepath.Join(dir, "chunk")
+		require.NoError(t, os.WriteFile(p, []byte(data), 0o600))
+		require.NoError(t, store.PutChunk(ctx, "abc", offset, p))
+	}
+	put(0, "hello ")
+	put(6, "wor")
+	put(6, "world") // chunk gửi lại ghi đè cùng offset
+
+	dst := filepath.Join(dir, "out")
+	require.NoError(t, store.Assemble(ctx, "abc", 11, dst))
+	data, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+	assert.Error(t, store.Assemble(ctx, "abc", 12, filepath.Join(dir, "short")))
+
+	require.NoError(t, store.Delete(ctx, "abc"))
+	require.NoError(t, store.Delete(ctx, "abc"))
+	_, err = os.Stat(store.partPath("abc"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+// TestUploadLockPostgres tests that an upload lock is exclusive until released.
+// Chỉ chạy khi có TEST_DATABASE_DSN.
+func TestUploadLockPostgres(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	repo := NewGormUploadRepository(db)
+	ctx := context.Background()
+
+	unlock, err := repo.Lock(ctx, "lock-test")
+	require.NoError(t, err)
+	_, err = repo.Lock(ctx, "lock-test")
+	assert.ErrorIs(t, err, ErrUploadLocked)
+	other, err := repo.Lock(ctx, "lock-test-other")
+	require.NoError(t, err)
+	other()
+
+	unlock()
+	unlock, err = repo.Lock(ctx, "lock-test")
+	require.NoError(t, err)
+	unlock()
+}
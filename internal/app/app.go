@@ -0,0 +1,199 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"photo-go/config"
+	"photo-go/internal/api"
+	v1 "photo-go/internal/api/v1"
+	"photo-go/internal/core"
+	"photo-go/internal/database"
+	"photo-go/internal/events"
+	"photo-go/internal/jobs"
+	"photo-go/internal/workspace"
+	"photo-go/pkg/logger"
+	"photo-go/pkg/storage"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// OpenDB kết nối Postgres theo settings
+func OpenDB() (*gorm.DB, error) {
+	cfg := config.Settings
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPass, cfg.DBName)
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Connected to DB")
+	return db, nil
+}
+
+// MigrateDB cập nhật schema lúc khởi động theo DB_MIGRATE
+func MigrateDB(ctx context.Context, db *gorm.DB) error {
+	switch mode := config.Settings.DBMigrate; mode {
+	case "versioned":
+		m, err := database.NewMigrator(db)
+		if err != nil {
+			return err
+		}
+		n, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Info("DB schema up to date, %d migrations applied", n)
+		return nil
+	case "auto":
+		logger.Warn("DB_MIGRATE=auto: using gorm AutoMigrate, for development only")
+		return database.AutoMigrate(db)
+	case "none":
+		m, err := database.NewMigrator(db)
+		if err != nil {
+			return err
+		}
+		pending, err := m.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			logger.Warn("DB schema has %d pending migrations, run 'migrate up'", pending)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown DB_MIGRATE %q", mode)
+	}
+}
+
+// NewDependencies khởi tạo storage, core processor, workspace và job queue dùng chung cho API và worker
+func NewDependencies(db *gorm.DB) (api.Dependencies, error) {
+	cfg := config.Settings
+	deps := api.Dependencies{DB: db}
+
+	// Init storage
+	var err error
+	deps.Storage, err = NewStorage()
+	if err != nil {
+		return deps, err
+	}
+	logger.Info("Storage initialized: %s", cfg.StorageBackend)
+
+	// Init core
+	ladder, err := core.NewLadder(cfg.QualityLadder)
+	if err != nil {
+		return deps, fmt.Errorf("invalid quality ladder: %w", err)
+	}
+	transcodeMode := core.TranscodeMode(cfg.TranscodeMode)
+	if err := transcodeMode.Validate(); err != nil {
+		return deps, err
+	}
+	if err := v1.ValidateDelivery(cfg.HLSDelivery); err != nil {
+		return deps, err
+	}
+	if err := core.MetadataPolicy(cfg.ImageMetadataPolicy).Validate(); err != nil {
+		return deps, err
+	}
+	deps.VideoCore = core.NewFFMPEGVideoProcessor(ladder, transcodeMode)
+	deps.ImageCore = core.NewDefaultImageProcessor()
+	deps.Prober = core.NewFFProbeProber()
+	logger.Info("Core processors initialized")
+
+	// Init workspace cho file upload/transcode tạm
+	deps.Workspaces, err = workspace.NewManager(cfg.WorkspaceRoot, cfg.WorkspaceMaxBytes, cfg.WorkspaceMinFreeBytes)
+	if err != nil {
+		return deps, fmt.Errorf("init workspace manager: %w", err)
+	}
+	if err := deps.Workspaces.PurgeStale(24 * time.Hour); err != nil {
+		logger.Error(err, "Failed to purge stale workspaces")
+	}
+	logger.Info("Workspace manager initialized: %s", cfg.WorkspaceRoot)
+
+	// Init store giữ chunk của upload resumable
+	deps.Chunks, err = NewChunkStore(deps.Storage)
+	if err != nil {
+		return deps, err
+	}
+	logger.Info("Upload chunk store initialized: %s", cfg.UploadStore)
+
+	// Init job queue
+	deps.Queue, err = NewQueue(db)
+	if err != nil {
+		return deps, err
+	}
+	logger.Info("Job queue initialized: %s", cfg.JobBackend)
+
+	// Event tiến độ chỉ phát trong process, SSE không thấy được tiến độ của worker chạy riêng
+	deps.Events = events.NewMemoryBroker()
+	return deps, nil
+}
+
+// NewStorage chọn backend lưu object theo STORAGE_BACKEND, với MinIO thì tạo bucket nếu chưa có
+func NewStorage() (storage.Storage, error) {
+	cfg := config.Settings
+	switch cfg.StorageBackend {
+	case storage.BackendMinio:
+		st, err := storage.NewMinioClient(
+			cfg.MinioEndpoint,
+			cfg.MinioAccessKey,
+			cfg.MinioSecretKey,
+			cfg.MinioBucket,
+			false, // useSSL
+		)
+		if err != nil {
+			return nil, fmt.Errorf("init minio client: %w", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		err = st.EnsureBucket(ctx, storage.BucketOptions{
+			Region:     cfg.MinioRegion,
+			Versioning: cfg.MinioVersioning,
+			Lifecycle: storage.BucketLifecycle(storage.LifecycleOptions{
+				TempPrefixes:         v1.TempPrefixes,
+				TempExpiryDays:       cfg.MinioTempExpiryDays,
+				NoncurrentExpiryDays: cfg.MinioNoncurrentExpiryDays,
+			}),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("bootstrap minio bucket: %w", err)
+		}
+		return st, nil
+	case storage.BackendLocal:
+		return storage.NewLocalStorage(cfg.StorageLocalDir)
+	case storage.BackendMemory:
+		return storage.NewMemoryStorage(), nil
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
+}
+
+// NewChunkStore chọn nơi lưu chunk upload theo UPLOAD_STORE
+func NewChunkStore(st storage.Storage) (v1.ChunkStore, error) {
+	cfg := config.Settings
+	switch cfg.UploadStore {
+	case v1.UploadStoreStorage:
+		return v1.NewStorageChunkStore(st), nil
+	case v1.UploadStoreLocal:
+		return v1.NewLocalChunkStore(cfg.UploadLocalDir)
+	}
+	return nil, fmt.Errorf("unknown upload store %q", cfg.UploadStore)
+}
+
+// NewQueue chọn backend job queue theo JOB_BACKEND
+func NewQueue(db *gorm.DB) (jobs.Queue, error) {
+	cfg := config.Settings
+	switch cfg.JobBackend {
+	case "postgres":
+		return jobs.NewPostgresQueue(db, jobs.PostgresOptions{
+			Workers:           cfg.WorkerConcurrency,
+			MaxAttempts:       cfg.JobMaxAttempts,
+			BackoffBase:       time.Duration(cfg.JobBackoffBase) * time.Second,
+			BackoffMax:        time.Duration(cfg.JobBackoffMax) * time.Second,
+			VisibilityTimeout: time.Duration(cfg.JobVisibilityTimeout) * time.Second,
+			PollInterval:      time.Duration(cfg.JobPollInterval) * time.Second,
+		}), nil
+	case "memory":
+		return jobs.NewMemoryQueue(cfg.WorkerConcurrency, cfg.JobQueueSize), nil
+	}
+	return nil, fmt.Errorf("unknown job backend %q", cfg.JobBackend)
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"photo-go/pkg/types"
+)
+
+// ErrUnsupportedMedia trả về khi magic bytes không khớp định dạng nào được hỗ trợ
+var ErrUnsupportedMedia = errors.New("unsupported media format")
+
+// Format là định dạng file nhận diện từ magic bytes, không tin vào đuôi file hay Content-Type của client
+type Format struct {
+	Type types.MediaType
+	Name string // jpeg, png, mp4, ...
+	Ext  string // đuôi file chuẩn, có dấu chấm
+}
+
+// sniffLen là số byte đầu file đủ để nhận diện mọi định dạng hỗ trợ (MPEG-TS cần 2 sync byte cách nhau 188)
+const sniffLen = 512
+
+// DetectFile đọc header của file và nhận diện định dạng
+func DetectFile(path string) (Format, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Format{}, err
+	}
+	defer f.Close()
+	return DetectReader(f)
+}
+
+// DetectReader đọc sniffLen byte đầu từ r (vd: object trên storage) và nhận diện định dạng
+func DetectReader(r io.Reader) (Format, error) {
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return Format{}, ErrUnsupportedMedia
+	}
+	return DetectFormat(header[:n])
+}
+
+// DetectFormat nhận diện ảnh/video từ magic bytes
+func DetectFormat(h []byte) (Format, error) {
+	image := func(name, ext string) (Format, error) {
+		return Format{Type: types.MediaTypeImage, Name: name, Ext: ext}, nil
+	}
+	video := func(name, ext string) (Format, error) {
+		return Format{Type: types.MediaTypeVideo, Name: name, Ext: ext}, nil
+	}
+	switch {
+	case bytes.HasPrefix(h, []byte{0xFF, 0xD8, 0xFF}):
+		return image("jpeg", ".jpg")
+	case bytes.HasPrefix(h, []byte("\x89PNG\r\n\x1a\n")):
+		return image("png", ".png")
+	case bytes.HasPrefix(h, []byte("GIF87a")), bytes.HasPrefix(h, []byte("GIF89a")):
+		return image("gif", ".gif")
+	case len(h) >= 12 && bytes.Equal(h[:4], []byte("RIFF")) && bytes.Equal(h[8:12], []byte("WEBP")):
+		return image("webp", ".webp")
+	case len(h) >= 12 && bytes.Equal(h[:4], []byte("RIFF")) && bytes.Equal(h[8:12], []byte("AVI ")):
+		return video("avi", ".avi")
+	case len(h) >= 12 && bytes.Equal(h[4:8], []byte("ftyp")):
+		// ISO BMFF: brand ảnh (HEIC/AVIF) chưa decode được nên không nhận
+		switch string(h[8:12]) {
+		case "heic", "heix", "hevc", "mif1", "msf1", "avif", "avis":
+			return Format{}, ErrUnsupportedMedia
+		case "qt  ":
+			return video("mov", ".mov")
+		}
+		return video("mp4", ".mp4")
+	case bytes.HasPrefix(h, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		if bytes.Contains(h, []byte("webm")) {
+			return video("webm", ".webm")
+		}
+		return video("matroska", ".mkv")
+	case bytes.HasPrefix(h, []byte("FLV\x01")):
+		return video("flv", ".flv")
+	case bytes.HasPrefix(h, []byte{0x00, 0x00, 0x01, 0xBA}):
+		return video("mpeg", ".mpg")
+	case len(h) > 188 && h[0] == 0x47 && h[188] == 0x47:
+		return video("mpegts", ".ts")
+	}
+	return Format{}, ErrUnsupportedMedia
+}
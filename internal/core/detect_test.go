@@ -0,0 +1,44 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"photo-go/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDetectFormat tests magic byte detection for supported and rejected formats
+func TestDetectFormat(t *testing.T) {
+	ts := make([]byte, 200)
+	ts[0], ts[188] = 0x47, 0x47
+	tests := []struct {
+		name     string
+		header   []byte
+		expected string
+		typ      types.MediaType
+	}{
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0}, "jpeg", types.MediaTypeImage},
+		{"png", []byte("\x89PNG\r\n\x1a\n...."), "png", types.MediaTypeImage},
+		{"gif", []byte("GIF89a.."), "gif", types.MediaTypeImage},
+		{"webp", []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), "webp", types.MediaTypeImage},
+		{"mp4", []byte("\x00\x00\x00\x18ftypisom"), "mp4", types.MediaTypeVideo},
+		{"mov", []byte("\x00\x00\x00\x14ftypqt  "), "mov", types.MediaTypeVideo},
+		{"webm", []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x84webm"), "webm", types.MediaTypeVideo},
+		{"mpegts", ts, "mpegts", types.MediaTypeVideo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := DetectFormat(tt.header)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, f.Name)
+			assert.Equal(t, tt.typ, f.Type)
+		})
+	}
+
+	for _, h := range [][]byte{[]byte("\x00\x00\x00\x18ftypheic"), []byte("%PDF-1.7"), nil} {
+		_, err := DetectFormat(h)
+		assert.True(t, errors.Is(err, ErrUnsupportedMedia))
+	}
+}
@@ -0,0 +1,567 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EXIF là các trường EXIF của ảnh chụp mà hệ thống đọc và ghi lại được.
+// Chỉ hỗ trợ JPEG (segment APP1 "Exif").
+type EXIF struct {
+	Make         string
+	Model        string
+	LensMake     string
+	LensModel    string
+	ExposureTime string  // dạng "1/125" hoặc "2"
+	FNumber      float64 // f/1.8 -> 1.8
+	ISO          int
+	FocalLength  float64 // mm
+	CapturedAt   time.Time
+	Orientation  int // 1-8, 0 = không có
+	GPS          *GPS
+}
+
+// GPS là vị trí chụp, độ thập phân (nam/tây là số âm)
+type GPS struct {
+	Latitude  float64
+	Longitude float64
+	Altitude  float64 // mét, âm là dưới mực nước biển
+}
+
+// Tag EXIF/TIFF được hỗ trợ
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagExposureTime     = 0x829A
+	tagFNumber          = 0x829D
+	tagISO              = 0x8827
+	tagDateTimeOriginal = 0x9003
+	tagOffsetTimeOrig   = 0x9011
+	tagFocalLength      = 0x920A
+	tagLensMake         = 0xA433
+	tagLensModel        = 0xA434
+	tagGPSVersion       = 0x0000
+	tagGPSLatitudeRef   = 0x0001
+	tagGPSLatitude      = 0x0002
+	tagGPSLongitudeRef  = 0x0003
+	tagGPSLongitude     = 0x0004
+	tagGPSAltitudeRef   = 0x0005
+	tagGPSAltitude      = 0x0006
+)
+
+// Kiểu dữ liệu TIFF
+const (
+	tiffByte      = 1
+	tiffASCII     = 2
+	tiffShort     = 3
+	tiffLong      = 4
+	tiffRational  = 5
+	tiffUndefined = 7
+)
+
+var tiffTypeSize = map[uint16]int{tiffByte: 1, tiffASCII: 1, tiffShort: 2, tiffLong: 4, tiffRational: 8, tiffUndefined: 1, 9: 4, 10: 8}
+
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// ReadEXIF đọc EXIF từ file JPEG. Trả về nil, nil nếu file không phải JPEG hoặc không có EXIF.
+func ReadEXIF(path string) (*EXIF, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tiff, err := readJPEGExif(f)
+	if err != nil || tiff == nil {
+		return nil, err
+	}
+	return parseTIFF(tiff)
+}
+
+// readJPEGExif trả về phần TIFF trong segment APP1 Exif, dừng ở SOS
+func readJPEGExif(r io.Reader) ([]byte, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return nil, nil
+	}
+	for {
+		marker, data, err := readJPEGSegment(r)
+		if err != nil {
+			return nil, err
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			return nil, nil
+		}
+		if marker == 0xE1 && bytes.HasPrefix(data, exifHeader) {
+			return data[len(exifHeader):], nil
+		}
+	}
+}
+
+// readJPEGSegment đọc một segment có độ dài (marker không phải RST/SOI/EOI)
+func readJPEGSegment(r io.Reader) (byte, []byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:2]); err != nil {
+		return 0, nil, err
+	}
+	for hdr[1] == 0xFF { // byte đệm 0xFF trước marker
+		if _, err := io.ReadFull(r, hdr[1:2]); err != nil {
+			return 0, nil, err
+		}
+	}
+	if hdr[0] != 0xFF {
+		return 0, nil, errors.New("invalid JPEG marker")
+	}
+	if hdr[1] == 0xD9 {
+		return hdr[1], nil, nil
+	}
+	if _, err := io.ReadFull(r, hdr[2:]); err != nil {
+		return 0, nil, err
+	}
+	n := int(binary.BigEndian.Uint16(hdr[2:]))
+	if n < 2 {
+		return 0, nil, errors.New("invalid JPEG segment length")
+	}
+	data := make([]byte, n-2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+	return hdr[1], data, nil
+}
+
+// WriteJPEGMetadata ghi lại JPEG ở src sang dst không re-encode, thay mọi metadata
+// (EXIF, XMP, IPTC) bằng EXIF từ e; e = nil thì bỏ hết
+func WriteJPEGMetadata(src, dst string, e *EXIF) error {
+	in, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	out, err := replaceJPEGMetadata(in, e)
+	if err != nil {
+		return fmt.Errorf("rewrite metadata %s: %w", src, err)
+	}
+	return os.WriteFile(dst, out, 0o644)
+}
+
+func replaceJPEGMetadata(in []byte, e *EXIF) ([]byte, error) {
+	if !bytes.HasPrefix(in, []byte{0xFF, 0xD8}) {
+		return nil, errors.New("not a JPEG")
+	}
+	var out bytes.Buffer
+	out.Write(in[:2])
+	if e != nil {
+		seg := append(append([]byte{}, exifHeader...), encodeTIFF(e)...)
+		if len(seg)+2 > math.MaxUint16 {
+			return nil, errors.New("EXIF too large")
+		}
+		out.Write([]byte{0xFF, 0xE1, byte((len(seg) + 2) >> 8), byte(len(seg) + 2)})
+		out.Write(seg)
+	}
+	r := bytes.NewReader(in[2:])
+	for {
+		start := len(in) - r.Len()
+		marker, _, err := readJPEGSegment(r)
+		if err != nil {
+			return nil, err
+		}
+		// APP1 (EXIF/XMP) và APP13 (IPTC) có thể chứa vị trí, bỏ đi
+		if marker == 0xE1 || marker == 0xED {
+			continue
+		}
+		end := len(in) - r.Len()
+		out.Write(in[start:end])
+		if marker == 0xDA || marker == 0xD9 {
+			// từ SOS trở đi là dữ liệu ảnh, copy nguyên
+			out.Write(in[end:])
+			return out.Bytes(), nil
+		}
+	}
+}
+
+// tiffEntry là một entry IFD đã đọc, value là dữ liệu thô đã resolve offset
+type tiffEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+type tiffReader struct {
+	b  []byte
+	bo binary.ByteOrder
+}
+
+func parseTIFF(b []byte) (*EXIF, error) {
+	if len(b) < 8 {
+		return nil, errors.New("EXIF too short")
+	}
+	r := tiffReader{b: b}
+	switch string(b[:2]) {
+	case "II":
+		r.bo = binary.LittleEndian
+	case "MM":
+		r.bo = binary.BigEndian
+	default:
+		return nil, errors.New("invalid TIFF byte order")
+	}
+	ifd0, err := r.ifd(r.bo.Uint32(b[4:]))
+	if err != nil {
+		return nil, err
+	}
+	e := &EXIF{
+		Make:        r.str(ifd0[tagMake]),
+		Model:       r.str(ifd0[tagModel]),
+		Orientation: int(r.uint(ifd0[tagOrientation])),
+	}
+	if e.Orientation < 0 || e.Orientation > 8 {
+		e.Orientation = 0
+	}
+	if off := r.uint(ifd0[tagExifIFD]); off > 0 {
+		sub, err := r.ifd(uint32(off))
+		if err != nil {
+			return nil, err
+		}
+		e.LensMake = r.str(sub[tagLensMake])
+		e.LensModel = r.str(sub[tagLensModel])
+		if num, den := r.rational(sub[tagExposureTime], 0); den > 0 {
+			e.ExposureTime = formatExposure(num, den)
+		}
+		e.FNumber = round3(r.float(sub[tagFNumber], 0))
+		e.ISO = int(r.uint(sub[tagISO]))
+		e.FocalLength = round3(r.float(sub[tagFocalLength], 0))
+		e.CapturedAt = parseEXIFTime(r.str(sub[tagDateTimeOriginal]), r.str(sub[tagOffsetTimeOrig]))
+	}
+	if off := r.uint(ifd0[tagGPSIFD]); off > 0 {
+		sub, err := r.ifd(uint32(off))
+		if err != nil {
+			return nil, err
+		}
+		e.GPS = r.gps(sub)
+	}
+	return e, nil
+}
+
+// ifd đọc các entry của IFD tại offset
+func (r tiffReader) ifd(off uint32) (map[uint16]tiffEntry, error) {
+	if int(off)+2 > len(r.b) {
+		return nil, errors.New("IFD offset out of range")
+	}
+	n := int(r.bo.Uint16(r.b[off:]))
+	p := int(off) + 2
+	if p+n*12 > len(r.b) {
+		return nil, errors.New("IFD truncated")
+	}
+	entries := make(map[uint16]tiffEntry, n)
+	for i := 0; i < n; i, p = i+1, p+12 {
+		tag, typ, count := r.bo.Uint16(r.b[p:]), r.bo.Uint16(r.b[p+2:]), r.bo.Uint32(r.b[p+4:])
+		size, ok := tiffTypeSize[typ]
+		if !ok || count > uint32(len(r.b)) {
+			continue
+		}
+		total := size * int(count)
+		valueOff := p + 8
+		if total > 4 {
+			valueOff = int(r.bo.Uint32(r.b[p+8:]))
+		}
+		if valueOff+total > len(r.b) {
+			continue
+		}
+		entries[tag] = tiffEntry{typ: typ, count: count, value: r.b[valueOff : valueOff+total]}
+	}
+	return entries, nil
+}
+
+func (r tiffReader) str(e tiffEntry) string {
+	if e.typ != tiffASCII && e.typ != tiffUndefined {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(e.value), "\x00"))
+}
+
+func (r tiffReader) uint(e tiffEntry) uint32 {
+	switch {
+	case e.typ == tiffShort && len(e.value) >= 2:
+		return uint32(r.bo.Uint16(e.value))
+	case e.typ == tiffLong && len(e.value) >= 4:
+		return r.bo.Uint32(e.value)
+	case e.typ == tiffByte && len(e.value) >= 1:
+		return uint32(e.value[0])
+	}
+	return 0
+}
+
+func (r tiffReader) rational(e tiffEntry, i int) (uint32, uint32) {
+	if e.typ != tiffRational || len(e.value) < (i+1)*8 {
+		return 0, 0
+	}
+	return r.bo.Uint32(e.value[i*8:]), r.bo.Uint32(e.value[i*8+4:])
+}
+
+func (r tiffReader) float(e tiffEntry, i int) float64 {
+	num, den := r.rational(e, i)
+	if den == 0 {
+		return 0
+	}
+	return float64(num) / float64(den)
+}
+
+func (r tiffReader) gps(sub map[uint16]tiffEntry) *GPS {
+	lat, lon := sub[tagGPSLatitude], sub[tagGPSLongitude]
+	if lat.count < 3 || lon.count < 3 {
+		return nil
+	}
+	dms := func(e tiffEntry) float64 {
+		return r.float(e, 0) + r.float(e, 1)/60 + r.float(e, 2)/3600
+	}
+	g := &GPS{Latitude: dms(lat), Longitude: dms(lon), Altitude: r.float(sub[tagGPSAltitude], 0)}
+	if r.str(sub[tagGPSLatitudeRef]) == "S" {
+		g.Latitude = -g.Latitude
+	}
+	if r.str(sub[tagGPSLongitudeRef]) == "W" {
+		g.Longitude = -g.Longitude
+	}
+	if r.uint(sub[tagGPSAltitudeRef]) == 1 {
+		g.Altitude = -g.Altitude
+	}
+	g.Latitude, g.Longitude, g.Altitude = round7(g.Latitude), round7(g.Longitude), round3(g.Altitude)
+	return g
+}
+
+// parseEXIFTime đọc "2006:01:02 15:04:05" kèm offset "+07:00" nếu có, không có offset thì coi là UTC
+func parseEXIFTime(s, offset string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	if offset != "" {
+		if t, err := time.Parse(exifTimeLayout+"-07:00", s+offset); err == nil {
+			return t
+		}
+	}
+	t, _ := time.Parse(exifTimeLayout, s)
+	return t
+}
+
+func formatExposure(num, den uint32) string {
+	if num == 0 {
+		return "0"
+	}
+	if num >= den {
+		return fmt.Sprint(round3(float64(num) / float64(den)))
+	}
+	return fmt.Sprintf("1/%d", int(math.Round(float64(den)/float64(num))))
+}
+
+func round3(v float64) float64 { return math.Round(v*1e3) / 1e3 }
+func round7(v float64) float64 { return math.Round(v*1e7) / 1e7 }
+
+// tiffField là một entry chuẩn bị ghi
+type tiffField struct {
+	tag  uint16
+	typ  uint16
+	data []byte
+}
+
+// encodeTIFF ghi EXIF thành cấu trúc TIFF little endian: IFD0, Exif IFD, GPS IFD
+func encodeTIFF(e *EXIF) []byte {
+	bo := binary.LittleEndian
+	short := func(v int) []byte { return bo.AppendUint16(nil, uint16(v)) }
+	long := func(v int) []byte { return bo.AppendUint32(nil, uint32(v)) }
+	rational := func(vs ...[2]uint32) []byte {
+		var b []byte
+		for _, v := range vs {
+			b = bo.AppendUint32(bo.AppendUint32(b, v[0]), v[1])
+		}
+		return b
+	}
+	float := func(v float64) [2]uint32 { return [2]uint32{uint32(math.Round(v * 1000)), 1000} }
+	ascii := func(s string) []byte { return append([]byte(s), 0) }
+
+	var ifd0, exif, gps []tiffField
+	addStr := func(fs *[]tiffField, tag uint16, s string) {
+		if s != "" {
+			*fs = append(*fs, tiffField{tag, tiffASCII, ascii(s)})
+		}
+	}
+	addStr(&ifd0, tagMake, e.Make)
+	addStr(&ifd0, tagModel, e.Model)
+	if e.Orientation > 0 {
+		ifd0 = append(ifd0, tiffField{tagOrientation, tiffShort, short(e.Orientation)})
+	}
+	if e.ExposureTime != "" {
+		var num, den uint32 = 1, 1
+		if _, err := fmt.Sscanf(e.ExposureTime, "%d/%d", &num, &den); err != nil {
+			num, den = float(parseFloat(e.ExposureTime))[0], 1000
+		}
+		exif = append(exif, tiffField{tagExposureTime, tiffRational, rational([2]uint32{num, den})})
+	}
+	if e.FNumber > 0 {
+		exif = append(exif, tiffField{tagFNumber, tiffRational, rational(float(e.FNumber))})
+	}
+	if e.ISO > 0 {
+		// ISOSpeedRatings là SHORT, EXIF quy định ghi 65535 khi ISO từ 65535 trở lên
+		exif = append(exif, tiffField{tagISO, tiffShort, short(min(e.ISO, 0xFFFF))})
+	}
+	if !e.CapturedAt.IsZero() {
+		addStr(&exif, tagDateTimeOriginal, e.CapturedAt.Format(exifTimeLayout))
+		addStr(&exif, tagOffsetTimeOrig, e.CapturedAt.Format("-07:00"))
+	}
+	if e.FocalLength > 0 {
+		exif = append(exif, tiffField{tagFocalLength, tiffRational, rational(float(e.FocalLength))})
+	}
+	addStr(&exif, tagLensMake, e.LensMake)
+	addStr(&exif, tagLensModel, e.LensModel)
+	if g := e.GPS; g != nil {
+		dms := func(v float64) []byte {
+			v = math.Abs(v)
+			deg := math.Floor(v)
+			mins := math.Floor((v - deg) * 60)
+			sec := (v - deg - mins/60) * 3600
+			return rational([2]uint32{uint32(deg), 1}, [2]uint32{uint32(mins), 1}, [2]uint32{uint32(math.Round(sec * 10000)), 10000})
+		}
+		latRef, lonRef, altRef := "N", "E", 0
+		if g.Latitude < 0 {
+			latRef = "S"
+		}
+		if g.Longitude < 0 {
+			lonRef = "W"
+		}
+		if g.Altitude < 0 {
+			altRef = 1
+		}
+		gps = []tiffField{
+			{tagGPSVersion, tiffByte, []byte{2, 2, 0, 0}},
+			{tagGPSLatitudeRef, tiffASCII, ascii(latRef)},
+			{tagGPSLatitude, tiffRational, dms(g.Latitude)},
+			{tagGPSLongitudeRef, tiffASCII, ascii(lonRef)},
+			{tagGPSLongitude, tiffRational, dms(g.Longitude)},
+			{tagGPSAltitudeRef, tiffByte, []byte{byte(altRef)}},
+			{tagGPSAltitude, tiffRational, rational(float(math.Abs(g.Altitude)))},
+		}
+	}
+	// con trỏ sub-IFD có kích thước cố định nên tính được offset trước khi biết giá trị
+	if len(exif) > 0 {
+		ifd0 = append(ifd0, tiffField{tagExifIFD, tiffLong, long(0)})
+	}
+	if len(gps) > 0 {
+		ifd0 = append(ifd0, tiffField{tagGPSIFD, tiffLong, long(0)})
+	}
+	exifOff := 8 + ifdSize(ifd0)
+	gpsOff := exifOff + ifdSize(exif)
+	for i := range ifd0 {
+		switch ifd0[i].tag {
+		case tagExifIFD:
+			ifd0[i].data = long(exifOff)
+		case tagGPSIFD:
+			ifd0[i].data = long(gpsOff)
+		}
+	}
+
+	out := []byte("II*\x00")
+	out = bo.AppendUint32(out, 8)
+	out = appendIFD(out, ifd0)
+	if len(exif) > 0 {
+		out = appendIFD(out, exif)
+	}
+	if len(gps) > 0 {
+		out = appendIFD(out, gps)
+	}
+	return out
+}
+
+func ifdSize(fields []tiffField) int {
+	if len(fields) == 0 {
+		return 0
+	}
+	n := 2 + 12*len(fields) + 4
+	for _, f := range fields {
+		if len(f.data) > 4 {
+			n += len(f.data) + len(f.data)%2
+		}
+	}
+	return n
+}
+
+// appendIFD ghi IFD bắt đầu tại len(out), dữ liệu dài hơn 4 byte nằm ngay sau bảng entry
+func appendIFD(out []byte, fields []tiffField) []byte {
+	bo := binary.LittleEndian
+	sort.Slice(fields, func(i, j int) bool { return fields[i].tag < fields[j].tag })
+	dataOff := len(out) + 2 + 12*len(fields) + 4
+	var data []byte
+	out = bo.AppendUint16(out, uint16(len(fields)))
+	for _, f := range fields {
+		out = bo.AppendUint16(out, f.tag)
+		out = bo.AppendUint16(out, f.typ)
+		out = bo.AppendUint32(out, uint32(len(f.data)/tiffTypeSize[f.typ]))
+		if len(f.data) <= 4 {
+			var inline [4]byte
+			copy(inline[:], f.data)
+			out = append(out, inline[:]...)
+			continue
+		}
+		out = bo.AppendUint32(out, uint32(dataOff+len(data)))
+		data = append(data, f.data...)
+		if len(f.data)%2 == 1 {
+			data = append(data, 0)
+		}
+	}
+	out = bo.AppendUint32(out, 0) // không có IFD kế tiếp
+	return append(out, data...)
+}
+
+func parseFloat(s string) float64 {
+	var v float64
+	fmt.Sscanf(s, "%g", &v)
+	return v
+}
+
+// MetadataPolicy quyết định EXIF nào được giữ trong file phục vụ cho client
+type MetadataPolicy string
+
+const (
+	// MetadataStripGPS giữ thông tin máy ảnh, bỏ vị trí (mặc định)
+	MetadataStripGPS MetadataPolicy = "strip_gps"
+	// MetadataStripAll bỏ toàn bộ metadata, chỉ giữ Orientation
+	MetadataStripAll MetadataPolicy = "strip_all"
+	// MetadataKeepAll giữ mọi trường EXIF đọc được, kể cả GPS
+	MetadataKeepAll MetadataPolicy = "keep_all"
+)
+
+// Validate kiểm tra policy có được hỗ trợ không
+func (p MetadataPolicy) Validate() error {
+	switch p {
+	case MetadataStripGPS, MetadataStripAll, MetadataKeepAll:
+		return nil
+	}
+	return fmt.Errorf("unknown metadata policy %q", p)
+}
+
+// Apply trả về bản EXIF được phép giữ theo policy.
+// Orientation luôn được giữ để ảnh gốc vẫn hiển thị đúng chiều.
+func (p MetadataPolicy) Apply(e *EXIF) *EXIF {
+	if e == nil {
+		return nil
+	}
+	switch p {
+	case MetadataKeepAll:
+		return e
+	case MetadataStripAll:
+		if e.Orientation == 0 {
+			return nil
+		}
+		return &EXIF{Orientation: e.Orientation}
+	}
+	out := *e
+	out.GPS = nil
+	return &out
+}
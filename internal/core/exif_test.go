@@ -0,0 +1,80 @@
+package core
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEXIFRoundTrip tests that encoded EXIF parses back to the same fields
+func TestEXIFRoundTrip(t *testing.T) {
+	in := &EXIF{
+		Make:         "Apple",
+		Model:        "iPhone 15 Pro",
+		LensModel:    "iPhone 15 Pro back triple camera 6.765mm f/1.78",
+		ExposureTime: "1/125",
+		FNumber:      1.78,
+		ISO:          80,
+		FocalLength:  6.765,
+		CapturedAt:   time.Date(2024, 5, 1, 14, 30, 0, 0, time.FixedZone("", 7*3600)),
+		Orientation:  6,
+		GPS:          &GPS{Latitude: 21.0285, Longitude: -105.8542, Altitude: -12.5},
+	}
+	out, err := parseTIFF(encodeTIFF(in))
+	require.NoError(t, err)
+	assert.True(t, in.CapturedAt.Equal(out.CapturedAt))
+	out.CapturedAt = in.CapturedAt
+	assert.Equal(t, in, out)
+
+	// ISO vượt SHORT bị chặn ở 65535 thay vì tràn số
+	out, err = parseTIFF(encodeTIFF(&EXIF{ISO: 102400}))
+	require.NoError(t, err)
+	assert.Equal(t, 65535, out.ISO)
+}
+
+// TestWriteJPEGMetadata tests injecting and stripping EXIF without re-encoding
+func TestWriteJPEGMetadata(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "in.jpg")
+	f, err := os.Create(src)
+	require.NoError(t, err)
+	require.NoError(t, jpeg.Encode(f, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil))
+	require.NoError(t, f.Close())
+
+	tagged := filepath.Join(dir, "tagged.jpg")
+	require.NoError(t, WriteJPEGMetadata(src, tagged, &EXIF{Make: "Canon", Orientation: 3, GPS: &GPS{Latitude: 1, Longitude: 2}}))
+	e, err := ReadEXIF(tagged)
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	assert.Equal(t, "Canon", e.Make)
+	assert.Equal(t, 3, e.Orientation)
+	assert.NotNil(t, e.GPS)
+
+	stripped := filepath.Join(dir, "stripped.jpg")
+	require.NoError(t, WriteJPEGMetadata(tagged, stripped, nil))
+	e, err = ReadEXIF(stripped)
+	require.NoError(t, err)
+	assert.Nil(t, e)
+	_, _, err = decodeImage(stripped)
+	assert.NoError(t, err)
+}
+
+// TestOrient tests that orientation 6 rotates the image clockwise
+func TestOrient(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	src.Set(1, 0, color.RGBA{0, 0, 255, 255})
+
+	dst := orient(src, 6)
+	assert.Equal(t, image.Rect(0, 0, 1, 2), dst.Bounds())
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, dst.At(0, 0))
+	assert.Equal(t, color.RGBA{0, 0, 255, 255}, dst.At(0, 1))
+	assert.Equal(t, src, orient(src, 1))
+}
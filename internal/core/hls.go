@@ -0,0 +1,210 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Rendition mô tả một luồng HLS đã transcode, dùng để sinh master playlist
+type Rendition struct {
+	Name             string `json:"name"`     // 360p, 480p, ...
+	Playlist         string `json:"playlist"` // đường dẫn playlist tương đối với thư mục output
+	Width            int    `json:"width"`
+	Height           int    `json:"height"`
+	Bandwidth        int64  `json:"bandwidth"`         // peak bitrate của segment (bit/s)
+	AverageBandwidth int64  `json:"average_bandwidth"` // bitrate trung bình (bit/s)
+	Codecs           string `json:"codecs"`            // RFC 6381, vd: avc1.4d401f,mp4a.40.2
+}
+
+// WriteMasterPlaylist ghi master playlist chứa #EXT-X-STREAM-INF cho từng rendition,
+// sắp xếp theo bandwidth tăng dần để player chọn rung thấp nhất khi khởi động
+func WriteMasterPlaylist(path string, renditions []Rendition) error {
+	if len(renditions) == 0 {
+		return fmt.Errorf("write master playlist: no renditions")
+	}
+	sorted := make([]Rendition, len(renditions))
+	copy(sorted, renditions)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Bandwidth < sorted[j].Bandwidth })
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-INDEPENDENT-SEGMENTS\n")
+	for _, r := range sorted {
+		attrs := []string{fmt.Sprintf("BANDWIDTH=%d", r.Bandwidth)}
+		if r.AverageBandwidth > 0 {
+			attrs = append(attrs, fmt.Sprintf("AVERAGE-BANDWIDTH=%d", r.AverageBandwidth))
+		}
+		if r.Width > 0 && r.Height > 0 {
+			attrs = append(attrs, fmt.Sprintf("RESOLUTION=%dx%d", r.Width, r.Height))
+		}
+		if r.Codecs != "" {
+			attrs = append(attrs, fmt.Sprintf("CODECS=%q", r.Codecs))
+		}
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:%s\n%s\n", strings.Join(attrs, ","), r.Playlist)
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
+
+// uriAttrPattern khớp thuộc tính URI="..." trong tag (EXT-X-MEDIA, EXT-X-MAP, EXT-X-KEY, ...)
+var uriAttrPattern = regexp.MustCompile(`URI="([^"]*)"`)
+
+// RewritePlaylist thay mọi URI trong playlist (dòng segment/playlist con và thuộc tính URI="...")
+// bằng kết quả của rewrite, các dòng khác giữ nguyên
+func RewritePlaylist(data []byte, rewrite func(uri string) (string, error)) ([]byte, error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	var rewriteErr error
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			continue
+		case strings.HasPrefix(trimmed, "#"):
+			lines[i] = uriAttrPattern.ReplaceAllStringFunc(line, func(m string) string {
+				uri := uriAttrPattern.FindStringSubmatch(m)[1]
+				out, err := rewrite(uri)
+				if err != nil {
+					rewriteErr = err
+					return m
+				}
+				return fmt.Sprintf("URI=%q", out)
+			})
+		default:
+			out, err := rewrite(trimmed)
+			if err != nil {
+				return nil, err
+			}
+			lines[i] = out
+		}
+		if rewriteErr != nil {
+			return nil, rewriteErr
+		}
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// describeRendition đo bandwidth từ các segment đã ghi và probe segment đầu tiên để lấy resolution/codec
+func describeRendition(ctx context.Context, outputDir, name, playlist string) (Rendition, error) {
+	r := Rendition{Name: name, Playlist: playlist}
+	segments, peak, avg, err := measureBandwidth(filepath.Join(outputDir, playlist))
+	if err != nil {
+		return r, err
+	}
+	r.Bandwidth, r.AverageBandwidth = peak, avg
+	if len(segments) == 0 {
+		return r, fmt.Errorf("rendition %s has no segments", name)
+	}
+	streams, err := probeStreams(ctx, filepath.Join(outputDir, segments[0]))
+	if err != nil {
+		return r, err
+	}
+	var codecs []string
+	for _, s := range streams {
+		switch s.CodecType {
+		case "video":
+			r.Width, r.Height = s.Width, s.Height
+			if c := videoCodecString(s); c != "" {
+				codecs = append(codecs, c)
+			}
+		case "audio":
+			if c := audioCodecString(s); c != "" {
+				codecs = append(codecs, c)
+			}
+		}
+	}
+	r.Codecs = strings.Join(codecs, ",")
+	return r, nil
+}
+
+// measureBandwidth đọc media playlist, trả về danh sách segment cùng peak và average bitrate (bit/s)
+func measureBandwidth(playlistPath string) ([]string, int64, int64, error) {
+	f, err := os.Open(playlistPath)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(playlistPath)
+	var (
+		segments   []string
+		peak       int64
+		totalBits  float64
+		totalSecs  float64
+		segmentDur float64
+	)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXTINF:"):
+			v := strings.SplitN(strings.TrimPrefix(line, "#EXTINF:"), ",", 2)[0]
+			segmentDur, _ = strconv.ParseFloat(v, 64)
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		default:
+			info, err := os.Stat(filepath.Join(dir, line))
+			if err != nil {
+				return nil, 0, 0, err
+			}
+			segments = append(segments, line)
+			bits := float64(info.Size() * 8)
+			if segmentDur > 0 {
+				if rate := int64(bits / segmentDur); rate > peak {
+					peak = rate
+				}
+			}
+			totalBits += bits
+			totalSecs += segmentDur
+			segmentDur = 0
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, 0, err
+	}
+	var avg int64
+	if totalSecs > 0 {
+		avg = int64(totalBits / totalSecs)
+	}
+	return segments, peak, avg, nil
+}
+
+// videoCodecString sinh codec string RFC 6381 cho H.264 (avc1.PPCCLL)
+func videoCodecString(s ffprobeStream) string {
+	if s.CodecName != "h264" {
+		return ""
+	}
+	var profileCompat string
+	switch strings.ToLower(s.Profile) {
+	case "constrained baseline":
+		profileCompat = "42e0"
+	case "baseline":
+		profileCompat = "4200"
+	case "main":
+		profileCompat = "4d40"
+	case "high":
+		profileCompat = "6400"
+	default:
+		return ""
+	}
+	return fmt.Sprintf("avc1.%s%02x", profileCompat, s.Level)
+}
+
+// audioCodecString sinh codec string RFC 6381 cho AAC
+func audioCodecString(s ffprobeStream) string {
+	if s.CodecName != "aac" {
+		return ""
+	}
+	switch strings.ToLower(s.Profile) {
+	case "he-aac":
+		return "mp4a.40.5"
+	case "he-aacv2":
+		return "mp4a.40.29"
+	default:
+		return "mp4a.40.2"
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWriteMasterPlaylist tests master playlist output and ordering
+func TestWriteMasterPlaylist(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, MasterPlaylistName)
+	renditions := []Rendition{
+		{Name: "720p", Playlist: "720p.m3u8", Width: 1280, Height: 720, Bandwidth: 3000000, AverageBandwidth: 2800000, Codecs: "avc1.4d401f,mp4a.40.2"},
+		{Name: "360p", Playlist: "360p.m3u8", Width: 640, Height: 360, Bandwidth: 900000, Codecs: "avc1.4d401e,mp4a.40.2"},
+	}
+
+	require.NoError(t, WriteMasterPlaylist(path, renditions))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	expected := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-INDEPENDENT-SEGMENTS\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=900000,RESOLUTION=640x360,CODECS=\"avc1.4d401e,mp4a.40.2\"\n360p.m3u8\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=3000000,AVERAGE-BANDWIDTH=2800000,RESOLUTION=1280x720,CODECS=\"avc1.4d401f,mp4a.40.2\"\n720p.m3u8\n"
+	assert.Equal(t, expected, string(data))
+
+	assert.Error(t, WriteMasterPlaylist(path, nil))
+}
+
+// TestMeasureBandwidth tests peak/average bitrate computed from segment sizes
+func TestMeasureBandwidth(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a_000.ts"), make([]byte, 1000), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a_001.ts"), make([]byte, 3000), 0o644))
+	playlist := strings.Join([]string{
+		"#EXTM3U",
+		"#EXT-X-TARGETDURATION:4",
+		"#EXTINF:4.000000,",
+		"a_000.ts",
+		"#EXTINF:2.000000,",
+		"a_001.ts",
+		"#EXT-X-ENDLIST",
+	}, "\n")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.m3u8"), []byte(playlist), 0o644))
+
+	segments, peak, avg, err := measureBandwidth(filepath.Join(dir, "a.m3u8"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a_000.ts", "a_001.ts"}, segments)
+	assert.Equal(t, int64(12000), peak)
+	assert.Equal(t, int64(5333), avg)
+}
+
+// TestCodecStrings tests RFC 6381 codec string generation
+func TestCodecStrings(t *testing.T) {
+	assert.Equal(t, "avc1.4d401f", videoCodecString(ffprobeStream{CodecName: "h264", Profile: "Main", Level: 31}))
+	assert.Equal(t, "avc1.640028", videoCodecString(ffprobeStream{CodecName: "h264", Profile: "High", Level: 40}))
+	assert.Equal(t, "", videoCodecString(ffprobeStream{CodecName: "hevc", Profile: "Main", Level: 93}))
+	assert.Equal(t, "mp4a.40.2", audioCodecString(ffprobeStream{CodecName: "aac", Profile: "LC"}))
+	assert.Equal(t, "", audioCodecString(ffprobeStream{CodecName: "opus"}))
+}
+
+// TestRewritePlaylist tests that segment lines and URI attributes are rewritten, tags are kept
+func TestRewritePlaylist(t *testing.T) {
+	in := "#EXTM3U\r\n#EXT-X-MAP:URI=\"init.mp4\"\r\n#EXTINF:4.000,\r\n720p_000.ts\r\n#EXT-X-ENDLIST\r\n"
+	out, err := RewritePlaylist([]byte(in), func(uri string) (string, error) {
+		return "/v1/media/stream/7/" + uri, nil
+	})
+	require.NoError(t, err)
+	expected := "#EXTM3U\n#EXT-X-MAP:URI=\"/v1/media/stream/7/init.mp4\"\n#EXTINF:4.000,\n/v1/media/stream/7/720p_000.ts\n#EXT-X-ENDLIST\n"
+	assert.Equal(t, expected, string(out))
+
+	_, err = RewritePlaylist([]byte(in), func(uri string) (string, error) {
+		return "", assert.AnError
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+}
@@ -2,21 +2,349 @@ package core
 
 import (
 	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+
+	_ "image/gif" // decode frame đầu của GIF
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // đăng ký decoder webp
 )
 
 // ImageProcessor định nghĩa interface xử lý ảnh
 
 type ImageProcessor interface {
-	ProcessImage(ctx context.Context, inputPath, outputPath string) error
+	ProcessImage(ctx context.Context, inputPath, outputPath string, opts ImageOptions) (*ImageResult, error)
+}
+
+// FitMode quyết định cách đưa ảnh vào khung Width x Height
+type FitMode string
+
+const (
+	// FitInside thu nhỏ vào trong khung, giữ tỉ lệ, không phóng to (mặc định)
+	FitInside FitMode = "inside"
+	// FitContain co giãn vào trong khung, giữ tỉ lệ, phần thừa tô Background, output đúng kích thước khung
+	FitContain FitMode = "contain"
+	// FitCover co giãn phủ kín khung, giữ tỉ lệ, cắt phần thừa ở giữa
+	FitCover FitMode = "cover"
+	// FitFill kéo giãn đúng kích thước khung, bỏ qua tỉ lệ
+	FitFill FitMode = "fill"
+)
+
+// Filter là bộ lọc resample khi co giãn ảnh
+type Filter string
+
+const (
+	FilterLanczos    Filter = "lanczos" // nét nhất, mặc định
+	FilterCatmullRom Filter = "catmullrom"
+	FilterBilinear   Filter = "bilinear"
+	FilterNearest    Filter = "nearest"
+)
+
+// ImageOptions tùy chọn tạo một derivative từ ảnh gốc
+type ImageOptions struct {
+	Width        int              // 0 = tính theo tỉ lệ từ Height
+	Height       int              // 0 = tính theo tỉ lệ từ Width
+	Fit          FitMode          // rỗng = FitInside
+	Crop         *image.Rectangle // vùng cắt trên ảnh gốc trước khi co giãn, nil = toàn ảnh
+	Filter       Filter           // rỗng = FilterLanczos
+	Background   color.Color      // màu nền cho FitContain, nil = trắng với JPEG, trong suốt với PNG
+	AutoOrient   bool             // xoay/lật theo EXIF Orientation của input trước khi crop/resize
+	KeepMetadata bool             // ghi lại EXIF của input vào output JPEG (Orientation = 1 nếu đã AutoOrient)
+	Format       string           // jpeg | png | webp, rỗng = giữ định dạng gốc (GIF -> png, còn lại -> jpeg)
+	Quality      int              // chất lượng JPEG/WebP 1-100, 0 = mặc định
 }
 
+// ImageResult mô tả ảnh output đã ghi
+type ImageResult struct {
+	Width  int
+	Height int
+	Format string
+}
+
+// defaultJPEGQuality dùng khi ImageOptions.Quality = 0
+const defaultJPEGQuality = 85
+
+// ImageFormats là các định dạng ProcessImage encode được (webp cần ffmpeg có libwebp)
+var ImageFormats = []string{"jpeg", "png", "webp"}
+
+// lanczos3 là kernel Lanczos a=3, x/image/draw chỉ có sẵn tới CatmullRom
+var lanczos3 = &draw.Kernel{Support: 3, At: func(t float64) float64 {
+	if t == 0 {
+		return 1
+	}
+	if t >= 3 {
+		return 0
+	}
+	x := math.Pi * t
+	return 3 * math.Sin(x) * math.Sin(x/3) / (x * x)
+}}
+
 type DefaultImageProcessor struct{}
 
 func NewDefaultImageProcessor() *DefaultImageProcessor {
 	return &DefaultImageProcessor{}
 }
 
-func (p *DefaultImageProcessor) ProcessImage(ctx context.Context, inputPath, outputPath string) error {
-	// TODO: Xử lý ảnh (resize, crop, ...)
+// ProcessImage decode ảnh (JPEG/PNG/GIF/WebP), cắt, co giãn theo Fit rồi encode JPEG/PNG/WebP
+func (p *DefaultImageProcessor) ProcessImage(ctx context.Context, inputPath, outputPath string, opts ImageOptions) (*ImageResult, error) {
+	src, srcFormat, err := decodeImage(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var meta *EXIF
+	if opts.AutoOrient || opts.KeepMetadata {
+		// EXIF hỏng không làm hỏng ảnh, chỉ bỏ qua metadata
+		meta, _ = ReadEXIF(inputPath)
+	}
+	if meta != nil && opts.AutoOrient {
+		src = orient(src, meta.Orientation)
+		meta.Orientation = 1
+	}
+	if opts.Format == "" {
+		opts.Format = outputFormat(srcFormat)
+	}
+	dst, err := transform(src, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if opts.Format == "webp" {
+		err = encodeWebP(ctx, outputPath, dst, opts.quality())
+	} else {
+		err = encodeImage(outputPath, dst, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if meta != nil && opts.KeepMetadata && opts.Format == "jpeg" {
+		if err := WriteJPEGMetadata(outputPath, outputPath, meta); err != nil {
+			return nil, err
+		}
+	}
+	b := dst.Bounds()
+	return &ImageResult{Width: b.Dx(), Height: b.Dy(), Format: opts.Format}, nil
+}
+
+// transform áp Crop và Fit lên ảnh đã decode
+func transform(src image.Image, opts ImageOptions) (image.Image, error) {
+	kernel, err := opts.Filter.kernel()
+	if err != nil {
+		return nil, err
+	}
+	area := src.Bounds()
+	if opts.Crop != nil {
+		area = opts.Crop.Add(area.Min).Intersect(area)
+		if area.Empty() {
+			return nil, fmt.Errorf("crop %v is outside image bounds %v", *opts.Crop, src.Bounds())
+		}
+	}
+	srcRect, dstRect, out, err := resizePlan(area, opts.Width, opts.Height, opts.Fit)
+	if err != nil {
+		return nil, err
+	}
+	if out == srcRect.Size() && dstRect.Min == (image.Point{}) && srcRect == src.Bounds() {
+		return src, nil
+	}
+	canvas := image.NewRGBA(image.Rectangle{Max: out})
+	op := draw.Src
+	if dstRect != canvas.Bounds() {
+		// FitContain: tô nền rồi vẽ ảnh đè lên
+		draw.Draw(canvas, canvas.Bounds(), image.NewUniform(opts.background()), image.Point{}, draw.Src)
+		op = draw.Over
+	}
+	kernel.Scale(canvas, dstRect, src, srcRect, op, nil)
+	return canvas, nil
+}
+
+// orient xoay/lật ảnh theo giá trị EXIF Orientation (1-8) để ảnh hiển thị đúng chiều
+func orient(src image.Image, o int) image.Image {
+	if o < 2 || o > 8 {
+		return src
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch o {
+			case 2: // lật ngang
+				dx, dy = w-1-x, y
+			case 3: // xoay 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // lật dọc
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // xoay 90 theo chiều kim đồng hồ
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // xoay 90 ngược chiều kim đồng hồ
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], rgba.Pix[rgba.PixOffset(x, y):rgba.PixOffset(x, y)+4])
+		}
+	}
+	return dst
+}
+
+// resizePlan tính vùng nguồn, vùng đích và kích thước output cho một fit mode.
+// area là vùng nguồn sau khi crop.
+func resizePlan(area image.Rectangle, boxW, boxH int, fit FitMode) (image.Rectangle, image.Rectangle, image.Point, error) {
+	w, h := area.Dx(), area.Dy()
+	if boxW < 0 || boxH < 0 {
+		return area, area, area.Size(), fmt.Errorf("invalid size %dx%d", boxW, boxH)
+	}
+	// thiếu một cạnh thì suy ra theo tỉ lệ nguồn
+	switch {
+	case boxW == 0 && boxH == 0:
+		return area, image.Rectangle{Max: area.Size()}, area.Size(), nil
+	case boxW == 0:
+		boxW = max(1, int(math.Round(float64(w)*float64(boxH)/float64(h))))
+	case boxH == 0:
+		boxH = max(1, int(math.Round(float64(h)*float64(boxW)/float64(w))))
+	}
+	box := image.Pt(boxW, boxH)
+	switch fit {
+	case "", FitInside:
+		ow, oh := fitInside(w, h, boxW, boxH)
+		return area, image.Rect(0, 0, ow, oh), image.Pt(ow, oh), nil
+	case FitFill:
+		return area, image.Rectangle{Max: box}, box, nil
+	case FitContain:
+		scale := math.Min(float64(boxW)/float64(w), float64(boxH)/float64(h))
+		sw, sh := max(1, int(math.Round(float64(w)*scale))), max(1, int(math.Round(float64(h)*scale)))
+		off := image.Pt((boxW-sw)/2, (boxH-sh)/2)
+		return area, image.Rectangle{Min: off, Max: off.Add(image.Pt(sw, sh))}, box, nil
+	case FitCover:
+		// cắt vùng nguồn ở giữa có cùng tỉ lệ với khung, rồi co giãn vùng đó lên toàn khung
+		scale := math.Max(float64(boxW)/float64(w), float64(boxH)/float64(h))
+		cw, ch := min(w, int(math.Round(float64(boxW)/scale))), min(h, int(math.Round(float64(boxH)/scale)))
+		origin := area.Min.Add(image.Pt((w-cw)/2, (h-ch)/2))
+		return image.Rectangle{Min: origin, Max: origin.Add(image.Pt(cw, ch))}, image.Rectangle{Max: box}, box, nil
+	}
+	return area, area, area.Size(), fmt.Errorf("unknown fit mode %q", fit)
+}
+
+func (f Filter) kernel() (draw.Interpolator, error) {
+	switch f {
+	case "", FilterLanczos:
+		return lanczos3, nil
+	case FilterCatmullRom:
+		return draw.CatmullRom, nil
+	case FilterBilinear:
+		return draw.BiLinear, nil
+	case FilterNearest:
+		return draw.NearestNeighbor, nil
+	}
+	return nil, fmt.Errorf("unknown resample filter %q", f)
+}
+
+func (o ImageOptions) background() color.Color {
+	if o.Background != nil {
+		return o.Background
+	}
+	if o.Format == "png" {
+		return color.Transparent
+	}
+	return color.White
+}
+
+// outputFormat chọn định dạng encode khi không chỉ định: PNG/GIF giữ alpha bằng PNG, còn lại JPEG
+func outputFormat(srcFormat string) string {
+	if srcFormat == "png" || srcFormat == "gif" {
+		return "png"
+	}
+	return "jpeg"
+}
+
+func decodeImage(path string) (image.Image, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	img, format, err := image.Decode(f)
+	if err != nil {
+		return nil, "", fmt.Errorf("decode image %s: %w", path, err)
+	}
+	return img, format, nil
+}
+
+func encodeImage(path string, img image.Image, opts ImageOptions) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	switch opts.Format {
+	case "jpeg":
+		err = jpeg.Encode(f, img, &jpeg.Options{Quality: opts.quality()})
+	case "png":
+		err = png.Encode(f, img)
+	default:
+		err = fmt.Errorf("unsupported output format %q", opts.Format)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("encode image %s: %w", path, err)
+	}
+	return nil
+}
+
+// encodeWebP ghi PNG tạm rồi dùng ffmpeg (libwebp) encode sang WebP vì Go chỉ có decoder WebP
+func encodeWebP(ctx context.Context, path string, img image.Image, quality int) error {
+	tmp := path + ".png"
+	if err := encodeImage(tmp, img, ImageOptions{Format: "png"}); err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	args := []string{"-i", tmp, "-frames:v", "1", "-c:v", "libwebp", "-quality", fmt.Sprint(quality), "-f", "webp", path}
+	if err := runFFmpeg(ctx, args, 0, nil); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("encode webp %s: %w", path, err)
+	}
 	return nil
 }
+
+func (o ImageOptions) quality() int {
+	if o.Quality <= 0 || o.Quality > 100 {
+		return defaultJPEGQuality
+	}
+	return o.Quality
+}
+
+// fitInside trả về kích thước lớn nhất nằm trong khung maxW x maxH giữ tỉ lệ, không phóng to
+func fitInside(w, h, maxW, maxH int) (int, int) {
+	scale := 1.0
+	if maxW > 0 && w > maxW {
+		scale = float64(maxW) / float64(w)
+	}
+	if maxH > 0 && h > maxH {
+		scale = min(scale, float64(maxH)/float64(h))
+	}
+	if scale == 1 {
+		return w, h
+	}
+	return max(1, int(float64(w)*scale+0.5)), max(1, int(float64(h)*scale+0.5))
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestPNG ghi một ảnh PNG w x h để làm input cho test
+func writeTestPNG(t *testing.T, w, h int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	path := filepath.Join(t.TempDir(), "in.png")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, png.Encode(f, img))
+	require.NoError(t, f.Close())
+	return path
+}
+
+// TestFitInside tests aspect-preserving downscale without upscaling
+func TestFitInside(t *testing.T) {
+	w, h := fitInside(4000, 3000, 640, 640)
+	assert.Equal(t, []int{640, 480}, []int{w, h})
+	w, h = fitInside(300, 200, 640, 640)
+	assert.Equal(t, []int{300, 200}, []int{w, h})
+	w, h = fitInside(1000, 2000, 0, 500)
+	assert.Equal(t, []int{250, 500}, []int{w, h})
+}
+
+// TestProcessImage tests resizing and encoding to JPEG
+func TestProcessImage(t *testing.T) {
+	in := writeTestPNG(t, 200, 100)
+	out := filepath.Join(t.TempDir(), "out.jpg")
+
+	res, err := NewDefaultImageProcessor().ProcessImage(context.Background(), in, out, ImageOptions{Width: 50, Height: 50, Format: "jpeg"})
+	require.NoError(t, err)
+	assert.Equal(t, &ImageResult{Width: 50, Height: 25, Format: "jpeg"}, res)
+
+	f, err := os.Open(out)
+	require.NoError(t, err)
+	defer f.Close()
+	cfg, format, err := image.DecodeConfig(f)
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, 50, cfg.Width)
+}
+
+// TestResizePlan tests source/destination geometry of every fit mode
+func TestResizePlan(t *testing.T) {
+	area := image.Rect(0, 0, 400, 200)
+	tests := []struct {
+		fit  FitMode
+		w, h int
+		src  image.Rectangle
+		dst  image.Rectangle
+		out  image.Point
+	}{
+		{FitInside, 100, 100, area, image.Rect(0, 0, 100, 50), image.Pt(100, 50)},
+		{FitInside, 800, 800, area, image.Rect(0, 0, 400, 200), image.Pt(400, 200)},
+		{FitContain, 100, 100, area, image.Rect(0, 25, 100, 75), image.Pt(100, 100)},
+		{FitCover, 100, 100, image.Rect(100, 0, 300, 200), image.Rect(0, 0, 100, 100), image.Pt(100, 100)},
+		{FitFill, 100, 100, area, image.Rect(0, 0, 100, 100), image.Pt(100, 100)},
+		{FitCover, 200, 0, area, image.Rect(0, 0, 200, 100), image.Pt(200, 100)},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.fit), func(t *testing.T) {
+			src, dst, out, err := resizePlan(area, tt.w, tt.h, tt.fit)
+			require.NoError(t, err)
+			assert.Equal(t, tt.src, src)
+			assert.Equal(t, tt.dst, dst)
+			assert.Equal(t, tt.out, out)
+		})
+	}
+	_, _, _, err := resizePlan(area, 10, 10, "stretch")
+	assert.Error(t, err)
+}
+
+// TestProcessImageCoverCrop tests crop followed by cover into an exact box, encoded as PNG
+func TestProcessImageCoverCrop(t *testing.T) {
+	in := writeTestPNG(t, 200, 100)
+	out := filepath.Join(t.TempDir(), "out.png")
+
+	crop := image.Rect(10, 10, 110, 90)
+	res, err := NewDefaultImageProcessor().ProcessImage(context.Background(), in, out, ImageOptions{
+		Width: 40, Height: 40, Fit: FitCover, Crop: &crop, Filter: FilterCatmullRom,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &ImageResult{Width: 40, Height: 40, Format: "png"}, res)
+
+	outside := image.Rect(500, 500, 600, 600)
+	_, err = NewDefaultImageProcessor().ProcessImage(context.Background(), in, out, ImageOptions{Crop: &outside})
+	assert.Error(t, err)
+}
@@ -0,0 +1,184 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"photo-go/config"
+)
+
+// Quality là một bậc của quality ladder, bitrate tính bằng kbps
+type Quality config.QualityRung
+
+// Ladder là danh sách rung sắp xếp theo chiều cao tăng dần
+type Ladder []Quality
+
+var levelPattern = regexp.MustCompile(`^[1-6](\.[0-2])?$`)
+
+// DefaultLadder là ladder mặc định khi settings không cấu hình QUALITY_LADDER
+func DefaultLadder() Ladder {
+	l, _ := NewLadder([]config.QualityRung{
+		{Name: "360p", Width: 640, Height: 360, VideoBitrate: 800, AudioBitrate: 96, Profile: "main", Level: "3.0"},
+		{Name: "480p", Width: 854, Height: 480, VideoBitrate: 1400, AudioBitrate: 128, Profile: "main", Level: "3.1"},
+		{Name: "720p", Width: 1280, Height: 720, VideoBitrate: 2800, AudioBitrate: 128, Profile: "main", Level: "3.1"},
+		{Name: "1080p", Width: 1920, Height: 1080, VideoBitrate: 5000, AudioBitrate: 192, Profile: "high", Level: "4.0"},
+	})
+	return l
+}
+
+// NewLadder điền giá trị mặc định, validate và sắp xếp ladder từ settings.
+// rungs rỗng trả về DefaultLadder.
+func NewLadder(rungs []config.QualityRung) (Ladder, error) {
+	if len(rungs) == 0 {
+		return DefaultLadder(), nil
+	}
+	l := make(Ladder, 0, len(rungs))
+	seen := map[string]bool{}
+	for i, r := range rungs {
+		q := Quality(r)
+		q.Profile = strings.ToLower(q.Profile)
+		if q.Profile == "" {
+			q.Profile = "main"
+		}
+		if q.MaxRate == 0 {
+			q.MaxRate = q.VideoBitrate * 107 / 100
+		}
+		if q.BufSize == 0 {
+			q.BufSize = q.MaxRate * 3 / 2
+		}
+		if q.AudioBitrate == 0 {
+			q.AudioBitrate = 128
+		}
+		if err := q.validate(); err != nil {
+			return nil, fmt.Errorf("quality ladder rung %d: %w", i, err)
+		}
+		if seen[q.Name] {
+			return nil, fmt.Errorf("quality ladder rung %d: duplicate name %q", i, q.Name)
+		}
+		seen[q.Name] = true
+		l = append(l, q)
+	}
+	sort.SliceStable(l, func(i, j int) bool { return l[i].Height < l[j].Height })
+	return l, nil
+}
+
+func (q Quality) validate() error {
+	switch {
+	case q.Name == "":
+		return fmt.Errorf("name is required")
+	case q.Height <= 0 || q.Height%2 != 0:
+		return fmt.Errorf("%s: height must be a positive even number", q.Name)
+	case q.Width < 0 || q.Width%2 != 0:
+		return fmt.Errorf("%s: width must be 0 or a positive even number", q.Name)
+	case q.VideoBitrate <= 0:
+		return fmt.Errorf("%s: video bitrate must be positive", q.Name)
+	case q.MaxRate < q.VideoBitrate:
+		return fmt.Errorf("%s: maxrate must be >= video bitrate", q.Name)
+	case q.BufSize <= 0:
+		return fmt.Errorf("%s: bufsize must be positive", q.Name)
+	case q.AudioBitrate <= 0:
+		return fmt.Errorf("%s: audio bitrate must be positive", q.Name)
+	case q.Profile != "baseline" && q.Profile != "main" && q.Profile != "high":
+		return fmt.Errorf("%s: unsupported profile %q", q.Name, q.Profile)
+	case q.Level != "" && !levelPattern.MatchString(q.Level):
+		return fmt.Errorf("%s: invalid level %q", q.Name, q.Level)
+	}
+	return nil
+}
+
+// Select trả về các rung theo tên; names rỗng trả về toàn bộ ladder
+func (l Ladder) Select(names []string) (Ladder, error) {
+	if len(names) == 0 {
+		return l, nil
+	}
+	want := map[string]bool{}
+	for _, n := range names {
+		want[n] = true
+	}
+	out := make(Ladder, 0, len(names))
+	for _, q := range l {
+		if want[q.Name] {
+			out = append(out, q)
+			delete(want, q.Name)
+		}
+	}
+	for n := range want {
+		return nil, fmt.Errorf("unknown quality %q", n)
+	}
+	return out, nil
+}
+
+// ForSource bỏ các rung lớn hơn video nguồn để không upscale.
+// Nếu nguồn nhỏ hơn mọi rung, giữ rung thấp nhất nhưng hạ xuống đúng độ phân giải nguồn.
+// Video dọc được so sánh theo cạnh ngắn và đảo chiều rộng/cao của rung.
+func (l Ladder) ForSource(width, height int) Ladder {
+	if width <= 0 || height <= 0 || len(l) == 0 {
+		return l
+	}
+	portrait := height > width
+	short := height
+	if portrait {
+		short = width
+	}
+	out := make(Ladder, 0, len(l))
+	for _, q := range l {
+		if q.Height > short {
+			continue
+		}
+		if portrait {
+			q.Width, q.Height = q.Height, q.Width
+		}
+		out = append(out, q)
+	}
+	if len(out) == 0 {
+		q := l[0]
+		q.Name = fmt.Sprintf("%dp", short&^1)
+		q.Width, q.Height = width&^1, height&^1
+		out = append(out, q)
+	}
+	return out
+}
+
+// scaleFilter trả về filter scale giữ tỉ lệ khung hình, kích thước luôn chẵn.
+// Cạnh bằng 0 được tính theo tỉ lệ nguồn.
+func (q Quality) scaleFilter() string {
+	switch {
+	case q.Width > 0 && q.Height > 0:
+		return fmt.Sprintf("scale=w=%d:h=%d:force_original_aspect_ratio=decrease:force_divisible_by=2", q.Width, q.Height)
+	case q.Width > 0:
+		return fmt.Sprintf("scale=w=%d:h=-2", q.Width)
+	default:
+		return fmt.Sprintf("scale=w=-2:h=%d", q.Height)
+	}
+}
+
+// encoderArgs trả về tham số encode video/audio cho rung.
+// stream >= 0 gắn stream specifier (vd -b:v:1) khi encode nhiều output trong một lệnh.
+func (q Quality) encoderArgs(stream int, withAudio bool) []string {
+	opt := func(name, kind string) string {
+		if stream < 0 {
+			return fmt.Sprintf("-%s:%s", name, kind)
+		}
+		return fmt.Sprintf("-%s:%s:%d", name, kind, stream)
+	}
+	args := []string{
+		opt("c", "v"), "libx264",
+		opt("profile", "v"), q.Profile,
+		opt("b", "v"), fmt.Sprintf("%dk", q.VideoBitrate),
+		opt("maxrate", "v"), fmt.Sprintf("%dk", q.MaxRate),
+		opt("bufsize", "v"), fmt.Sprintf("%dk", q.BufSize),
+	}
+	if q.Level != "" {
+		args = append(args, opt("level", "v"), q.Level)
+	}
+	if !withAudio {
+		return args
+	}
+	return append(args,
+		opt("c", "a"), "aac",
+		opt("b", "a"), fmt.Sprintf("%dk", q.AudioBitrate),
+		opt("ar", "a"), "48000",
+	)
+}
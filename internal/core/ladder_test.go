@@ -0,0 +1,95 @@
+package core
+
+import (
+	"testing"
+
+	"photo-go/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewLadderDefaults tests default filling, sorting and the built-in ladder
+func TestNewLadderDefaults(t *testing.T) {
+	l, err := NewLadder([]config.QualityRung{
+		{Name: "720p", Height: 720, VideoBitrate: 2800},
+		{Name: "360p", Height: 360, VideoBitrate: 800, Profile: "Baseline"},
+	})
+	require.NoError(t, err)
+	require.Len(t, l, 2)
+	assert.Equal(t, "360p", l[0].Name)
+	assert.Equal(t, "baseline", l[0].Profile)
+	assert.Equal(t, 2996, l[1].MaxRate)
+	assert.Equal(t, 4494, l[1].BufSize)
+	assert.Equal(t, 128, l[1].AudioBitrate)
+	assert.Equal(t, "main", l[1].Profile)
+
+	def, err := NewLadder(nil)
+	require.NoError(t, err)
+	assert.Len(t, def, 4)
+}
+
+// TestNewLadderValidation tests rejection of invalid rungs
+func TestNewLadderValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		rung config.QualityRung
+	}{
+		{"missing name", config.QualityRung{Height: 360, VideoBitrate: 800}},
+		{"odd height", config.QualityRung{Name: "a", Height: 361, VideoBitrate: 800}},
+		{"no bitrate", config.QualityRung{Name: "a", Height: 360}},
+		{"maxrate below bitrate", config.QualityRung{Name: "a", Height: 360, VideoBitrate: 800, MaxRate: 500}},
+		{"bad profile", config.QualityRung{Name: "a", Height: 360, VideoBitrate: 800, Profile: "high10"}},
+		{"bad level", config.QualityRung{Name: "a", Height: 360, VideoBitrate: 800, Level: "31"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLadder([]config.QualityRung{tt.rung})
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := NewLadder([]config.QualityRung{
+		{Name: "a", Height: 360, VideoBitrate: 800},
+		{Name: "a", Height: 480, VideoBitrate: 1400},
+	})
+	assert.Error(t, err)
+}
+
+// TestLadderForSource tests that renditions never upscale the source
+func TestLadderForSource(t *testing.T) {
+	l := DefaultLadder()
+
+	landscape := l.ForSource(1280, 720)
+	assert.Equal(t, []string{"360p", "480p", "720p"}, ladderNames(landscape))
+
+	portrait := l.ForSource(720, 1280)
+	require.Len(t, portrait, 3)
+	assert.Equal(t, 360, portrait[0].Width)
+	assert.Equal(t, 640, portrait[0].Height)
+
+	tiny := l.ForSource(321, 241)
+	require.Len(t, tiny, 1)
+	assert.Equal(t, "240p", tiny[0].Name)
+	assert.Equal(t, 320, tiny[0].Width)
+	assert.Equal(t, 240, tiny[0].Height)
+}
+
+// TestLadderSelect tests selecting rungs by name
+func TestLadderSelect(t *testing.T) {
+	l := DefaultLadder()
+	sel, err := l.Select([]string{"720p", "360p"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"360p", "720p"}, ladderNames(sel))
+
+	_, err = l.Select([]string{"4k"})
+	assert.Error(t, err)
+}
+
+func ladderNames(l Ladder) []string {
+	names := make([]string, 0, len(l))
+	for _, q := range l {
+		names = append(names, q.Name)
+	}
+	return names
+}
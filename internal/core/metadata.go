@@ -0,0 +1,158 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// Cờ metadata trong chunk VP8X của WebP
+const (
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+// SanitizeMetadata ghi lại ảnh src sang dst không re-encode, metadata lọc theo p:
+//   - JPEG: thay EXIF bằng p.Apply(e) (e đã đọc trước, nil khi không có hoặc hỏng), bỏ XMP/IPTC (APP1/APP13)
+//   - PNG: lọc chunk eXIf theo p, bỏ tEXt/zTXt/iTXt (XMP và text tự do)
+//   - WebP: lọc chunk EXIF theo p, bỏ chunk XMP
+//
+// Trả về false khi không ghi dst: policy keep_all hoặc GIF (không có EXIF, giữ nguyên file).
+func SanitizeMetadata(src, dst, format string, p MetadataPolicy, e *EXIF) (bool, error) {
+	if p == MetadataKeepAll {
+		return false, nil
+	}
+	var rewrite func(in []byte) ([]byte, error)
+	switch format {
+	case "jpeg":
+		return true, WriteJPEGMetadata(src, dst, p.Apply(e))
+	case "png":
+		rewrite = func(in []byte) ([]byte, error) { return replacePNGMetadata(in, p) }
+	case "webp":
+		rewrite = func(in []byte) ([]byte, error) { return replaceWebPMetadata(in, p) }
+	default:
+		return false, nil
+	}
+	in, err := os.ReadFile(src)
+	if err != nil {
+		return false, err
+	}
+	out, err := rewrite(in)
+	if err != nil {
+		return false, fmt.Errorf("rewrite metadata %s: %w", src, err)
+	}
+	return true, os.WriteFile(dst, out, 0o644)
+}
+
+// filterEXIF parse EXIF thô (TIFF, có thể kèm header "Exif\0\0") rồi lọc theo p.
+// EXIF hỏng trả về nil để bị bỏ hẳn thay vì giữ nguyên.
+func filterEXIF(raw []byte, p MetadataPolicy) []byte {
+	e, err := parseTIFF(bytes.TrimPrefix(raw, exifHeader))
+	if err != nil {
+		return nil
+	}
+	if e = p.Apply(e); e == nil {
+		return nil
+	}
+	return encodeTIFF(e)
+}
+
+func replacePNGMetadata(in []byte, p MetadataPolicy) ([]byte, error) {
+	if !bytes.HasPrefix(in, pngSignature) {
+		return nil, errors.New("not a PNG")
+	}
+	var out bytes.Buffer
+	out.Write(pngSignature)
+	for rest := in[len(pngSignature):]; ; {
+		if len(rest) < 12 {
+			return nil, errors.New("truncated PNG chunk")
+		}
+		n := binary.BigEndian.Uint32(rest)
+		if uint64(n)+12 > uint64(len(rest)) {
+			return nil, errors.New("invalid PNG chunk length")
+		}
+		typ := string(rest[4:8])
+		chunk := rest[:12+n]
+		rest = rest[12+n:]
+		switch typ {
+		case "tEXt", "zTXt", "iTXt":
+			continue
+		case "eXIf":
+			if tiff := filterEXIF(chunk[8:8+n], p); tiff != nil {
+				writePNGChunk(&out, "eXIf", tiff)
+			}
+			continue
+		}
+		out.Write(chunk)
+		if typ == "IEND" {
+			return out.Bytes(), nil
+		}
+	}
+}
+
+func writePNGChunk(w *bytes.Buffer, typ string, data []byte) {
+	w.Write(binary.BigEndian.AppendUint32(nil, uint32(len(data))))
+	body := append([]byte(typ), data...)
+	w.Write(body)
+	w.Write(binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(body)))
+}
+
+func replaceWebPMetadata(in []byte, p MetadataPolicy) ([]byte, error) {
+	if len(in) < 12 || string(in[:4]) != "RIFF" || string(in[8:12]) != "WEBP" {
+		return nil, errors.New("not a WebP")
+	}
+	out := append([]byte{}, in[:12]...)
+	vp8x := -1 // vị trí payload VP8X trong out để sửa cờ sau cùng
+	hasEXIF := false
+	for rest := in[12:]; len(rest) > 0; {
+		if len(rest) < 8 {
+			return nil, errors.New("truncated WebP chunk")
+		}
+		fourcc := string(rest[:4])
+		n := binary.LittleEndian.Uint32(rest[4:8])
+		size := uint64(n) + uint64(n&1) // chunk được đệm tới số byte chẵn
+		if 8+size > uint64(len(rest)) {
+			return nil, errors.New("invalid WebP chunk length")
+		}
+		chunk := rest[:8+size]
+		rest = rest[8+size:]
+		switch fourcc {
+		case "XMP ":
+			continue
+		case "EXIF":
+			if tiff := filterEXIF(chunk[8:8+n], p); tiff != nil {
+				out = appendWebPChunk(out, "EXIF", tiff)
+				hasEXIF = true
+			}
+			continue
+		case "VP8X":
+			if n > 0 {
+				vp8x = len(out) + 8
+			}
+		}
+		out = append(out, chunk...)
+	}
+	if vp8x >= 0 {
+		out[vp8x] &^= webpFlagXMP
+		if !hasEXIF {
+			out[vp8x] &^= webpFlagEXIF
+		}
+	}
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, nil
+}
+
+func appendWebPChunk(out []byte, fourcc string, data []byte) []byte {
+	out = append(out, fourcc...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(data)))
+	out = append(out, data...)
+	if len(data)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
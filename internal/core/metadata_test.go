@@ -0,0 +1,118 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var xmpWithGPS = []byte(`<x:xmpmeta><exif:GPSLatitude>21,1.71N</exif:GPSLatitude></x:xmpmeta>`)
+
+func jpegSegment(marker byte, data []byte) []byte {
+	return append([]byte{0xFF, marker, byte((len(data) + 2) >> 8), byte(len(data) + 2)}, data...)
+}
+
+// TestSanitizeMetadataCorruptEXIF tests that a JPEG whose EXIF cannot be parsed still loses its EXIF, XMP and IPTC
+func TestSanitizeMetadataCorruptEXIF(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil))
+	plain := buf.Bytes()
+	var in []byte
+	in = append(in, plain[:2]...)
+	in = append(in, jpegSegment(0xE1, append(append([]byte{}, exifHeader...), "II*\x00\xff\xff\xff\x7f"...))...)
+	in = append(in, jpegSegment(0xE1, append([]byte("http://ns.adobe.com/xap/1.0/\x00"), xmpWithGPS...))...)
+	in = append(in, jpegSegment(0xED, []byte("Photoshop 3.0\x00GPS"))...)
+	in = append(in, plain[2:]...)
+
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "in.jpg"), filepath.Join(dir, "out.jpg")
+	require.NoError(t, os.WriteFile(src, in, 0o600))
+	e, err := ReadEXIF(src)
+	require.Error(t, err)
+	require.Nil(t, e)
+
+	ok, err := SanitizeMetadata(src, dst, "jpeg", MetadataStripGPS, e)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	out, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(out, exifHeader))
+	assert.False(t, bytes.Contains(out, []byte("xmpmeta")))
+	assert.False(t, bytes.Contains(out, []byte("Photoshop")))
+	_, err = jpeg.Decode(bytes.NewReader(out))
+	assert.NoError(t, err)
+
+	ok, err = SanitizeMetadata(src, dst, "jpeg", MetadataKeepAll, e)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// TestSanitizePNGMetadata tests that eXIf is filtered by the policy and text chunks are dropped
+func TestSanitizePNGMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))))
+	plain := buf.Bytes()
+	iend := len(plain) - 12
+	var chunks bytes.Buffer
+	writePNGChunk(&chunks, "eXIf", encodeTIFF(&EXIF{Make: "Canon", Orientation: 6, GPS: &GPS{Latitude: 21, Longitude: 105}}))
+	writePNGChunk(&chunks, "iTXt", append([]byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00"), xmpWithGPS...))
+	writePNGChunk(&chunks, "tEXt", []byte("Comment\x00home"))
+	in := append(append(append([]byte{}, plain[:iend]...), chunks.Bytes()...), plain[iend:]...)
+
+	out, err := replacePNGMetadata(in, MetadataStripGPS)
+	require.NoError(t, err)
+	_, err = png.Decode(bytes.NewReader(out)) // kiểm cả CRC của chunk ghi lại
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(out, []byte("xmpmeta")))
+	assert.False(t, bytes.Contains(out, []byte("tEXt")))
+	i := bytes.Index(out, []byte("eXIf"))
+	require.Positive(t, i)
+	n := binary.BigEndian.Uint32(out[i-4:])
+	e, err := parseTIFF(out[i+4 : i+4+int(n)])
+	require.NoError(t, err)
+	assert.Equal(t, "Canon", e.Make)
+	assert.Equal(t, 6, e.Orientation)
+	assert.Nil(t, e.GPS)
+
+	out, err = replacePNGMetadata(in, MetadataStripAll)
+	require.NoError(t, err)
+	assert.True(t, bytes.Contains(out, []byte("eXIf")), "orientation is kept")
+	assert.False(t, bytes.Contains(out, []byte("Canon")))
+}
+
+// TestSanitizeWebPMetadata tests that the XMP chunk is dropped, EXIF is filtered and VP8X flags and RIFF size follow
+func TestSanitizeWebPMetadata(t *testing.T) {
+	in := []byte("RIFF\x00\x00\x00\x00WEBP")
+	in = appendWebPChunk(in, "VP8X", []byte{webpFlagEXIF | webpFlagXMP, 0, 0, 0, 3, 0, 0, 3, 0, 0})
+	in = appendWebPChunk(in, "VP8L", []byte{0x2f, 3, 0xc0, 0, 7}) // payload lẻ để có byte đệm
+	in = appendWebPChunk(in, "EXIF", append(append([]byte{}, exifHeader...), encodeTIFF(&EXIF{Model: "X100", GPS: &GPS{Latitude: 1, Longitude: 2}})...))
+	in = appendWebPChunk(in, "XMP ", xmpWithGPS)
+	binary.LittleEndian.PutUint32(in[4:8], uint32(len(in)-8))
+
+	out, err := replaceWebPMetadata(in, MetadataStripGPS)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(len(out)-8), binary.LittleEndian.Uint32(out[4:8]))
+	assert.Equal(t, byte(webpFlagEXIF), out[20])
+	assert.False(t, bytes.Contains(out, []byte("xmpmeta")))
+	i := bytes.Index(out, []byte("EXIF"))
+	require.Positive(t, i)
+	n := binary.LittleEndian.Uint32(out[i+4:])
+	e, err := parseTIFF(out[i+8 : i+8+int(n)])
+	require.NoError(t, err)
+	assert.Equal(t, "X100", e.Model)
+	assert.Nil(t, e.GPS)
+
+	out, err = replaceWebPMetadata(in, MetadataStripAll)
+	require.NoError(t, err)
+	assert.Zero(t, out[20])
+	assert.False(t, bytes.Contains(out, []byte("EXIF")))
+	assert.True(t, bytes.Contains(out, []byte("VP8L")))
+}
@@ -0,0 +1,214 @@
+package core
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Placeholder là dữ liệu hiển thị tức thì trước khi thumbnail tải xong
+type Placeholder struct {
+	BlurHash string   // https://blurha.sh
+	Colors   []string // màu chủ đạo dạng #rrggbb, nhiều pixel nhất trước
+}
+
+const (
+	// placeholderSize là cạnh dài của ảnh thu nhỏ dùng để tính placeholder, đủ cho BlurHash 4x3
+	placeholderSize = 64
+	// placeholderColors là số màu chủ đạo tối đa
+	placeholderColors = 5
+	// colorMinDistance là khoảng cách RGB tối thiểu giữa hai màu trong palette để tránh màu gần trùng
+	colorMinDistance = 48
+)
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// ComputePlaceholder đọc ảnh (jpeg/png/gif/webp) và tính BlurHash cùng palette màu chủ đạo.
+// Nên truyền variant nhỏ nhất thay vì ảnh gốc để giảm thời gian decode.
+func ComputePlaceholder(path string) (*Placeholder, error) {
+	src, _, err := decodeImage(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewPlaceholder(src)
+}
+
+// NewPlaceholder tính placeholder cho ảnh đã decode, component BlurHash theo hướng ảnh (4x3 hoặc 3x4)
+func NewPlaceholder(src image.Image) (*Placeholder, error) {
+	b := src.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 {
+		return nil, fmt.Errorf("empty image")
+	}
+	w, h := fitInside(b.Dx(), b.Dy(), placeholderSize, placeholderSize)
+	small := image.NewNRGBA(image.Rect(0, 0, max(w, 1), max(h, 1)))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), src, b, draw.Src, nil)
+
+	xComp, yComp := 4, 3
+	if h > w {
+		xComp, yComp = 3, 4
+	}
+	hash, err := BlurHash(small, xComp, yComp)
+	if err != nil {
+		return nil, err
+	}
+	return &Placeholder{BlurHash: hash, Colors: DominantColors(small, placeholderColors)}, nil
+}
+
+// BlurHash mã hóa ảnh thành chuỗi BlurHash với xComp x yComp component (1-9 mỗi chiều)
+func BlurHash(img image.Image, xComp, yComp int) (string, error) {
+	if xComp < 1 || xComp > 9 || yComp < 1 || yComp > 9 {
+		return "", fmt.Errorf("blurhash components must be in 1..9, got %dx%d", xComp, yComp)
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= 0 || h <= 0 {
+		return "", fmt.Errorf("empty image")
+	}
+
+	// chuyển sang linear RGB một lần, tránh đổi lại ở mỗi component
+	linear := make([][3]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			linear[y*w+x] = [3]float64{srgbToLinear(c.R), srgbToLinear(c.G), srgbToLinear(c.B)}
+		}
+	}
+	factors := make([][3]float64, 0, xComp*yComp)
+	for j := 0; j < yComp; j++ {
+		for i := 0; i < xComp; i++ {
+			norm := 2.0
+			if i == 0 && j == 0 {
+				norm = 1
+			}
+			var f [3]float64
+			for y := 0; y < h; y++ {
+				by := math.Cos(math.Pi * float64(j) * float64(y) / float64(h))
+				for x := 0; x < w; x++ {
+					basis := by * math.Cos(math.Pi*float64(i)*float64(x)/float64(w))
+					p := linear[y*w+x]
+					f[0] += basis * p[0]
+					f[1] += basis * p[1]
+					f[2] += basis * p[2]
+				}
+			}
+			scale := norm / float64(w*h)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var sb strings.Builder
+	encodeBase83(&sb, (xComp-1)+(yComp-1)*9, 1)
+	dc, ac := factors[0], factors[1:]
+	maxValue := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = max(actualMax, math.Abs(f[0]), math.Abs(f[1]), math.Abs(f[2]))
+		}
+		quantised := int(max(0, min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantised+1) / 166
+		encodeBase83(&sb, quantised, 1)
+	} else {
+		encodeBase83(&sb, 0, 1)
+	}
+	encodeBase83(&sb, linearToSRGB(dc[0])<<16|linearToSRGB(dc[1])<<8|linearToSRGB(dc[2]), 4)
+	for _, f := range ac {
+		q := func(v float64) int {
+			return int(max(0, min(18, math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+		}
+		encodeBase83(&sb, q(f[0])*19*19+q(f[1])*19+q(f[2]), 2)
+	}
+	return sb.String(), nil
+}
+
+// DominantColors trả về tối đa n màu chủ đạo, gom pixel theo ô màu 5 bit/kênh và bỏ pixel gần trong suốt
+func DominantColors(img image.Image, n int) []string {
+	type bucket struct {
+		r, g, b, count int
+	}
+	buckets := map[uint32]*bucket{}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A < 128 {
+				continue
+			}
+			key := uint32(c.R>>3)<<10 | uint32(c.G>>3)<<5 | uint32(c.B>>3)
+			bk := buckets[key]
+			if bk == nil {
+				bk = &bucket{}
+				buckets[key] = bk
+			}
+			bk.r += int(c.R)
+			bk.g += int(c.G)
+			bk.b += int(c.B)
+			bk.count++
+		}
+	}
+	sorted := make([]*bucket, 0, len(buckets))
+	for _, bk := range buckets {
+		sorted = append(sorted, bk)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].r+sorted[i].g+sorted[i].b < sorted[j].r+sorted[j].g+sorted[j].b
+	})
+
+	var picked [][3]int
+	out := make([]string, 0, n)
+	for _, bk := range sorted {
+		if len(out) == n {
+			break
+		}
+		c := [3]int{bk.r / bk.count, bk.g / bk.count, bk.b / bk.count}
+		distinct := true
+		for _, p := range picked {
+			dr, dg, db := c[0]-p[0], c[1]-p[1], c[2]-p[2]
+			if dr*dr+dg*dg+db*db < colorMinDistance*colorMinDistance {
+				distinct = false
+				break
+			}
+		}
+		if !distinct {
+			continue
+		}
+		picked = append(picked, c)
+		out = append(out, fmt.Sprintf("#%02x%02x%02x", c[0], c[1], c[2]))
+	}
+	return out
+}
+
+func encodeBase83(sb *strings.Builder, v, length int) {
+	for i := 1; i <= length; i++ {
+		digit := v / int(math.Pow(83, float64(length-i))) % 83
+		sb.WriteByte(base83Chars[digit])
+	}
+}
+
+func srgbToLinear(c uint8) float64 {
+	v := float64(c) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = max(0, min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func solidImage(w, h int, c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// TestBlurHash tests the encoder against hashes produced by the reference implementation
+func TestBlurHash(t *testing.T) {
+	hash, err := BlurHash(solidImage(16, 12, color.NRGBA{R: 255, A: 255}), 4, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "LRTI:j]9fQ]9|co1fQo1fQfQfQfQ", hash)
+
+	gradient := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			gradient.Set(x, y, color.NRGBA{R: uint8(x * 8), G: uint8(y * 8), B: 128, A: 255})
+		}
+	}
+	hash, err = BlurHash(gradient, 3, 4)
+	require.NoError(t, err)
+	assert.Equal(t, "TxH2cX2swxl}WDjtgJfjfQnmWpjt", hash)
+
+	_, err = BlurHash(gradient, 0, 3)
+	assert.Error(t, err)
+}
+
+// TestDominantColors tests that colors are ordered by coverage and near-duplicates are merged
+func TestDominantColors(t *testing.T) {
+	img := solidImage(10, 10, color.NRGBA{R: 200, G: 20, B: 20, A: 255})
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 10; x++ {
+			img.Set(x, y, color.NRGBA{R: 10, G: 30, B: 220, A: 255})
+		}
+	}
+	img.Set(9, 9, color.NRGBA{R: 205, G: 22, B: 20, A: 255})
+	img.Set(8, 9, color.NRGBA{A: 0})
+
+	assert.Equal(t, []string{"#c81414", "#0a1edc"}, DominantColors(img, 5))
+	assert.Equal(t, []string{"#c81414"}, DominantColors(img, 1))
+}
+
+// TestNewPlaceholder tests the component layout for portrait images
+func TestNewPlaceholder(t *testing.T) {
+	p, err := NewPlaceholder(solidImage(300, 600, color.NRGBA{G: 255, A: 255}))
+	require.NoError(t, err)
+	assert.Equal(t, byte('T'), p.BlurHash[0]) // 3x4: (3-1)+(4-1)*9 = 29
+	assert.Equal(t, []string{"#00ff00"}, p.Colors)
+}
@@ -0,0 +1,270 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Tên file preview trong thư mục output, upload cùng prefix với HLS
+const (
+	PosterName       = "poster.jpg"
+	PreviewTrackName = "thumbnails.vtt"
+	spritePattern    = "sprite_%03d.jpg"
+)
+
+// posterCandidates là các vị trí (tỉ lệ theo thời lượng) lấy frame ứng viên cho poster
+var posterCandidates = []float64{0.1, 0.25, 0.4, 0.55, 0.7}
+
+// posterMaxWidth giới hạn chiều rộng poster, nguồn 4K không cần poster full size
+const posterMaxWidth = 1920
+
+// PreviewOptions cấu hình poster và sprite sheet cho scrub preview
+type PreviewOptions struct {
+	Source    *MediaInfo // metadata đã probe của nguồn, nil = tự probe
+	Interval  float64    // giây giữa hai frame trong sprite
+	MaxFrames int        // Interval được nới ra để tổng số frame không vượt quá giá trị này, 0 = không giới hạn
+	Width     int        // chiều rộng một ô trong sprite, chiều cao theo tỉ lệ nguồn
+	Columns   int
+	Rows      int
+}
+
+// Previews là các file preview đã sinh, tên tương đối với outputDir
+type Previews struct {
+	Poster       string
+	PosterWidth  int
+	PosterHeight int
+	Sprites      []string
+	Track        string // WebVTT map thời gian -> vùng trong sprite, rỗng khi không sinh được sprite
+}
+
+// ThumbnailCue là một cue của WebVTT thumbnail track
+type ThumbnailCue struct {
+	Start, End float64 // giây
+	Image      string
+	X, Y, W, H int
+}
+
+// GeneratePreviews sinh poster, sprite sheet và WebVTT thumbnail track vào outputDir
+func (p *FFMPEGVideoProcessor) GeneratePreviews(ctx context.Context, inputPath, outputDir string, opts PreviewOptions) (*Previews, error) {
+	src := opts.Source
+	if src == nil {
+		var err error
+		if src, err = p.Prober.Probe(ctx, inputPath); err != nil {
+			return nil, err
+		}
+	}
+	if src.VideoCodec == "" || src.Width <= 0 || src.Height <= 0 {
+		return nil, fmt.Errorf("no video stream in %s", inputPath)
+	}
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create output dir: %w", err)
+	}
+	previews, err := extractPoster(ctx, inputPath, outputDir, src.Duration)
+	if err != nil {
+		return nil, err
+	}
+	if src.Duration <= 0 || opts.Interval <= 0 || opts.Width <= 0 {
+		return previews, nil
+	}
+	cues, err := generateSprites(ctx, inputPath, outputDir, src, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cues {
+		if n := len(previews.Sprites); n == 0 || previews.Sprites[n-1] != c.Image {
+			previews.Sprites = append(previews.Sprites, c.Image)
+		}
+	}
+	if len(cues) > 0 {
+		if err := WriteThumbnailTrack(filepath.Join(outputDir, PreviewTrackName), cues); err != nil {
+			return nil, err
+		}
+		previews.Track = PreviewTrackName
+	}
+	return previews, nil
+}
+
+// extractPoster lấy một frame đại diện (filter thumbnail) ở mỗi vị trí ứng viên
+// rồi chọn frame có độ tương phản cao nhất, tránh frame đen/trắng ở đoạn mở đầu hoặc chuyển cảnh
+func extractPoster(ctx context.Context, inputPath, outputDir string, duration float64) (*Previews, error) {
+	var (
+		best      string
+		bestScore = -1.0
+		bestSize  image.Point
+		tmp       []string
+	)
+	defer func() {
+		for _, f := range tmp {
+			if f != best {
+				os.Remove(f)
+			}
+		}
+	}()
+	for i, at := range posterCandidates {
+		out := filepath.Join(outputDir, fmt.Sprintf("poster_candidate_%d.jpg", i))
+		args := []string{
+			"-ss", fmt.Sprintf("%.3f", at*duration), "-i", inputPath,
+			"-vf", fmt.Sprintf("thumbnail=n=24,scale='min(%d,iw)':-2", posterMaxWidth),
+			"-frames:v", "1", "-q:v", "2", out,
+		}
+		if err := runFFmpeg(ctx, args, 0, nil); err != nil {
+			if ctx.Err() != nil {
+				return nil, context.Cause(ctx)
+			}
+			continue
+		}
+		tmp = append(tmp, out)
+		img, _, err := decodeImage(out)
+		if err != nil {
+			continue
+		}
+		if score := frameScore(img); score > bestScore {
+			best, bestScore, bestSize = out, score, img.Bounds().Size()
+		}
+		if duration <= 0 {
+			// không biết thời lượng thì mọi vị trí đều là 0
+			break
+		}
+	}
+	if best == "" {
+		return nil, fmt.Errorf("extract poster %s: no frame decoded", inputPath)
+	}
+	if err := os.Rename(best, filepath.Join(outputDir, PosterName)); err != nil {
+		return nil, err
+	}
+	return &Previews{Poster: PosterName, PosterWidth: bestSize.X, PosterHeight: bestSize.Y}, nil
+}
+
+// frameScore chấm điểm frame cho poster theo độ lệch chuẩn độ sáng (độ tương phản).
+// Frame gần như đen hoặc trắng toàn bộ bị phạt nặng nhưng vẫn được chọn nếu không còn frame khác.
+func frameScore(img image.Image) float64 {
+	b := img.Bounds()
+	step := max(1, max(b.Dx(), b.Dy())/64)
+	var sum, sumSq, n float64
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			l := float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+			sum += l
+			sumSq += l * l
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	mean := sum / n
+	std := math.Sqrt(max(0, sumSq/n-mean*mean))
+	if mean < 24 || mean > 232 {
+		return std / 10
+	}
+	return std
+}
+
+// generateSprites ghép frame cách đều Interval vào các sprite sheet Columns x Rows và trả về cue cho từng frame.
+// Chỉ decode keyframe (-skip_frame nokey) nên nhanh hơn nhiều so với decode toàn bộ video,
+// đổi lại vị trí frame lệch tối đa một GOP so với mốc thời gian.
+func generateSprites(ctx context.Context, inputPath, outputDir string, src *MediaInfo, opts PreviewOptions) ([]ThumbnailCue, error) {
+	interval := opts.Interval
+	if opts.MaxFrames > 0 && src.Duration/interval > float64(opts.MaxFrames) {
+		interval = src.Duration / float64(opts.MaxFrames)
+	}
+	cols, rows := max(opts.Columns, 1), max(opts.Rows, 1)
+	tileW := max(opts.Width&^1, 2)
+	tileH := max(int(math.Round(float64(tileW)*float64(src.Height)/float64(src.Width)))&^1, 2)
+	args := []string{
+		"-skip_frame", "nokey", "-i", inputPath, "-an",
+		"-vf", fmt.Sprintf("fps=%.6f,scale=%d:%d,tile=%dx%d", 1/interval, tileW, tileH, cols, rows),
+		"-vsync", "vfr", "-q:v", "4",
+		filepath.Join(outputDir, spritePattern),
+	}
+	if err := runFFmpeg(ctx, args, 0, nil); err != nil {
+		return nil, fmt.Errorf("generate sprites %s: %w", inputPath, err)
+	}
+	sheets, err := filepath.Glob(filepath.Join(outputDir, "sprite_*.jpg"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(sheets)
+	return spriteCues(src.Duration, interval, tileW, tileH, cols, rows, sheets), nil
+}
+
+// spriteCues chia thời lượng thành các cue Interval giây, cue thứ i nằm ở ô i của sprite sheet tương ứng.
+// Cue vượt quá số sheet thực tế bị bỏ.
+func spriteCues(duration, interval float64, tileW, tileH, cols, rows int, sheets []string) []ThumbnailCue {
+	frames := int(math.Ceil(duration / interval))
+	perSheet := cols * rows
+	cues := make([]ThumbnailCue, 0, frames)
+	for i := 0; i < frames; i++ {
+		sheet := i / perSheet
+		if sheet >= len(sheets) {
+			break
+		}
+		cell := i % perSheet
+		cues = append(cues, ThumbnailCue{
+			Start: float64(i) * interval,
+			End:   min(float64(i+1)*interval, duration),
+			Image: filepath.Base(sheets[sheet]),
+			X:     cell % cols * tileW,
+			Y:     cell / cols * tileH,
+			W:     tileW,
+			H:     tileH,
+		})
+	}
+	return cues
+}
+
+// WriteThumbnailTrack ghi WebVTT với payload dạng "sprite.jpg#xywh=x,y,w,h" mà các player dùng cho preview trên thanh seek
+func WriteThumbnailTrack(path string, cues []ThumbnailCue) error {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for _, c := range cues {
+		fmt.Fprintf(&b, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n", vttTime(c.Start), vttTime(c.End), c.Image, c.X, c.Y, c.W, c.H)
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
+
+// RewriteThumbnailTrack thay URI ảnh trong payload của từng cue, giữ nguyên fragment #xywh
+func RewriteThumbnailTrack(data []byte, rewrite func(uri string) (string, error)) ([]byte, error) {
+	var out bytes.Buffer
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	payload := false
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.Contains(line, "-->"):
+			payload = true
+		case strings.TrimSpace(line) == "":
+			payload = false
+		case payload:
+			uri, frag, _ := strings.Cut(line, "#")
+			u, err := rewrite(uri)
+			if err != nil {
+				return nil, err
+			}
+			line = u
+			if frag != "" {
+				line += "#" + frag
+			}
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func vttTime(sec float64) string {
+	ms := int64(math.Round(sec * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3_600_000, ms/60_000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSpriteCues tests cue timing and tile coordinates across sprite sheets
+func TestSpriteCues(t *testing.T) {
+	cues := spriteCues(23, 5, 160, 90, 2, 2, []string{"/tmp/out/sprite_001.jpg", "/tmp/out/sprite_002.jpg"})
+	require.Len(t, cues, 5)
+	assert.Equal(t, ThumbnailCue{Start: 0, End: 5, Image: "sprite_001.jpg", X: 0, Y: 0, W: 160, H: 90}, cues[0])
+	assert.Equal(t, ThumbnailCue{Start: 15, End: 20, Image: "sprite_001.jpg", X: 160, Y: 90, W: 160, H: 90}, cues[3])
+	assert.Equal(t, ThumbnailCue{Start: 20, End: 23, Image: "sprite_002.jpg", X: 0, Y: 0, W: 160, H: 90}, cues[4])
+
+	// cue không có sheet tương ứng bị bỏ
+	assert.Len(t, spriteCues(23, 5, 160, 90, 2, 2, []string{"sprite_001.jpg"}), 4)
+}
+
+// TestThumbnailTrack tests the WebVTT output and rewriting of sprite URIs
+func TestThumbnailTrack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), PreviewTrackName)
+	require.NoError(t, WriteThumbnailTrack(path, []ThumbnailCue{
+		{Start: 0, End: 5, Image: "sprite_001.jpg", W: 160, H: 90},
+		{Start: 3725.5, End: 3730, Image: "sprite_001.jpg", X: 160, W: 160, H: 90},
+	}))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `WEBVTT
+
+00:00:00.000 --> 00:00:05.000
+sprite_001.jpg#xywh=0,0,160,90
+
+01:02:05.500 --> 01:02:10.000
+sprite_001.jpg#xywh=160,0,160,90
+`, string(data))
+
+	out, err := RewriteThumbnailTrack(data, func(uri string) (string, error) {
+		return "/v1/media/stream/7/" + uri, nil
+	})
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "\n/v1/media/stream/7/sprite_001.jpg#xywh=160,0,160,90\n")
+	assert.True(t, strings.HasPrefix(string(out), "WEBVTT\n\n00:00:00.000 --> 00:00:05.000\n"))
+}
+
+// TestFrameScore tests that flat or black frames rank below a detailed frame
+func TestFrameScore(t *testing.T) {
+	black := solidImage(64, 36, color.Black)
+	gray := solidImage(64, 36, color.Gray{Y: 128})
+	stripes := image.NewGray(image.Rect(0, 0, 64, 36))
+	for y := 0; y < 36; y++ {
+		for x := 0; x < 64; x++ {
+			stripes.SetGray(x, y, color.Gray{Y: uint8(40 + x%2*160)})
+		}
+	}
+	dark := image.NewGray(image.Rect(0, 0, 64, 36))
+	for x := 0; x < 64; x++ {
+		dark.SetGray(x, 0, color.Gray{Y: 255})
+	}
+
+	assert.Zero(t, frameScore(black))
+	assert.Zero(t, frameScore(gray))
+	assert.Greater(t, frameScore(stripes), frameScore(dark))
+	assert.Greater(t, frameScore(dark), frameScore(black))
+}
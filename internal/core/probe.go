@@ -0,0 +1,184 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif" // đăng ký decoder cho image.DecodeConfig
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// MediaInfo là metadata của file nguồn.
+// Width/Height là kích thước hiển thị, đã áp Rotation.
+type MediaInfo struct {
+	Container     string
+	Duration      float64 // giây
+	Width         int
+	Height        int
+	FrameRate     float64
+	VideoCodec    string
+	AudioCodec    string
+	Bitrate       int64 // bit/s
+	AudioChannels int
+	Rotation      int // 0, 90, 180, 270 theo chiều kim đồng hồ
+}
+
+// Prober định nghĩa interface đọc metadata của file media
+
+type Prober interface {
+	Probe(ctx context.Context, path string) (*MediaInfo, error)
+}
+
+// FFProbeProber đọc ảnh tĩnh bằng image decoder, còn lại dùng ffprobe
+
+type FFProbeProber struct{}
+
+func NewFFProbeProber() *FFProbeProber {
+	return &FFProbeProber{}
+}
+
+func (p *FFProbeProber) Probe(ctx context.Context, path string) (*MediaInfo, error) {
+	if info, err := probeImage(path); err == nil {
+		return info, nil
+	}
+	cmd := exec.CommandContext(ctx, "ffprobe", "-v", "error", "-print_format", "json", "-show_format", "-show_streams", path)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe error (%s): %v", path, err)
+	}
+	return parseFFProbe(out)
+}
+
+func probeImage(path string) (*MediaInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cfg, format, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, err
+	}
+	return &MediaInfo{Container: format, Width: cfg.Width, Height: cfg.Height}, nil
+}
+
+// ffprobeStream là một phần output `ffprobe -show_streams -print_format json`
+type ffprobeStream struct {
+	CodecType    string `json:"codec_type"`
+	CodecName    string `json:"codec_name"`
+	Profile      string `json:"profile"`
+	Level        int    `json:"level"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	AvgFrameRate string `json:"avg_frame_rate"`
+	RFrameRate   string `json:"r_frame_rate"`
+	BitRate      string `json:"bit_rate"`
+	Channels     int    `json:"channels"`
+	Tags         struct {
+		Rotate string `json:"rotate"`
+	} `json:"tags"`
+	SideDataList []struct {
+		SideDataType string  `json:"side_data_type"`
+		Rotation     float64 `json:"rotation"`
+	} `json:"side_data_list"`
+}
+
+type ffprobeOutput struct {
+	Streams []ffprobeStream `json:"streams"`
+	Format  struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+func probeStreams(ctx context.Context, path string) ([]ffprobeStream, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe", "-v", "error", "-print_format", "json", "-show_streams", path)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe error (%s): %v", path, err)
+	}
+	var res ffprobeOutput
+	if err := json.Unmarshal(out, &res); err != nil {
+		return nil, fmt.Errorf("parse ffprobe output: %w", err)
+	}
+	return res.Streams, nil
+}
+
+// parseFFProbe chuyển output `ffprobe -show_format -show_streams` sang MediaInfo
+func parseFFProbe(data []byte) (*MediaInfo, error) {
+	var res ffprobeOutput
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("parse ffprobe output: %w", err)
+	}
+	info := &MediaInfo{
+		Container: strings.Split(res.Format.FormatName, ",")[0],
+	}
+	info.Duration, _ = strconv.ParseFloat(res.Format.Duration, 64)
+	info.Bitrate, _ = strconv.ParseInt(res.Format.BitRate, 10, 64)
+	for _, s := range res.Streams {
+		switch {
+		case s.CodecType == "video" && info.VideoCodec == "":
+			info.VideoCodec = s.CodecName
+			info.Width, info.Height = s.Width, s.Height
+			info.FrameRate = parseRational(s.AvgFrameRate)
+			if info.FrameRate == 0 {
+				info.FrameRate = parseRational(s.RFrameRate)
+			}
+			info.Rotation = streamRotation(s)
+			if info.Rotation == 90 || info.Rotation == 270 {
+				info.Width, info.Height = info.Height, info.Width
+			}
+		case s.CodecType == "audio" && info.AudioCodec == "":
+			info.AudioCodec = s.CodecName
+			info.AudioChannels = s.Channels
+		}
+	}
+	if info.VideoCodec == "" && info.AudioCodec == "" {
+		return nil, fmt.Errorf("no audio or video stream found")
+	}
+	return info, nil
+}
+
+// streamRotation đọc rotation từ tag "rotate" (ffmpeg cũ) hoặc display matrix,
+// chuẩn hóa về 0/90/180/270 theo chiều kim đồng hồ
+func streamRotation(s ffprobeStream) int {
+	deg := 0.0
+	if s.Tags.Rotate != "" {
+		deg, _ = strconv.ParseFloat(s.Tags.Rotate, 64)
+	} else {
+		for _, sd := range s.SideDataList {
+			if sd.SideDataType == "Display Matrix" {
+				// display matrix lưu góc ngược chiều kim đồng hồ
+				deg = -sd.Rotation
+				break
+			}
+		}
+	}
+	r := int(math.Round(deg/90)) * 90 % 360
+	if r < 0 {
+		r += 360
+	}
+	return r
+}
+
+func parseRational(s string) float64 {
+	num, den, ok := strings.Cut(s, "/")
+	if !ok {
+		v, _ := strconv.ParseFloat(s, 64)
+		return v
+	}
+	n, err1 := strconv.ParseFloat(num, 64)
+	d, err2 := strconv.ParseFloat(den, 64)
+	if err1 != nil || err2 != nil || d == 0 {
+		return 0
+	}
+	return math.Round(n/d*1000) / 1000
+}
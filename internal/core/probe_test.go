@@ -0,0 +1,51 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseFFProbe tests metadata extraction from ffprobe JSON of a rotated phone video
+func TestParseFFProbe(t *testing.T) {
+	data := []byte(`{
+		"streams": [
+			{"codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080,
+			 "avg_frame_rate": "30000/1001", "r_frame_rate": "30/1",
+			 "side_data_list": [{"side_data_type": "Display Matrix", "rotation": -90}]},
+			{"codec_type": "audio", "codec_name": "aac", "channels": 2}
+		],
+		"format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2", "duration": "12.345000", "bit_rate": "8000000"}
+	}`)
+
+	info, err := parseFFProbe(data)
+	require.NoError(t, err)
+	assert.Equal(t, &MediaInfo{
+		Container:     "mov",
+		Duration:      12.345,
+		Width:         1080,
+		Height:        1920,
+		FrameRate:     29.97,
+		VideoCodec:    "h264",
+		AudioCodec:    "aac",
+		Bitrate:       8000000,
+		AudioChannels: 2,
+		Rotation:      90,
+	}, info)
+
+	_, err = parseFFProbe([]byte(`{"streams": [], "format": {}}`))
+	assert.Error(t, err)
+}
+
+// TestStreamRotation tests normalization of rotate tags and display matrices
+func TestStreamRotation(t *testing.T) {
+	s := ffprobeStream{}
+	s.Tags.Rotate = "-90"
+	assert.Equal(t, 270, streamRotation(s))
+
+	s.Tags.Rotate = "180"
+	assert.Equal(t, 180, streamRotation(s))
+
+	assert.Equal(t, 0, streamRotation(ffprobeStream{}))
+}
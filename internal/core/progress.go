@@ -0,0 +1,67 @@
+package core
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TranscodeProgress là tiến độ transcode, Percent tính trên toàn bộ ladder
+type TranscodeProgress struct {
+	Rendition string  // rendition đang encode, rỗng khi single-pass encode mọi rendition cùng lúc
+	Percent   float64 // 0-100
+}
+
+// parseProgress đọc output `ffmpeg -progress pipe:1` (các dòng key=value, mỗi block kết thúc bằng progress=...)
+// và gọi onProgress với phần trăm out_time so với duration của nguồn
+func parseProgress(r io.Reader, duration float64, onProgress func(float64)) {
+	scanner := bufio.NewScanner(r)
+	var outTimeUs int64
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "out_time_us", "out_time_ms": // out_time_ms thực chất cũng là micro giây
+			if v, err := strconv.ParseInt(value, 10, 64); err == nil && v > 0 {
+				outTimeUs = v
+			}
+		case "progress":
+			if onProgress == nil {
+				continue
+			}
+			if value == "end" {
+				onProgress(100)
+				continue
+			}
+			if duration <= 0 {
+				continue
+			}
+			pct := float64(outTimeUs) / 1e6 / duration * 100
+			if pct > 100 {
+				pct = 100
+			}
+			onProgress(pct)
+		}
+	}
+}
+
+// tailBuffer giữ lại tối đa limit byte cuối cùng được ghi vào, dùng cho stderr của ffmpeg
+type tailBuffer struct {
+	limit int
+	buf   []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - t.limit; over > 0 {
+		t.buf = t.buf[over:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	return string(t.buf)
+}
@@ -3,61 +3,220 @@ package core
 import (
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// MasterPlaylistName là tên file master playlist trong thư mục output HLS
+const MasterPlaylistName = "master.m3u8"
+
+// hlsSegmentSeconds là độ dài segment, keyframe được ép đúng biên này ở mọi rendition
+const hlsSegmentSeconds = 4
+
+// TranscodeMode chọn cách chạy ffmpeg cho quality ladder
+type TranscodeMode string
+
+const (
+	// TranscodeSinglePass decode một lần, split filter ra mọi rendition trong cùng process
+	TranscodeSinglePass TranscodeMode = "single_pass"
+	// TranscodePerRendition chạy một process ffmpeg cho từng rendition (fallback)
+	TranscodePerRendition TranscodeMode = "per_rendition"
 )
 
 // VideoProcessor định nghĩa interface xử lý video
 // Triển khai bằng ffmpeg qua shell
 
 type VideoProcessor interface {
-	TranscodeToHLS(ctx context.Context, inputPath, outputDir string, qualities []string) error
+	// TranscodeToHLS transcode video ra outputDir, sinh master playlist và trả về các rendition đã tạo
+	TranscodeToHLS(ctx context.Context, inputPath, outputDir string, opts TranscodeOptions) ([]Rendition, error)
+	// GeneratePreviews sinh poster, sprite sheet và WebVTT thumbnail track vào outputDir
+	GeneratePreviews(ctx context.Context, inputPath, outputDir string, opts PreviewOptions) (*Previews, error)
+}
+
+// TranscodeOptions tùy chọn cho một lần transcode
+type TranscodeOptions struct {
+	Qualities []string                // tên rung cần tạo, rỗng = toàn bộ ladder
+	Source    *MediaInfo              // metadata đã probe của nguồn, nil = tự probe
+	Progress  func(TranscodeProgress) // nhận tiến độ parse từ `ffmpeg -progress`, có thể nil
 }
 
 // FFMPEGVideoProcessor là implement VideoProcessor dùng ffmpeg
 
-type FFMPEGVideoProcessor struct{}
+type FFMPEGVideoProcessor struct {
+	Ladder Ladder
+	Mode   TranscodeMode
+	Prober Prober
+}
+
+// Validate kiểm tra mode có được hỗ trợ không
+func (m TranscodeMode) Validate() error {
+	switch m {
+	case TranscodeSinglePass, TranscodePerRendition:
+		return nil
+	}
+	return fmt.Errorf("unknown transcode mode %q", m)
+}
 
-func NewFFMPEGVideoProcessor() *FFMPEGVideoProcessor {
-	return &FFMPEGVideoProcessor{}
+func NewFFMPEGVideoProcessor(ladder Ladder, mode TranscodeMode) *FFMPEGVideoProcessor {
+	if mode == "" {
+		mode = TranscodeSinglePass
+	}
+	return &FFMPEGVideoProcessor{Ladder: ladder, Mode: mode, Prober: NewFFProbeProber()}
 }
 
 // TranscodeToHLS chuyển video sang HLS với nhiều chất lượng
-func (p *FFMPEGVideoProcessor) TranscodeToHLS(ctx context.Context, inputPath, outputDir string, qualities []string) error {
-	// qualities ví dụ: ["360p", "480p", "720p"]
-	// mapping chất lượng sang thông số ffmpeg
-	qualityMap := map[string]string{
-		"360p":  "-vf scale=-2:360 -b:v 800k",
-		"480p":  "-vf scale=-2:480 -b:v 1400k",
-		"720p":  "-vf scale=-2:720 -b:v 2800k",
-		"1080p": "-vf scale=-2:1080 -b:v 5000k",
-	}
-	for _, q := range qualities {
-		ffmpegArgs := []string{"-i", inputPath}
-		if opt, ok := qualityMap[q]; ok {
-			ffmpegArgs = append(ffmpegArgs, splitArgs(opt)...) // scale, bitrate
+func (p *FFMPEGVideoProcessor) TranscodeToHLS(ctx context.Context, inputPath, outputDir string, opts TranscodeOptions) ([]Rendition, error) {
+	ladder, err := p.Ladder.Select(opts.Qualities)
+	if err != nil {
+		return nil, err
+	}
+	src := opts.Source
+	if src == nil {
+		if src, err = p.Prober.Probe(ctx, inputPath); err != nil {
+			return nil, err
 		}
-		ffmpegArgs = append(ffmpegArgs,
-			"-c:a", "aac", "-ar", "48000", "-c:v", "h264", "-profile:v", "main", "-crf", "20", "-sc_threshold", "0",
-			"-g", "48", "-keyint_min", "48", "-hls_time", "4", "-hls_playlist_type", "vod",
+	}
+	if src.VideoCodec == "" {
+		return nil, fmt.Errorf("no video stream in %s", inputPath)
+	}
+	hasAudio := src.AudioCodec != ""
+	ladder = ladder.ForSource(src.Width, src.Height)
+
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create output dir: %w", err)
+	}
+	switch p.Mode {
+	case TranscodeSinglePass:
+		err = p.transcodeSinglePass(ctx, inputPath, outputDir, ladder, hasAudio, src.Duration, opts.Progress)
+	case TranscodePerRendition:
+		err = p.transcodePerRendition(ctx, inputPath, outputDir, ladder, hasAudio, src.Duration, opts.Progress)
+	default:
+		err = p.Mode.Validate()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	renditions := make([]Rendition, 0, len(ladder))
+	for _, q := range ladder {
+		r, err := describeRendition(ctx, outputDir, q.Name, q.Name+".m3u8")
+		if err != nil {
+			return nil, err
+		}
+		renditions = append(renditions, r)
+	}
+	// master luôn ghi từ rendition đã đo để BANDWIDTH/CODECS/RESOLUTION không phụ thuộc chế độ transcode
+	if err := WriteMasterPlaylist(filepath.Join(outputDir, MasterPlaylistName), renditions); err != nil {
+		return nil, err
+	}
+	return renditions, nil
+}
+
+// transcodeSinglePass decode nguồn một lần, dùng split + var_stream_map để ghi mọi rendition
+// trong cùng một process ffmpeg
+func (p *FFMPEGVideoProcessor) transcodeSinglePass(ctx context.Context, inputPath, outputDir string, ladder Ladder, hasAudio bool, duration float64, progress func(TranscodeProgress)) error {
+	var onProgress func(float64)
+	if progress != nil {
+		onProgress = func(pct float64) { progress(TranscodeProgress{Percent: pct}) }
+	}
+	return runFFmpeg(ctx, singlePassArgs(inputPath, outputDir, ladder, hasAudio), duration, onProgress)
+}
+
+func singlePassArgs(inputPath, outputDir string, ladder Ladder, hasAudio bool) []string {
+	var filter strings.Builder
+	fmt.Fprintf(&filter, "[0:v]split=%d", len(ladder))
+	for i := range ladder {
+		fmt.Fprintf(&filter, "[v%d]", i)
+	}
+	for i, q := range ladder {
+		fmt.Fprintf(&filter, ";[v%d]%s[v%dout]", i, q.scaleFilter(), i)
+	}
+
+	args := []string{"-i", inputPath, "-filter_complex", filter.String()}
+	streamMap := make([]string, 0, len(ladder))
+	for i, q := range ladder {
+		args = append(args, "-map", fmt.Sprintf("[v%dout]", i))
+		if hasAudio {
+			args = append(args, "-map", "0:a:0")
+		}
+		args = append(args, q.encoderArgs(i, hasAudio)...)
+		if hasAudio {
+			streamMap = append(streamMap, fmt.Sprintf("v:%d,a:%d,name:%s", i, i, q.Name))
+		} else {
+			streamMap = append(streamMap, fmt.Sprintf("v:%d,name:%s", i, q.Name))
+		}
+	}
+	args = append(args, keyframeArgs()...)
+	args = append(args,
+		"-hls_time", fmt.Sprint(hlsSegmentSeconds), "-hls_playlist_type", "vod",
+		"-hls_flags", "independent_segments",
+		"-hls_segment_filename", filepath.Join(outputDir, "%v_%03d.ts"),
+		"-var_stream_map", strings.Join(streamMap, " "),
+		"-f", "hls",
+		filepath.Join(outputDir, "%v.m3u8"),
+	)
+	return args
+}
+
+// transcodePerRendition chạy một process ffmpeg cho từng rendition
+func (p *FFMPEGVideoProcessor) transcodePerRendition(ctx context.Context, inputPath, outputDir string, ladder Ladder, hasAudio bool, duration float64, progress func(TranscodeProgress)) error {
+	for i, q := range ladder {
+		args := []string{"-i", inputPath, "-vf", q.scaleFilter()}
+		args = append(args, q.encoderArgs(-1, hasAudio)...)
+		args = append(args, keyframeArgs()...)
+		args = append(args,
+			"-hls_time", fmt.Sprint(hlsSegmentSeconds), "-hls_playlist_type", "vod",
+			"-hls_flags", "independent_segments",
+			"-hls_segment_filename", filepath.Join(outputDir, q.Name+"_%03d.ts"),
 			"-f", "hls",
-			fmt.Sprintf("%s/%s.m3u8", outputDir, q),
+			filepath.Join(outputDir, q.Name+".m3u8"),
 		)
-		cmd := exec.CommandContext(ctx, "ffmpeg", ffmpegArgs...)
-		if out, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("ffmpeg error (%s): %v", out, err)
+		var onProgress func(float64)
+		if progress != nil {
+			done, name := float64(i), q.Name
+			onProgress = func(pct float64) {
+				progress(TranscodeProgress{Rendition: name, Percent: (done*100 + pct) / float64(len(ladder))})
+			}
+		}
+		if err := runFFmpeg(ctx, args, duration, onProgress); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-// splitArgs tách chuỗi thành slice cho exec.Command
-func splitArgs(s string) []string {
-	var out []string
-	for _, v := range []rune(s) {
-		if v == ' ' {
-			continue
+// keyframeArgs ép keyframe đúng biên segment và tắt scene-cut keyframe,
+// để mọi rendition có GOP đóng trùng nhau và player chuyển bitrate không bị giật
+func keyframeArgs() []string {
+	return []string{
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", hlsSegmentSeconds),
+		"-sc_threshold", "0",
+		"-flags", "+cgop",
+	}
+}
+
+// runFFmpeg chạy ffmpeg với -progress pipe:1 và báo phần trăm qua onProgress.
+// Khi ctx bị hủy, process bị kill và trả về cause của ctx.
+func runFFmpeg(ctx context.Context, args []string, duration float64, onProgress func(float64)) error {
+	full := append([]string{"-y", "-hide_banner", "-nostats", "-progress", "pipe:1"}, args...)
+	cmd := exec.CommandContext(ctx, "ffmpeg", full...)
+	stderr := &tailBuffer{limit: 8 << 10}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start ffmpeg: %w", err)
+	}
+	parseProgress(stdout, duration, onProgress)
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
 		}
-		out = append(out, string(v))
+		return fmt.Errorf("ffmpeg error (%s): %v", stderr, err)
 	}
-	return []string{}
+	return nil
 }
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSinglePassArgs tests the filter graph and stream map of the single-pass command
+func TestSinglePassArgs(t *testing.T) {
+	ladder := DefaultLadder().ForSource(1280, 720)
+	args := singlePassArgs("in.mp4", "/out", ladder, true)
+	joined := strings.Join(args, " ")
+
+	assert.Equal(t, "[0:v]split=3[v0][v1][v2];"+
+		"[v0]scale=w=640:h=360:force_original_aspect_ratio=decrease:force_divisible_by=2[v0out];"+
+		"[v1]scale=w=854:h=480:force_original_aspect_ratio=decrease:force_divisible_by=2[v1out];"+
+		"[v2]scale=w=1280:h=720:force_original_aspect_ratio=decrease:force_divisible_by=2[v2out]",
+		argValue(args, "-filter_complex"))
+	assert.Equal(t, "v:0,a:0,name:360p v:1,a:1,name:480p v:2,a:2,name:720p", argValue(args, "-var_stream_map"))
+	assert.Equal(t, "expr:gte(t,n_forced*4)", argValue(args, "-force_key_frames"))
+	assert.Equal(t, "1400k", argValue(args, "-b:v:1"))
+	assert.Equal(t, "128k", argValue(args, "-b:a:2"))
+	assert.NotContains(t, args, "-master_pl_name", "master is written by WriteMasterPlaylist")
+	assert.True(t, strings.HasSuffix(joined, "/out/%v.m3u8"))
+
+	noAudio := singlePassArgs("in.mp4", "/out", ladder, false)
+	assert.Equal(t, "v:0,name:360p v:1,name:480p v:2,name:720p", argValue(noAudio, "-var_stream_map"))
+	assert.NotContains(t, noAudio, "0:a:0")
+}
+
+func argValue(args []string, flag string) string {
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == flag {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// TestParseProgress tests percentage computation from ffmpeg -progress output
+func TestParseProgress(t *testing.T) {
+	out := strings.Join([]string{
+		"frame=10",
+		"out_time_us=2500000",
+		"progress=continue",
+		"out_time_us=N/A",
+		"progress=continue",
+		"out_time_us=7500000",
+		"progress=continue",
+		"progress=end",
+	}, "\n")
+	var got []float64
+	parseProgress(strings.NewReader(out), 10, func(p float64) { got = append(got, p) })
+	assert.Equal(t, []float64{25, 25, 75, 100}, got)
+}
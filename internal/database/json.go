@@ -0,0 +1,42 @@
+package database
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// JSONList lưu một slice dưới dạng cột jsonb
+type JSONList[T any] []T
+
+func (l JSONList[T]) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]T(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (l *JSONList[T]) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("scan JSONList: unsupported type %T", src)
+	}
+	return json.Unmarshal(data, (*[]T)(l))
+}
+
+// GormDataType khai báo kiểu cột cho AutoMigrate
+func (JSONList[T]) GormDataType() string {
+	return "jsonb"
+}
@@ -0,0 +1,25 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestJSONList tests the jsonb round trip of JSONList
+func TestJSONList(t *testing.T) {
+	in := JSONList[Rendition]{{Name: "720p", Playlist: "720p.m3u8", Width: 1280, Height: 720, Bandwidth: 3000000}}
+	v, err := in.Value()
+	require.NoError(t, err)
+
+	var out JSONList[Rendition]
+	require.NoError(t, out.Scan([]byte(v.(string))))
+	assert.Equal(t, in, out)
+
+	v, err = JSONList[Rendition](nil).Value()
+	require.NoError(t, err)
+	assert.Equal(t, "[]", v)
+	require.NoError(t, out.Scan(nil))
+	assert.Nil(t, out)
+}
@@ -0,0 +1,298 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"photo-go/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey là khóa pg_advisory_lock giữ trong lúc migrate, để nhiều replica khởi động cùng lúc
+// không chạy cùng một migration hai lần
+const migrationLockKey int64 = 0x70686f746f676f // "photogo"
+
+// ErrNoDownMigration trả về khi rollback migration không có file .down.sql
+var ErrNoDownMigration = errors.New("migration has no down script")
+
+// migrationName là tên file migration: <version>_<name>.up.sql hoặc .down.sql
+var migrationName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration là một bước thay đổi schema, Down rỗng thì không rollback được
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 hex của Up, phát hiện file đã áp bị sửa
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// SchemaMigration là một migration đã áp, lưu trong bảng schema_migrations
+type SchemaMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	Checksum  string
+	AppliedAt int64
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+const createSchemaMigrations = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    bigint PRIMARY KEY,
+    name       text NOT NULL,
+    checksum   text NOT NULL,
+    applied_at bigint NOT NULL
+)`
+
+// MigrationStatus là một migration cùng thời điểm đã áp, AppliedAt = 0 khi chưa áp
+type MigrationStatus struct {
+	Migration
+	AppliedAt int64
+	Modified  bool // file đã bị sửa sau khi áp
+}
+
+// Migrator áp các migration SQL theo thứ tự version, mỗi migration trong một transaction
+type Migrator struct {
+	DB         *gorm.DB
+	Migrations []Migration
+}
+
+// NewMigrator tạo Migrator với các migration nhúng trong binary (migrations/*.sql)
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := LoadMigrations(sub)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// LoadMigrations đọc các file <version>_<name>.up.sql/.down.sql ở gốc fsys, sắp theo version.
+// Mỗi version phải có file up và chỉ một tên.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".sql" {
+			continue
+		}
+		match := migrationName.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q, want <version>_<name>.up.sql or .down.sql", e.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", e.Name())
+		}
+		data, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d used by both %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %s has no up script", m)
+		}
+		sum := sha256.Sum256([]byte(m.Up))
+		m.Checksum = hex.EncodeToString(sum[:])
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// Up áp mọi migration chưa áp theo thứ tự version, trả về số migration đã áp
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		done, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.Migrations {
+			if prev, ok := done[mig.Version]; ok {
+				if prev.Checksum != mig.Checksum {
+					logger.Warn("Migration %s was modified after it was applied", mig)
+				}
+				continue
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(mig.Up).Error; err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{Version: mig.Version, Name: mig.Name, Checksum: mig.Checksum, AppliedAt: time.Now().Unix()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("apply migration %s: %w", mig, err)
+			}
+			logger.Info("Migration applied: %s", mig)
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rollback steps migration đã áp gần nhất (theo version), trả về số migration đã rollback
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	known := make(map[int64]Migration, len(m.Migrations))
+	for _, mig := range m.Migrations {
+		known[mig.Version] = mig
+	}
+	reverted := 0
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		var rows []SchemaMigration
+		if err := conn.Order("version DESC").Limit(steps).Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			mig, ok := known[row.Version]
+			if !ok {
+				return fmt.Errorf("rollback migration %04d_%s: not found in this binary", row.Version, row.Name)
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("rollback migration %s: %w", mig, ErrNoDownMigration)
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(mig.Down).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{}, row.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rollback migration %s: %w", mig, err)
+			}
+			logger.Info("Migration rolled back: %s", mig)
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status trả về mọi migration đã biết kèm trạng thái áp. Chỉ đọc nên không giữ migration lock
+// (không chờ một `migrate up` đang chạy) và không tạo bảng: chưa có schema_migrations = chưa áp migration nào.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	db := m.DB.WithContext(ctx)
+	var exists bool
+	if err := db.Raw("SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists).Error; err != nil {
+		return nil, err
+	}
+	done := map[int64]SchemaMigration{}
+	if exists {
+		var err error
+		if done, err = appliedMigrations(db); err != nil {
+			return nil, err
+		}
+	}
+	return migrationStatus(m.Migrations, done), nil
+}
+
+// Pending trả về số migration chưa áp
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, s := range status {
+		if s.AppliedAt == 0 {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// withLock chạy fn trên một connection giữ advisory lock, advisory lock gắn với session
+// nên lock, các migration và unlock phải cùng một connection
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return m.DB.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockKey).Error; err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		defer func() {
+			// unlock cả khi ctx đã bị hủy, nếu không connection trả về pool vẫn giữ lock
+			if err := conn.WithContext(context.WithoutCancel(ctx)).Exec("SELECT pg_advisory_unlock(?)", migrationLockKey).Error; err != nil {
+				logger.Error(err, "Release migration lock failed")
+			}
+		}()
+		if err := conn.Exec(createSchemaMigrations).Error; err != nil {
+			return fmt.Errorf("create schema_migrations: %w", err)
+		}
+		return fn(conn)
+	})
+}
+
+func appliedMigrations(conn *gorm.DB) (map[int64]SchemaMigration, error) {
+	var rows []SchemaMigration
+	if err := conn.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	done := make(map[int64]SchemaMigration, len(rows))
+	for _, r := range rows {
+		done[r.Version] = r
+	}
+	return done, nil
+}
+
+// migrationStatus ghép migration đã biết với bản ghi đã áp. Bản ghi không có file tương ứng
+// (do binary cũ hơn DB) vẫn được liệt kê để thấy schema đang đi trước code.
+func migrationStatus(migrations []Migration, done map[int64]SchemaMigration) []MigrationStatus {
+	out := make([]MigrationStatus, 0, len(migrations))
+	known := make(map[int64]bool, len(migrations))
+	for _, mig := range migrations {
+		known[mig.Version] = true
+		s := MigrationStatus{Migration: mig}
+		if row, ok := done[mig.Version]; ok {
+			s.AppliedAt = row.AppliedAt
+			s.Modified = row.Checksum != mig.Checksum
+		}
+		out = append(out, s)
+	}
+	for _, row := range done {
+		if !known[row.Version] {
+			out = append(out, MigrationStatus{Migration: Migration{Version: row.Version, Name: row.Name, Checksum: row.Checksum}, AppliedAt: row.AppliedAt})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out
+}
@@ -0,0 +1,300 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// TestLoadMigrations tests ordering, up/down pairing and invalid migration sets
+func TestLoadMigrations(t *testing.T) {
+	ms, err := LoadMigrations(fstest.MapFS{
+		"0010_add_index.up.sql":     {Data: []byte("CREATE INDEX a ON b (c);")},
+		"0002_init.up.sql":          {Data: []byte("CREATE TABLE b (c int);")},
+		"0002_init.down.sql":        {Data: []byte("DROP TABLE b;")},
+		"README.md":                 {Data: []byte("ignored")},
+		"0010_add_index.down.sql":   {Data: []byte("DROP INDEX a;")},
+		"0011_backfill_only.up.sql": {Data: []byte("UPDATE b SET c = 0;")},
+	})
+	require.NoError(t, err)
+	require.Len(t, ms, 3)
+	assert.Equal(t, "0002_init", ms[0].String())
+	assert.Equal(t, "DROP TABLE b;", ms[0].Down)
+	assert.Equal(t, int64(10), ms[1].Version)
+	assert.Len(t, ms[1].Checksum, 64)
+	assert.Empty(t, ms[2].Down)
+
+	_, err = LoadMigrations(fstest.MapFS{"0001_init.down.sql": {Data: []byte("DROP TABLE b;")}})
+	assert.ErrorContains(t, err, "no up script")
+	_, err = LoadMigrations(fstest.MapFS{
+		"0001_init.up.sql":  {Data: []byte("SELECT 1;")},
+		"0001_other.up.sql": {Data: []byte("SELECT 2;")},
+	})
+	assert.ErrorContains(t, err, "used by both")
+	_, err = LoadMigrations(fstest.MapFS{"init.sql": {Data: []byte("SELECT 1;")}})
+	assert.ErrorContains(t, err, "invalid migration file name")
+}
+
+// TestMigrationStatus tests pending, applied, modified and unknown applied migrations
+func TestMigrationStatus(t *testing.T) {
+	ms := []Migration{{Version: 1, Name: "init", Checksum: "a"}, {Version: 2, Name: "trash", Checksum: "b"}}
+	status := migrationStatus(ms, map[int64]SchemaMigration{
+		1: {Version: 1, Name: "init", Checksum: "old", AppliedAt: 100},
+		3: {Version: 3, Name: "future", Checksum: "c", AppliedAt: 200},
+	})
+	require.Len(t, status, 3)
+	assert.Equal(t, int64(100), status[0].AppliedAt)
+	assert.True(t, status[0].Modified)
+	assert.Zero(t, status[1].AppliedAt)
+	assert.Equal(t, "0003_future", status[2].String())
+}
+
+// TestEmbeddedMigrations tests that the embedded migrations load and can be rolled back
+func TestEmbeddedMigrations(t *testing.T) {
+	m, err := NewMigrator(nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, m.Migrations)
+	for i, mig := range m.Migrations {
+		assert.Equal(t, int64(i+1), mig.Version, "versions are consecutive")
+		assert.NotEmpty(t, mig.Down, "%s has a down script", mig)
+	}
+}
+
+// TestMigrationsOverExistingSchema tests that the embedded migrations bring an empty DB, a baseline DB
+// and a DB created by AutoMigrate to exactly the schema of the models, and that down reverts them
+func TestMigrationsOverExistingSchema(t *testing.T) {
+	m, err := NewMigrator(nil)
+	require.NoError(t, err)
+	want := modelSchema(t)
+
+	baseline := newSQLSchema()
+	baseline.tables["media"] = map[string]bool{"id": true, "type": true, "path": true, "created_at": true, "updated_at": true}
+	// AutoMigrate của phiên bản trước thùng rác: có mọi bảng nhưng media chưa có cột trash
+	autoMigrated := modelSchema(t)
+	delete(autoMigrated.tables["media"], "deleted_at")
+	delete(autoMigrated.tables["media"], "purge_queued_at")
+	delete(autoMigrated.indexes, "idx_media_deleted_at")
+
+	for name, db := range map[string]*sqlSchema{"empty": newSQLSchema(), "baseline": baseline, "auto_migrated": autoMigrated} {
+		t.Run(name, func(t *testing.T) {
+			for _, mig := range m.Migrations {
+				require.NoError(t, db.apply(mig.Up), "up %s", mig)
+			}
+			assert.Equal(t, want.tables, db.tables)
+			assert.Equal(t, want.indexes, db.indexes)
+			if name != "empty" {
+				return
+			}
+			for i := len(m.Migrations) - 1; i >= 0; i-- {
+				require.NoError(t, db.apply(m.Migrations[i].Down), "down %s", m.Migrations[i])
+			}
+			assert.Empty(t, db.tables)
+			assert.Empty(t, db.indexes)
+		})
+	}
+}
+
+// sqlSchema là mô hình tối giản của schema Postgres (bảng, cột, index) để kiểm migration không cần DB.
+// Chỉ hiểu các câu lệnh migration đang dùng, câu lệnh lạ là lỗi để test phải được mở rộng cùng migration.
+type sqlSchema struct {
+	tables  map[string]map[string]bool
+	indexes map[string]string // tên index -> bảng
+}
+
+var (
+	createTableStmt = regexp.MustCompile(`(?is)^CREATE TABLE IF NOT EXISTS (\w+) \((.*)\)$`)
+	alterTableStmt  = regexp.MustCompile(`(?is)^ALTER TABLE (\w+)\s+(.*)$`)
+	createIndexStmt = regexp.MustCompile(`(?is)^CREATE INDEX IF NOT EXISTS (\w+) ON (\w+) \(([^)]*)\)$`)
+	updateStmt      = regexp.MustCompile(`(?is)^UPDATE (\w+) SET (\w+) = .* WHERE (\w+) IS NULL$`)
+	dropTableStmt   = regexp.MustCompile(`(?is)^DROP TABLE IF EXISTS (\w+)$`)
+	dropIndexStmt   = regexp.MustCompile(`(?is)^DROP INDEX IF EXISTS (\w+)$`)
+	addColumn       = regexp.MustCompile(`(?is)^ADD COLUMN IF NOT EXISTS (\w+) \w+`)
+	alterColumn     = regexp.MustCompile(`(?is)^ALTER COLUMN (\w+) `)
+	dropColumn      = regexp.MustCompile(`(?is)^DROP COLUMN IF EXISTS (\w+)$`)
+)
+
+func newSQLSchema() *sqlSchema {
+	return &sqlSchema{tables: map[string]map[string]bool{}, indexes: map[string]string{}}
+}
+
+// modelSchema là schema AutoMigrate tạo từ các model
+func modelSchema(t *testing.T) *sqlSchema {
+	out := newSQLSchema()
+	for _, model := range []any{&Media{}, &MediaTag{}, &Job{}, &Upload{}, &PresignedUpload{}} {
+		s, err := schema.Parse(model, &sync.Map{}, schema.NamingStrategy{})
+		require.NoError(t, err)
+		cols := map[string]bool{}
+		for _, f := range s.Fields {
+			if f.DBName != "" {
+				cols[f.DBName] = true
+			}
+		}
+		out.tables[s.Table] = cols
+		for _, idx := range s.ParseIndexes() {
+			out.indexes[idx.Name] = s.Table
+		}
+	}
+	return out
+}
+
+func (s *sqlSchema) apply(script string) error {
+	var lines []string
+	for _, l := range strings.Split(script, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(l), "--") {
+			lines = append(lines, l)
+		}
+	}
+	for _, stmt := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if stmt = strings.TrimSpace(stmt); stmt == "" {
+			continue
+		}
+		if err := s.exec(stmt); err != nil {
+			return fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+	return nil
+}
+
+func (s *sqlSchema) exec(stmt string) error {
+	if m := createTableStmt.FindStringSubmatch(stmt); m != nil {
+		if s.tables[m[1]] != nil {
+			return nil
+		}
+		cols := map[string]bool{}
+		for _, def := range strings.Split(m[2], "\n") {
+			name, _, _ := strings.Cut(strings.TrimSpace(def), " ")
+			if name != "" && name != "PRIMARY" && name != "CONSTRAINT" {
+				cols[name] = true
+			}
+		}
+		s.tables[m[1]] = cols
+		return nil
+	}
+	if m := alterTableStmt.FindStringSubmatch(stmt); m != nil {
+		cols, err := s.table(m[1])
+		if err != nil {
+			return err
+		}
+		for _, clause := range strings.Split(m[2], ",") {
+			clause = strings.TrimSpace(clause)
+			if c := addColumn.FindStringSubmatch(clause); c != nil {
+				cols[c[1]] = true
+			} else if c := alterColumn.FindStringSubmatch(clause); c != nil {
+				if !cols[c[1]] {
+					return fmt.Errorf("column %s.%s does not exist", m[1], c[1])
+				}
+			} else if c := dropColumn.FindStringSubmatch(clause); c != nil {
+				delete(cols, c[1])
+			} else {
+				return fmt.Errorf("unsupported ALTER TABLE clause %q", clause)
+			}
+		}
+		return nil
+	}
+	if m := createIndexStmt.FindStringSubmatch(stmt); m != nil {
+		if err := s.requireColumns(m[2], strings.Split(m[3], ",")...); err != nil {
+			return err
+		}
+		if _, ok := s.indexes[m[1]]; !ok {
+			s.indexes[m[1]] = m[2]
+		}
+		return nil
+	}
+	if m := updateStmt.FindStringSubmatch(stmt); m != nil {
+		return s.requireColumns(m[1], m[2], m[3])
+	}
+	if m := dropTableStmt.FindStringSubmatch(stmt); m != nil {
+		// DROP TABLE xóa luôn index của bảng
+		for idx, table := range s.indexes {
+			if table == m[1] {
+				delete(s.indexes, idx)
+			}
+		}
+		delete(s.tables, m[1])
+		return nil
+	}
+	if m := dropIndexStmt.FindStringSubmatch(stmt); m != nil {
+		delete(s.indexes, m[1])
+		return nil
+	}
+	return fmt.Errorf("unsupported statement")
+}
+
+func (s *sqlSchema) table(name string) (map[string]bool, error) {
+	cols, ok := s.tables[name]
+	if !ok {
+		return nil, fmt.Errorf("table %s does not exist", name)
+	}
+	return cols, nil
+}
+
+func (s *sqlSchema) requireColumns(table string, cols ...string) error {
+	existing, err := s.table(table)
+	if err != nil {
+		return err
+	}
+	for _, c := range cols {
+		if c = strings.TrimSpace(c); !existing[c] {
+			return fmt.Errorf("column %s.%s does not exist", table, c)
+		}
+	}
+	return nil
+}
+
+// TestMigrateUpOverBaselinePostgres tests up and down against a real Postgres holding a baseline media table.
+// Chỉ chạy khi có TEST_DATABASE_DSN, dùng một schema tạm nên không đụng dữ liệu có sẵn.
+func TestMigrateUpOverBaselinePostgres(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	ctx := context.Background()
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	schemaName := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	require.NoError(t, admin.Exec("CREATE SCHEMA "+schemaName).Error)
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schemaName + " CASCADE") })
+
+	db, err := gorm.Open(postgres.Open(dsn+" search_path="+schemaName), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Exec(`CREATE TABLE media (id bigserial PRIMARY KEY, type text, path text, created_at bigint, updated_at bigint)`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO media (type, path, created_at, updated_at) VALUES ('video', 'hls/1/master.m3u8', 1, 1)`).Error)
+
+	m, err := NewMigrator(db)
+	require.NoError(t, err)
+	n, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, len(m.Migrations), n)
+	for _, model := range []any{&Media{}, &MediaTag{}, &Job{}, &Upload{}, &PresignedUpload{}} {
+		s, err := schema.Parse(model, &sync.Map{}, schema.NamingStrategy{})
+		require.NoError(t, err)
+		for _, f := range s.Fields {
+			if f.DBName != "" {
+				assert.True(t, db.Migrator().HasColumn(model, f.DBName), "%s.%s", s.Table, f.DBName)
+			}
+		}
+	}
+	var media Media
+	require.NoError(t, db.First(&media).Error)
+	assert.Zero(t, media.DeletedAt, "existing rows are backfilled")
+
+	n, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	n, err = m.Down(ctx, len(m.Migrations))
+	require.NoError(t, err)
+	assert.Equal(t, len(m.Migrations), n)
+	assert.False(t, db.Migrator().HasTable(&Media{}))
+}
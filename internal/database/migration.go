@@ -4,6 +4,8 @@ import (
 	"gorm.io/gorm"
 )
 
+// AutoMigrate tạo/thêm bảng và cột theo model, chỉ dùng cho dev (DB_MIGRATE=auto):
+// không xóa cột, không backfill và không rollback được. Môi trường thật dùng Migrator.
 func AutoMigrate(db *gorm.DB) error {
-	return db.AutoMigrate(&Media{})
+	return db.AutoMigrate(&Media{}, &MediaTag{}, &Job{}, &Upload{}, &PresignedUpload{})
 }
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS presigned_uploads;
+DROP TABLE IF EXISTS uploads;
+DROP TABLE IF EXISTS jobs;
+DROP TABLE IF EXISTS media_tags;
+DROP TABLE IF EXISTS media;
@@ -0,0 +1,102 @@
+-- Schema trước khi có migration versioned. DB có thể đã được AutoMigrate tạo ở bất kỳ phiên bản nào
+-- từ baseline (media chỉ có id, type, path, created_at, updated_at), nên bảng chỉ tạo với khóa chính
+-- còn mọi cột khác được thêm bằng ADD COLUMN IF NOT EXISTS trước khi tạo index.
+CREATE TABLE IF NOT EXISTS media (
+    id         bigserial PRIMARY KEY,
+    type       text,
+    path       text,
+    created_at bigint,
+    updated_at bigint
+);
+ALTER TABLE media
+    ADD COLUMN IF NOT EXISTS status text,
+    ADD COLUMN IF NOT EXISTS failure_reason text,
+    ADD COLUMN IF NOT EXISTS original_path text,
+    ADD COLUMN IF NOT EXISTS original_size bigint,
+    ADD COLUMN IF NOT EXISTS container text,
+    ADD COLUMN IF NOT EXISTS duration decimal,
+    ADD COLUMN IF NOT EXISTS width bigint,
+    ADD COLUMN IF NOT EXISTS height bigint,
+    ADD COLUMN IF NOT EXISTS frame_rate decimal,
+    ADD COLUMN IF NOT EXISTS video_codec text,
+    ADD COLUMN IF NOT EXISTS audio_codec text,
+    ADD COLUMN IF NOT EXISTS bitrate bigint,
+    ADD COLUMN IF NOT EXISTS audio_channels bigint,
+    ADD COLUMN IF NOT EXISTS rotation bigint,
+    ADD COLUMN IF NOT EXISTS camera_make text,
+    ADD COLUMN IF NOT EXISTS camera_model text,
+    ADD COLUMN IF NOT EXISTS lens_model text,
+    ADD COLUMN IF NOT EXISTS exposure_time text,
+    ADD COLUMN IF NOT EXISTS f_number decimal,
+    ADD COLUMN IF NOT EXISTS iso bigint,
+    ADD COLUMN IF NOT EXISTS focal_length decimal,
+    ADD COLUMN IF NOT EXISTS captured_at bigint,
+    ADD COLUMN IF NOT EXISTS orientation bigint,
+    ADD COLUMN IF NOT EXISTS latitude decimal,
+    ADD COLUMN IF NOT EXISTS longitude decimal,
+    ADD COLUMN IF NOT EXISTS altitude decimal,
+    ADD COLUMN IF NOT EXISTS blur_hash text,
+    ADD COLUMN IF NOT EXISTS colors jsonb,
+    ADD COLUMN IF NOT EXISTS renditions jsonb,
+    ADD COLUMN IF NOT EXISTS variants jsonb,
+    ADD COLUMN IF NOT EXISTS preview_track text;
+CREATE INDEX IF NOT EXISTS idx_media_status ON media (status);
+CREATE INDEX IF NOT EXISTS idx_media_created_at ON media (created_at);
+
+CREATE TABLE IF NOT EXISTS media_tags (
+    media_id bigint,
+    tag      text,
+    PRIMARY KEY (media_id, tag),
+    CONSTRAINT fk_media_tags FOREIGN KEY (media_id) REFERENCES media (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_media_tags_tag ON media_tags (tag);
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id bigserial PRIMARY KEY
+);
+ALTER TABLE jobs
+    ADD COLUMN IF NOT EXISTS type text,
+    ADD COLUMN IF NOT EXISTS media_id bigint,
+    ADD COLUMN IF NOT EXISTS state text,
+    ADD COLUMN IF NOT EXISTS attempts bigint,
+    ADD COLUMN IF NOT EXISTS max_attempts bigint,
+    ADD COLUMN IF NOT EXISTS run_at bigint,
+    ADD COLUMN IF NOT EXISTS locked_by text,
+    ADD COLUMN IF NOT EXISTS locked_until bigint,
+    ADD COLUMN IF NOT EXISTS last_error text,
+    ADD COLUMN IF NOT EXISTS stage text,
+    ADD COLUMN IF NOT EXISTS progress decimal,
+    ADD COLUMN IF NOT EXISTS cancel_requested boolean,
+    ADD COLUMN IF NOT EXISTS created_at bigint,
+    ADD COLUMN IF NOT EXISTS updated_at bigint;
+CREATE INDEX IF NOT EXISTS idx_jobs_claim ON jobs (state, type, run_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_media_id ON jobs (media_id);
+
+CREATE TABLE IF NOT EXISTS uploads (
+    id text PRIMARY KEY
+);
+ALTER TABLE uploads
+    ADD COLUMN IF NOT EXISTS length bigint,
+    ADD COLUMN IF NOT EXISTS upload_offset bigint,
+    ADD COLUMN IF NOT EXISTS metadata text,
+    ADD COLUMN IF NOT EXISTS media_id bigint,
+    ADD COLUMN IF NOT EXISTS expires_at bigint,
+    ADD COLUMN IF NOT EXISTS created_at bigint,
+    ADD COLUMN IF NOT EXISTS updated_at bigint;
+CREATE INDEX IF NOT EXISTS idx_uploads_expires_at ON uploads (expires_at);
+
+CREATE TABLE IF NOT EXISTS presigned_uploads (
+    id text PRIMARY KEY
+);
+ALTER TABLE presigned_uploads
+    ADD COLUMN IF NOT EXISTS object_name text,
+    ADD COLUMN IF NOT EXISTS multipart_id text,
+    ADD COLUMN IF NOT EXISTS filename text,
+    ADD COLUMN IF NOT EXISTS size bigint,
+    ADD COLUMN IF NOT EXISTS sha256 text,
+    ADD COLUMN IF NOT EXISTS tags jsonb,
+    ADD COLUMN IF NOT EXISTS media_id bigint,
+    ADD COLUMN IF NOT EXISTS expires_at bigint,
+    ADD COLUMN IF NOT EXISTS created_at bigint,
+    ADD COLUMN IF NOT EXISTS updated_at bigint;
+CREATE INDEX IF NOT EXISTS idx_presigned_uploads_expires_at ON presigned_uploads (expires_at);
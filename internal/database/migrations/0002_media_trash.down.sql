@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_media_deleted_at;
+ALTER TABLE media DROP COLUMN IF EXISTS deleted_at, DROP COLUMN IF EXISTS purge_queued_at;
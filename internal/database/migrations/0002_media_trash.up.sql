@@ -0,0 +1,11 @@
+-- Thùng rác của media. Cột có thể đã được AutoMigrate thêm (nullable, không default) nên backfill trước khi NOT NULL.
+ALTER TABLE media ADD COLUMN IF NOT EXISTS deleted_at bigint;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS purge_queued_at bigint;
+UPDATE media SET deleted_at = 0 WHERE deleted_at IS NULL;
+UPDATE media SET purge_queued_at = 0 WHERE purge_queued_at IS NULL;
+ALTER TABLE media
+    ALTER COLUMN deleted_at SET DEFAULT 0,
+    ALTER COLUMN deleted_at SET NOT NULL,
+    ALTER COLUMN purge_queued_at SET DEFAULT 0,
+    ALTER COLUMN purge_queued_at SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_media_deleted_at ON media (deleted_at);
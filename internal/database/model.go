@@ -1,10 +1,126 @@
 package database
 
 type Media struct {
-	ID        uint   `gorm:"primaryKey"`
-	Type      string // video, image
-	Path      string
+	ID            uint   `gorm:"primaryKey"`
+	Type          string // video, image
+	Path          string // object chính để phát (master playlist với video)
+	Status        string `gorm:"index"` // pending, processing, ready, failed
+	FailureReason string
+	OriginalPath  string // object file gốc trên storage
+	OriginalSize  int64
+
+	// Metadata đọc bằng core.Prober trước khi xử lý
+	Container     string
+	Duration      float64 // giây
+	Width         int     // kích thước hiển thị, đã áp Rotation
+	Height        int
+	FrameRate     float64
+	VideoCodec    string
+	AudioCodec    string
+	Bitrate       int64 // bit/s
+	AudioChannels int
+	Rotation      int
+
+	// EXIF của ảnh chụp, GPS nil khi không có
+	CameraMake   string
+	CameraModel  string
+	LensModel    string
+	ExposureTime string
+	FNumber      float64
+	ISO          int
+	FocalLength  float64
+	CapturedAt   int64 // unix, 0 = không rõ
+	Orientation  int   // EXIF 1-8, đã áp vào variant và Width/Height
+	Latitude     *float64
+	Longitude    *float64
+	Altitude     *float64
+
+	// Placeholder tính từ ảnh nhỏ nhất hoặc frame poster của video
+	BlurHash string
+	Colors   JSONList[string] // màu chủ đạo #rrggbb
+
+	Renditions JSONList[Rendition]    // các luồng HLS đã upload, rỗng khi chưa ready
+	Variants   JSONList[ImageVariant] // các kích thước ảnh đã sinh sẵn, poster với video
+	// WebVTT thumbnail track của video, tương đối với thư mục HLS
+	PreviewTrack string
+	Tags         []MediaTag `gorm:"foreignKey:MediaID;constraint:OnDelete:CASCADE"`
+
+	// Thùng rác: media bị xóa mềm được giữ TRASH_RETENTION giây rồi mới purge cả bản ghi lẫn object
+	DeletedAt     int64 `gorm:"index;not null;default:0"` // unix, 0 = chưa xóa
+	PurgeQueuedAt int64 `gorm:"not null;default:0"`       // unix lúc enqueue job purge, 0 = chưa; khác 0 thì không restore được nữa
+
+	CreatedAt int64 `gorm:"index"`
+	UpdatedAt int64
+}
+
+// MediaTag gắn tag cho media, dùng để lọc danh sách
+type MediaTag struct {
+	MediaID uint   `gorm:"primaryKey"`
+	Tag     string `gorm:"primaryKey;index"`
+}
+
+// Rendition là một luồng HLS của video, lưu trong cột jsonb của Media
+type Rendition struct {
+	Name      string `json:"name"`
+	Playlist  string `json:"playlist"` // tương đối với thư mục HLS của media
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Bandwidth int64  `json:"bandwidth"` // bit/s
+	Codecs    string `json:"codecs"`
+}
+
+// ImageVariant là một derivative của ảnh đã upload lên storage
+type ImageVariant struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"` // object trên storage
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Size   int64  `json:"size"`
+	Format string `json:"format"`
+}
+
+// Job là một bản ghi trong hàng đợi xử lý nền, được claim bằng SELECT ... FOR UPDATE SKIP LOCKED
+type Job struct {
+	ID              uint   `gorm:"primaryKey"`
+	Type            string `gorm:"index:idx_jobs_claim,priority:2"`
+	MediaID         uint   `gorm:"index"`
+	State           string `gorm:"index:idx_jobs_claim,priority:1"` // queued, running, succeeded, failed, dead, canceled
+	Attempts        int
+	MaxAttempts     int
+	RunAt           int64  `gorm:"index:idx_jobs_claim,priority:3"` // unix, chưa claim trước thời điểm này (backoff)
+	LockedBy        string // worker đang giữ job
+	LockedUntil     int64  // unix, hết hạn thì worker khác được claim lại (visibility timeout)
+	LastError       string
+	Stage           string  // probing, transcoding 720p, uploading, ...
+	Progress        float64 // 0-100
+	CancelRequested bool    // worker đang giữ job sẽ hủy handler ở lần heartbeat kế tiếp
+	CreatedAt       int64
+	UpdatedAt       int64
+}
+
+// Upload là trạng thái một upload tus, chunk nằm ở ChunkStore cho tới khi ghép xong
+type Upload struct {
+	ID        string `gorm:"primaryKey"` // hex ngẫu nhiên, xuất hiện trong URL
+	Length    int64  // Upload-Length
+	Offset    int64  `gorm:"column:upload_offset"` // số byte đã nhận liên tục từ đầu, OFFSET là từ khóa SQL
+	Metadata  string // Upload-Metadata nguyên gốc (filename, tags)
+	MediaID   uint   // media tạo ra khi upload hoàn tất, 0 = chưa
+	ExpiresAt int64  `gorm:"index"` // unix, upload dở bị dọn sau thời điểm này
 	CreatedAt int64
 	UpdatedAt int64
-	// TODO: Thêm các trường metadata (duration, quality, ...)
+}
+
+// PresignedUpload là upload client PUT thẳng lên MinIO bằng presigned URL, chờ gọi complete
+type PresignedUpload struct {
+	ID          string `gorm:"primaryKey"`
+	ObjectName  string // object tạm dưới incoming/ cho tới khi complete
+	MultipartID string // UploadId của multipart upload, rỗng = một PUT
+	Filename    string
+	Size        int64
+	SHA256      string // hex, rỗng = không kiểm
+	Tags        JSONList[string]
+	MediaID     uint  // media tạo ra khi complete, 0 = chưa
+	ExpiresAt   int64 `gorm:"index"`
+	CreatedAt   int64
+	UpdatedAt   int64
 }
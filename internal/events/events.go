@@ -0,0 +1,95 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// Type là loại event tiến độ xử lý media
+type Type string
+
+const (
+	TypeProgress Type = "progress" // đổi stage hoặc phần trăm
+	TypeReady    Type = "ready"    // xử lý xong, có URL stream
+	TypeFailed   Type = "failed"   // lỗi không retry nữa hoặc bị hủy
+)
+
+// Event là một cập nhật tiến độ của media, được đẩy tới client qua SSE
+type Event struct {
+	Type     Type    `json:"type"`
+	MediaID  uint    `json:"media_id"`
+	JobID    uint    `json:"job_id,omitempty"`
+	Stage    string  `json:"stage,omitempty"`
+	Progress float64 `json:"progress"`
+	URL      string  `json:"url,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// Terminal trả về true nếu sau event này media không còn thay đổi
+func (e Event) Terminal() bool {
+	return e.Type == TypeReady || e.Type == TypeFailed
+}
+
+// Broker là pub/sub event theo media.
+// MemoryBroker chỉ phát trong cùng process; khi worker chạy riêng cần implement khác (vd: Postgres LISTEN/NOTIFY).
+type Broker interface {
+	Publish(ctx context.Context, e Event) error
+	// Subscribe nhận event của mediaID cho tới khi ctx bị hủy, lúc đó channel bị đóng
+	Subscribe(ctx context.Context, mediaID uint) (<-chan Event, error)
+}
+
+// subscriberBuffer là số event tối đa giữ cho một subscriber chậm
+const subscriberBuffer = 16
+
+// MemoryBroker là Broker trong bộ nhớ
+type MemoryBroker struct {
+	mu   sync.Mutex
+	subs map[uint]map[chan Event]struct{}
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{subs: map[uint]map[chan Event]struct{}{}}
+}
+
+// Publish không bao giờ block: subscriber đầy buffer bị bỏ event cũ nhất để giữ event mới
+func (b *MemoryBroker) Publish(_ context.Context, e Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[e.MediaID] {
+		select {
+		case ch <- e:
+			continue
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context, mediaID uint) (<-chan Event, error) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	if b.subs[mediaID] == nil {
+		b.subs[mediaID] = map[chan Event]struct{}{}
+	}
+	b.subs[mediaID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs[mediaID], ch)
+		if len(b.subs[mediaID]) == 0 {
+			delete(b.subs, mediaID)
+		}
+		close(ch)
+	})
+	return ch, nil
+}
@@ -0,0 +1,49 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMemoryBroker tests delivery per media and channel close on unsubscribe
+func TestMemoryBroker(t *testing.T) {
+	b := NewMemoryBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := b.Subscribe(ctx, 1)
+	require.NoError(t, err)
+
+	require.NoError(t, b.Publish(context.Background(), Event{Type: TypeProgress, MediaID: 2}))
+	require.NoError(t, b.Publish(context.Background(), Event{Type: TypeReady, MediaID: 1, URL: "/x"}))
+	e := <-ch
+	assert.Equal(t, TypeReady, e.Type)
+	assert.True(t, e.Terminal())
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		_, ok := <-ch
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, b.Publish(context.Background(), Event{MediaID: 1}))
+}
+
+// TestMemoryBrokerSlowSubscriber tests that a full subscriber keeps the newest events
+func TestMemoryBrokerSlowSubscriber(t *testing.T) {
+	b := NewMemoryBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := b.Subscribe(ctx, 1)
+	require.NoError(t, err)
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		require.NoError(t, b.Publish(context.Background(), Event{MediaID: 1, Progress: float64(i)}))
+	}
+	var last Event
+	for range subscriberBuffer {
+		last = <-ch
+	}
+	assert.Equal(t, float64(subscriberBuffer), last.Progress)
+}
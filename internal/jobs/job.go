@@ -0,0 +1,126 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+
+	"photo-go/pkg/logger"
+)
+
+// Type là loại job, mỗi loại có một handler riêng
+type Type string
+
+const (
+	TypeProcessMedia Type = "process_media"
+	TypePurgeMedia   Type = "purge_media"
+)
+
+// State là trạng thái của job trong queue
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateDead      State = "dead" // hết số lần retry hoặc lỗi không thể retry
+	StateCanceled  State = "canceled"
+)
+
+// Terminal trả về true nếu job đã kết thúc và không chạy lại
+func (s State) Terminal() bool {
+	switch s {
+	case StateSucceeded, StateFailed, StateDead, StateCanceled:
+		return true
+	}
+	return false
+}
+
+var (
+	// ErrQueueFull trả về khi queue không nhận thêm job
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrNotFound trả về khi không có job với ID yêu cầu
+	ErrNotFound = errors.New("job not found")
+	// ErrAlreadyFinished trả về khi hủy job đã kết thúc
+	ErrAlreadyFinished = errors.New("job already finished")
+	// ErrCanceled là cause của context job khi người dùng hủy job
+	ErrCanceled = errors.New("job canceled")
+)
+
+type Job struct {
+	ID          uint
+	Type        Type
+	MediaID     uint
+	State       State
+	Attempts    int
+	MaxAttempts int
+	Stage       string  // probing, transcoding 720p, uploading, ...
+	Progress    float64 // 0-100
+	Error       string
+}
+
+// LastAttempt trả về true nếu lỗi ở lần chạy này sẽ không được retry nữa
+func (j *Job) LastAttempt() bool {
+	return j.Attempts >= j.MaxAttempts
+}
+
+// permanentError đánh dấu lỗi không nên retry (vd: file không hợp lệ)
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent bọc err để queue dead-letter job ngay thay vì retry
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent kiểm tra err có được đánh dấu bằng Permanent không
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// Handler xử lý một job; trả về error để đánh dấu job thất bại
+type Handler func(ctx context.Context, job *Job) error
+
+// FailureHandler được gọi khi job kết thúc mà Handler không chạy tới cuối để tự ghi nhận lỗi,
+// vd: job bị hủy lúc còn chờ (err là ErrCanceled) hoặc bị dead-letter vì worker chết ở lần thử cuối
+type FailureHandler func(ctx context.Context, job *Job, err error)
+
+// Queue định nghĩa interface hàng đợi job xử lý nền
+
+type Queue interface {
+	Enqueue(ctx context.Context, jobType Type, mediaID uint) (*Job, error)
+	Get(ctx context.Context, id uint) (*Job, error)
+	// Cancel hủy job đang chờ hoặc đang chạy; context của handler bị hủy với cause ErrCanceled
+	Cancel(ctx context.Context, id uint) (*Job, error)
+	// UpdateProgress lưu stage và phần trăm hoàn thành của job đang chạy
+	UpdateProgress(ctx context.Context, id uint, stage string, progress float64) error
+	Handle(jobType Type, h Handler)
+	// HandleFailure đăng ký FailureHandler cho jobType
+	HandleFailure(jobType Type, h FailureHandler)
+	Start(ctx context.Context)
+	Stop()
+}
+
+// runFailureHandler gọi h nếu có và chuyển panic thành log để không làm hỏng caller
+func runFailureHandler(ctx context.Context, h FailureHandler, job *Job, err error) {
+	if h == nil {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("Panic in failure handler of job %d: %v", job.ID, r)
+		}
+	}()
+	h(ctx, job, err)
+}
+
+// IsCanceled kiểm tra context của handler có bị hủy bởi Cancel không
+func IsCanceled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrCanceled)
+}
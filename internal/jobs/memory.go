@@ -0,0 +1,223 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"photo-go/pkg/logger"
+)
+
+// memoryJobTTL là thời gian job đã kết thúc còn được giữ để Get, sau đó bị dọn khỏi bộ nhớ
+const memoryJobTTL = time.Hour
+
+// MemoryQueue chạy job bằng pool goroutine trong process, job mất khi restart
+
+type MemoryQueue struct {
+	workers  int
+	ch       chan *Job
+	handlers map[Type]Handler
+	failures map[Type]FailureHandler
+
+	mu      sync.Mutex
+	nextID  uint
+	jobs    map[uint]*Job
+	running map[uint]context.CancelCauseFunc
+	// finished là thời điểm job kết thúc, dùng để dọn job quá memoryJobTTL
+	finished  map[uint]time.Time
+	lastPrune time.Time
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+func NewMemoryQueue(workers, size int) *MemoryQueue {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &MemoryQueue{
+		workers:  workers,
+		ch:       make(chan *Job, size),
+		handlers: map[Type]Handler{},
+		failures: map[Type]FailureHandler{},
+		jobs:     map[uint]*Job{},
+		running:  map[uint]context.CancelCauseFunc{},
+		finished: map[uint]time.Time{},
+	}
+}
+
+func (q *MemoryQueue) Handle(jobType Type, h Handler) {
+	q.handlers[jobType] = h
+}
+
+func (q *MemoryQueue) HandleFailure(jobType Type, h FailureHandler) {
+	q.failures[jobType] = h
+}
+
+func (q *MemoryQueue) Enqueue(ctx context.Context, jobType Type, mediaID uint) (*Job, error) {
+	q.mu.Lock()
+	q.prune(time.Now())
+	q.nextID++
+	job := &Job{ID: q.nextID, Type: jobType, MediaID: mediaID, State: StateQueued, MaxAttempts: 1}
+	// đăng ký trước khi gửi vào channel, worker có thể lấy job ra và gọi UpdateProgress ngay
+	q.jobs[job.ID] = job
+	snapshot := *job
+	q.mu.Unlock()
+	select {
+	case q.ch <- job:
+		logger.Info("Job enqueued: %d (%s, media %d)", job.ID, job.Type, job.MediaID)
+		return &snapshot, nil
+	default:
+		q.mu.Lock()
+		delete(q.jobs, job.ID)
+		q.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+}
+
+// prune xóa job đã kết thúc quá memoryJobTTL, chạy tối đa mỗi phút. Caller giữ q.mu.
+func (q *MemoryQueue) prune(now time.Time) {
+	if now.Sub(q.lastPrune) < memoryJobTTL/60 {
+		return
+	}
+	q.lastPrune = now
+	for id, at := range q.finished {
+		if now.Sub(at) > memoryJobTTL {
+			delete(q.jobs, id)
+			delete(q.finished, id)
+		}
+	}
+}
+
+func (q *MemoryQueue) Get(ctx context.Context, id uint) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	snapshot := *job
+	return &snapshot, nil
+}
+
+func (q *MemoryQueue) Cancel(ctx context.Context, id uint) (*Job, error) {
+	q.mu.Lock()
+	job, ok := q.jobs[id]
+	if !ok {
+		q.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	if job.State.Terminal() {
+		q.mu.Unlock()
+		return nil, ErrAlreadyFinished
+	}
+	cancel, running := q.running[id]
+	if running {
+		cancel(ErrCanceled)
+	} else {
+		// job còn trong channel, worker sẽ bỏ qua khi lấy ra
+		job.State = StateCanceled
+		q.finished[id] = time.Now()
+	}
+	snapshot := *job
+	q.mu.Unlock()
+	logger.Info("Job cancel requested: %d", id)
+	if !running {
+		// handler không chạy nên không tự ghi nhận job bị hủy
+		runFailureHandler(context.WithoutCancel(ctx), q.failures[job.Type], &snapshot, ErrCanceled)
+	}
+	return &snapshot, nil
+}
+
+func (q *MemoryQueue) UpdateProgress(ctx context.Context, id uint, stage string, progress float64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	job.Stage, job.Progress = stage, progress
+	return nil
+}
+
+// Start chạy các worker cho tới khi ctx bị hủy hoặc Stop được gọi
+func (q *MemoryQueue) Start(ctx context.Context) {
+	ctx, q.cancel = context.WithCancel(ctx)
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-q.ch:
+					q.run(ctx, job)
+				}
+			}
+		}()
+	}
+	logger.Info("Memory job queue started with %d workers", q.workers)
+}
+
+// Stop hủy các job đang chạy và chờ worker thoát
+func (q *MemoryQueue) Stop() {
+	if q.cancel != nil {
+		q.cancel()
+	}
+	q.wg.Wait()
+}
+
+func (q *MemoryQueue) run(ctx context.Context, job *Job) {
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	q.mu.Lock()
+	if job.State == StateCanceled {
+		q.mu.Unlock()
+		logger.Info("Job %d canceled before start", job.ID)
+		return
+	}
+	h, ok := q.handlers[job.Type]
+	if !ok {
+		job.State, job.Error = StateFailed, fmt.Sprintf("no handler for job type %s", job.Type)
+		q.finished[job.ID] = time.Now()
+		q.mu.Unlock()
+		logger.Errorf("Job %d failed: %s", job.ID, job.Error)
+		return
+	}
+	job.State = StateRunning
+	job.Attempts++
+	q.running[job.ID] = cancel
+	snapshot := *job
+	q.mu.Unlock()
+
+	logger.Info("Job started: %d (%s, media %d)", job.ID, job.Type, job.MediaID)
+	err := runHandler(jobCtx, h, &snapshot)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.running, job.ID)
+	q.finished[job.ID] = time.Now()
+	switch {
+	case IsCanceled(jobCtx):
+		job.State = StateCanceled
+		logger.Info("Job canceled: %d", job.ID)
+	case err != nil:
+		job.State, job.Error = StateFailed, err.Error()
+		logger.Error(err, "Job failed: %d", job.ID)
+	default:
+		job.State, job.Stage, job.Progress = StateSucceeded, "done", 100
+		logger.Info("Job succeeded: %d", job.ID)
+	}
+}
+
+// runHandler gọi handler và chuyển panic thành error để worker không chết
+func runHandler(ctx context.Context, h Handler, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in job handler: %v", r)
+		}
+	}()
+	return h(ctx, job)
+}
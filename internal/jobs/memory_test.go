@@ -0,0 +1,116 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMemoryQueueRunsHandlers tests that enqueued jobs reach their handler
+func TestMemoryQueueRunsHandlers(t *testing.T) {
+	q := NewMemoryQueue(2, 10)
+	done := make(chan uint, 3)
+	q.Handle(TypeProcessMedia, func(ctx context.Context, job *Job) error {
+		done <- job.MediaID
+		if job.MediaID == 2 {
+			panic("boom")
+		}
+		if job.MediaID == 3 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	q.Start(context.Background())
+	defer q.Stop()
+
+	var queued []*Job
+	for id := uint(1); id <= 3; id++ {
+		job, err := q.Enqueue(context.Background(), TypeProcessMedia, id)
+		require.NoError(t, err)
+		queued = append(queued, job)
+	}
+
+	seen := map[uint]bool{}
+	for i := 0; i < 3; i++ {
+		select {
+		case id := <-done:
+			seen[id] = true
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for jobs")
+		}
+	}
+	assert.Len(t, seen, 3)
+	assert.NotEqual(t, queued[0].ID, queued[1].ID)
+}
+
+// TestMemoryQueueFull tests that Enqueue fails fast when the buffer is full
+func TestMemoryQueueFull(t *testing.T) {
+	q := NewMemoryQueue(1, 1)
+	_, err := q.Enqueue(context.Background(), TypeProcessMedia, 1)
+	require.NoError(t, err)
+	_, err = q.Enqueue(context.Background(), TypeProcessMedia, 2)
+	assert.ErrorIs(t, err, ErrQueueFull)
+}
+
+// TestMemoryQueueCancelQueued tests that canceling a queued job calls the failure handler and never runs the job
+func TestMemoryQueueCancelQueued(t *testing.T) {
+	q := NewMemoryQueue(1, 10)
+	var failed []error
+	q.HandleFailure(TypeProcessMedia, func(ctx context.Context, job *Job, err error) {
+		assert.Equal(t, uint(7), job.MediaID)
+		failed = append(failed, err)
+	})
+	ran := make(chan uint, 1)
+	q.Handle(TypeProcessMedia, func(ctx context.Context, job *Job) error {
+		ran <- job.MediaID
+		return nil
+	})
+
+	job, err := q.Enqueue(context.Background(), TypeProcessMedia, 7)
+	require.NoError(t, err)
+	canceled, err := q.Cancel(context.Background(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StateCanceled, canceled.State)
+	require.Len(t, failed, 1)
+	assert.ErrorIs(t, failed[0], ErrCanceled)
+	_, err = q.Cancel(context.Background(), job.ID)
+	assert.ErrorIs(t, err, ErrAlreadyFinished)
+
+	q.Start(context.Background())
+	defer q.Stop()
+	select {
+	case <-ran:
+		t.Fatal("canceled job was run")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestMemoryQueuePrune tests that finished jobs are dropped after the TTL while queued jobs are kept
+func TestMemoryQueuePrune(t *testing.T) {
+	q := NewMemoryQueue(1, 10)
+	ctx := context.Background()
+	done, err := q.Enqueue(ctx, TypeProcessMedia, 1)
+	require.NoError(t, err)
+	_, err = q.Cancel(ctx, done.ID)
+	require.NoError(t, err)
+	queued, err := q.Enqueue(ctx, TypeProcessMedia, 2)
+	require.NoError(t, err)
+
+	q.mu.Lock()
+	q.prune(time.Now().Add(memoryJobTTL / 2))
+	q.mu.Unlock()
+	_, err = q.Get(ctx, done.ID)
+	require.NoError(t, err, "kept until the TTL")
+
+	q.mu.Lock()
+	q.prune(time.Now().Add(2 * memoryJobTTL))
+	q.mu.Unlock()
+	_, err = q.Get(ctx, done.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = q.Get(ctx, queued.ID)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,402 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"photo-go/internal/database"
+	"photo-go/pkg/logger"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresOptions cấu hình retry và visibility timeout cho PostgresQueue
+type PostgresOptions struct {
+	Workers           int
+	MaxAttempts       int
+	BackoffBase       time.Duration
+	BackoffMax        time.Duration
+	VisibilityTimeout time.Duration
+	PollInterval      time.Duration
+}
+
+// PostgresQueue lưu job trong bảng jobs nên không mất khi restart.
+// Worker claim job bằng SELECT ... FOR UPDATE SKIP LOCKED, gia hạn locked_until trong lúc chạy;
+// job của worker đã chết được claim lại khi locked_until hết hạn.
+
+type PostgresQueue struct {
+	db       *gorm.DB
+	opts     PostgresOptions
+	workerID string
+	handlers map[Type]Handler
+	failures map[Type]FailureHandler
+
+	mu      sync.Mutex
+	running map[uint]context.CancelCauseFunc
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// errLockLost là cause khi job bị worker khác claim lại trong lúc đang chạy
+var errLockLost = errors.New("job lock lost")
+
+func NewPostgresQueue(db *gorm.DB, opts PostgresOptions) *PostgresQueue {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	host, _ := os.Hostname()
+	return &PostgresQueue{
+		db:       db,
+		opts:     opts,
+		workerID: fmt.Sprintf("%s-%d", host, os.Getpid()),
+		handlers: map[Type]Handler{},
+		failures: map[Type]FailureHandler{},
+		running:  map[uint]context.CancelCauseFunc{},
+	}
+}
+
+func (q *PostgresQueue) Handle(jobType Type, h Handler) {
+	q.handlers[jobType] = h
+}
+
+func (q *PostgresQueue) HandleFailure(jobType Type, h FailureHandler) {
+	q.failures[jobType] = h
+}
+
+func (q *PostgresQueue) Enqueue(ctx context.Context, jobType Type, mediaID uint) (*Job, error) {
+	now := time.Now().Unix()
+	row := &database.Job{
+		Type:        string(jobType),
+		MediaID:     mediaID,
+		State:       string(StateQueued),
+		MaxAttempts: q.opts.MaxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := q.db.WithContext(ctx).Create(row).Error; err != nil {
+		return nil, err
+	}
+	logger.Info("Job enqueued: %d (%s, media %d)", row.ID, row.Type, row.MediaID)
+	return toJob(row), nil
+}
+
+func (q *PostgresQueue) Get(ctx context.Context, id uint) (*Job, error) {
+	var row database.Job
+	if err := q.db.WithContext(ctx).First(&row, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return toJob(&row), nil
+}
+
+// Cancel hủy ngay job đang chờ; job đang chạy được đánh dấu cancel_requested để worker giữ nó
+// (có thể ở process khác) hủy handler. Job của worker đã chết được hủy trực tiếp và gọi FailureHandler.
+func (q *PostgresQueue) Cancel(ctx context.Context, id uint) (*Job, error) {
+	var row database.Job
+	abandoned := false
+	err := q.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&row, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if State(row.State).Terminal() {
+			return ErrAlreadyFinished
+		}
+		now := time.Now().Unix()
+		row.CancelRequested = true
+		row.UpdatedAt = now
+		if row.State == string(StateQueued) || row.LockedUntil < now {
+			row.State = string(StateCanceled)
+			row.LockedBy, row.LockedUntil = "", 0
+			abandoned = true
+		}
+		return tx.Save(&row).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	q.mu.Lock()
+	if cancel, ok := q.running[id]; ok {
+		cancel(ErrCanceled)
+	}
+	q.mu.Unlock()
+	logger.Info("Job cancel requested: %d", id)
+	job := toJob(&row)
+	if abandoned {
+		runFailureHandler(context.WithoutCancel(ctx), q.failures[job.Type], job, ErrCanceled)
+	}
+	return job, nil
+}
+
+func (q *PostgresQueue) UpdateProgress(ctx context.Context, id uint, stage string, progress float64) error {
+	return q.db.WithContext(ctx).Model(&database.Job{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"stage": stage, "progress": progress, "updated_at": time.Now().Unix()}).Error
+}
+
+// Start chạy các worker poll bảng jobs cho tới khi ctx bị hủy hoặc Stop được gọi
+func (q *PostgresQueue) Start(ctx context.Context) {
+	ctx, q.cancel = context.WithCancel(ctx)
+	for i := 0; i < q.opts.Workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			q.loop(ctx)
+		}()
+	}
+	logger.Info("Postgres job queue started with %d workers (%s)", q.opts.Workers, q.workerID)
+}
+
+// Stop hủy các job đang chạy và chờ worker thoát; job bị hủy được trả về queue ngay (release),
+// không tính lần thử đó
+func (q *PostgresQueue) Stop() {
+	if q.cancel != nil {
+		q.cancel()
+	}
+	q.wg.Wait()
+}
+
+func (q *PostgresQueue) loop(ctx context.Context) {
+	for {
+		row, err := q.claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Error(err, "Claim job failed")
+		}
+		if row != nil {
+			q.run(ctx, row)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(q.opts.PollInterval):
+		}
+	}
+}
+
+// claim lấy một job sẵn sàng (queued tới hạn hoặc running đã hết visibility timeout)
+func (q *PostgresQueue) claim(ctx context.Context) (*database.Job, error) {
+	types := make([]string, 0, len(q.handlers))
+	for t := range q.handlers {
+		types = append(types, string(t))
+	}
+	if len(types) == 0 {
+		return nil, nil
+	}
+	now := time.Now().Unix()
+	var rows []database.Job
+	err := q.db.WithContext(ctx).Raw(`
+		UPDATE jobs SET state = ?, attempts = attempts + 1, locked_by = ?, locked_until = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE type IN ?
+			  AND NOT cancel_requested
+			  AND ((state = ? AND run_at <= ?) OR (state = ? AND locked_until < ?))
+			ORDER BY run_at, id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING *`,
+		string(StateRunning), q.workerID, now+int64(q.opts.VisibilityTimeout.Seconds()), now,
+		types, string(StateQueued), now, string(StateRunning), now,
+	).Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return &rows[0], nil
+}
+
+func (q *PostgresQueue) run(ctx context.Context, row *database.Job) {
+	job := toJob(row)
+	if row.Attempts > row.MaxAttempts {
+		// worker trước đã chết ở lần thử cuối, handler không còn lượt chạy để tự ghi nhận lỗi
+		err := fmt.Errorf("exceeded %d attempts", row.MaxAttempts)
+		q.finish(row, err)
+		runFailureHandler(ctx, q.failures[job.Type], job, err)
+		return
+	}
+	logger.Info("Job started: %d (%s, media %d, attempt %d/%d)", row.ID, row.Type, row.MediaID, row.Attempts, row.MaxAttempts)
+
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	q.mu.Lock()
+	q.running[row.ID] = cancel
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, row.ID)
+		q.mu.Unlock()
+	}()
+
+	stopHeartbeat := q.heartbeat(jobCtx, cancel, row)
+	err := runHandler(jobCtx, q.handlers[job.Type], job)
+	stopHeartbeat()
+	switch {
+	case IsCanceled(jobCtx):
+		q.finishCanceled(row)
+	case errors.Is(context.Cause(jobCtx), errLockLost):
+		logger.Warn("Job %d finished after lock was lost, result discarded", row.ID)
+	case ctx.Err() != nil:
+		q.release(row)
+	default:
+		q.finish(row, err)
+	}
+}
+
+// heartbeat chạy mỗi PollInterval: hủy handler khi job bị yêu cầu hủy hoặc mất lock,
+// và gia hạn locked_until sau mỗi 1/3 visibility timeout
+func (q *PostgresQueue) heartbeat(ctx context.Context, cancel context.CancelCauseFunc, row *database.Job) func() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(q.opts.PollInterval)
+		defer ticker.Stop()
+		lastExtend := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			var cur database.Job
+			if err := q.db.Select("cancel_requested", "locked_by").First(&cur, row.ID).Error; err != nil {
+				logger.Error(err, "Job heartbeat failed: %d", row.ID)
+				continue
+			}
+			if cur.CancelRequested {
+				cancel(ErrCanceled)
+				return
+			}
+			if cur.LockedBy != q.workerID {
+				logger.Warn("Job %d lock lost, canceling", row.ID)
+				cancel(errLockLost)
+				return
+			}
+			if time.Since(lastExtend) < q.opts.VisibilityTimeout/3 {
+				continue
+			}
+			now := time.Now().Unix()
+			err := q.db.Model(&database.Job{}).
+				Where("id = ? AND locked_by = ?", row.ID, q.workerID).
+				Updates(map[string]interface{}{"locked_until": now + int64(q.opts.VisibilityTimeout.Seconds()), "updated_at": now}).Error
+			if err != nil {
+				logger.Error(err, "Job heartbeat failed: %d", row.ID)
+				continue
+			}
+			lastExtend = time.Now()
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func (q *PostgresQueue) finishCanceled(row *database.Job) {
+	res := q.db.Model(&database.Job{}).Where("id = ? AND locked_by = ?", row.ID, q.workerID).
+		Updates(map[string]interface{}{
+			"state":        string(StateCanceled),
+			"locked_by":    "",
+			"locked_until": 0,
+			"updated_at":   time.Now().Unix(),
+		})
+	if res.Error != nil {
+		logger.Error(res.Error, "Save job result failed: %d", row.ID)
+		return
+	}
+	logger.Info("Job canceled: %d", row.ID)
+}
+
+// release trả job về queue khi process tắt giữa chừng, không tính lần thử này
+func (q *PostgresQueue) release(row *database.Job) {
+	logger.Warn("Job %d interrupted by shutdown, releasing", row.ID)
+	res := q.db.Model(&database.Job{}).Where("id = ? AND locked_by = ?", row.ID, q.workerID).
+		Updates(map[string]interface{}{
+			"state":        string(StateQueued),
+			"attempts":     gorm.Expr("attempts - 1"),
+			"locked_by":    "",
+			"locked_until": 0,
+			"run_at":       time.Now().Unix(),
+			"updated_at":   time.Now().Unix(),
+		})
+	if res.Error != nil {
+		logger.Error(res.Error, "Release job failed: %d", row.ID)
+	}
+}
+
+// finish ghi kết quả: thành công, retry với exponential backoff, hoặc dead-letter
+func (q *PostgresQueue) finish(row *database.Job, err error) {
+	now := time.Now().Unix()
+	updates := map[string]interface{}{"locked_by": "", "locked_until": 0, "updated_at": now}
+	switch {
+	case err == nil:
+		updates["state"] = string(StateSucceeded)
+		updates["last_error"] = ""
+		updates["stage"] = "done"
+		updates["progress"] = 100
+		logger.Info("Job succeeded: %d", row.ID)
+	case IsPermanent(err) || row.Attempts >= row.MaxAttempts:
+		updates["state"] = string(StateDead)
+		updates["last_error"] = err.Error()
+		logger.Error(err, "Job dead-lettered: %d (attempt %d/%d)", row.ID, row.Attempts, row.MaxAttempts)
+	default:
+		delay := q.backoff(row.Attempts)
+		updates["state"] = string(StateQueued)
+		updates["last_error"] = err.Error()
+		updates["run_at"] = now + int64(delay.Seconds())
+		logger.Error(err, "Job failed: %d, retry in %s (attempt %d/%d)", row.ID, delay, row.Attempts, row.MaxAttempts)
+	}
+	res := q.db.Model(&database.Job{}).Where("id = ? AND locked_by = ?", row.ID, q.workerID).Updates(updates)
+	if res.Error != nil {
+		logger.Error(res.Error, "Save job result failed: %d", row.ID)
+	}
+}
+
+// backoff = base * 2^(attempt-1), giới hạn bởi BackoffMax, cộng jitter tới 20%
+func (q *PostgresQueue) backoff(attempt int) time.Duration {
+	d := q.opts.BackoffBase
+	for i := 1; i < attempt && d < q.opts.BackoffMax; i++ {
+		d *= 2
+	}
+	if q.opts.BackoffMax > 0 && d > q.opts.BackoffMax {
+		d = q.opts.BackoffMax
+	}
+	if d > 0 {
+		d += time.Duration(rand.Int63n(int64(d)/5 + 1))
+	}
+	return d
+}
+
+func toJob(row *database.Job) *Job {
+	return &Job{
+		ID:          row.ID,
+		Type:        Type(row.Type),
+		MediaID:     row.MediaID,
+		State:       State(row.State),
+		Attempts:    row.Attempts,
+		MaxAttempts: row.MaxAttempts,
+		Stage:       row.Stage,
+		Progress:    row.Progress,
+		Error:       row.LastError,
+	}
+}
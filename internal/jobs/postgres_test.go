@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBackoff tests exponential growth, the cap and the jitter bound
+func TestBackoff(t *testing.T) {
+	q := NewPostgresQueue(nil, PostgresOptions{BackoffBase: 10 * time.Second, BackoffMax: time.Minute})
+	tests := []struct {
+		attempt int
+		min     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{10, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.attempt), func(t *testing.T) {
+			d := q.backoff(tt.attempt)
+			assert.GreaterOrEqual(t, d, tt.min)
+			assert.LessOrEqual(t, d, tt.min+tt.min/5)
+		})
+	}
+}
+
+// TestPermanent tests permanent error wrapping
+func TestPermanent(t *testing.T) {
+	base := errors.New("bad input")
+	err := fmt.Errorf("process: %w", Permanent(base))
+	assert.True(t, IsPermanent(err))
+	assert.ErrorIs(t, err, base)
+	assert.False(t, IsPermanent(base))
+	assert.Nil(t, Permanent(nil))
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package workspace
+
+import "errors"
+
+// freeBytes không hỗ trợ trên nền tảng này, chỉ áp dụng budget của Manager
+func freeBytes(string) (int64, error) {
+	return 0, errors.New("statfs not supported")
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package workspace
+
+import "syscall"
+
+// freeBytes trả về dung lượng trống khả dụng cho user trên filesystem chứa path
+func freeBytes(path string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
@@ -0,0 +1,154 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"photo-go/pkg/logger"
+)
+
+// ErrInsufficientSpace trả về khi không đủ dung lượng (theo budget hoặc đĩa thật) cho một job
+var ErrInsufficientSpace = errors.New("insufficient workspace disk space")
+
+// Manager cấp phát thư mục tạm riêng cho từng job dưới một root chung
+// và giữ tổng dung lượng đã đặt trước trong giới hạn cho phép.
+type Manager struct {
+	root         string
+	maxBytes     int64 // tổng dung lượng tối đa cho mọi workspace đang hoạt động, 0 = không giới hạn
+	minFreeBytes int64 // dung lượng trống tối thiểu phải còn lại trên đĩa
+
+	mu       sync.Mutex
+	reserved int64
+}
+
+// Workspace là thư mục tạm của một job, bị xóa khi Release hoặc khi context bị hủy
+type Workspace struct {
+	Dir string
+
+	manager *Manager
+	reserve int64
+	once    sync.Once
+	stop    func() bool
+}
+
+func NewManager(root string, maxBytes, minFreeBytes int64) (*Manager, error) {
+	if root == "" {
+		return nil, fmt.Errorf("workspace root is empty")
+	}
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return nil, fmt.Errorf("create workspace root: %w", err)
+	}
+	return &Manager{root: root, maxBytes: maxBytes, minFreeBytes: minFreeBytes}, nil
+}
+
+// Acquire tạo thư mục riêng cho job và đặt trước reserve byte trong budget.
+// Workspace tự dọn khi ctx bị hủy; caller vẫn phải defer Release cho các nhánh còn lại.
+func (m *Manager) Acquire(ctx context.Context, kind string, reserve int64) (*Workspace, error) {
+	if err := m.reserveBytes(reserve); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(m.root, SanitizeFilename(kind)+"-*")
+	if err != nil {
+		m.releaseBytes(reserve)
+		return nil, fmt.Errorf("create workspace: %w", err)
+	}
+	w := &Workspace{Dir: dir, manager: m, reserve: reserve}
+	w.stop = context.AfterFunc(ctx, func() {
+		logger.Warn("Workspace context canceled, cleaning up: %s", dir)
+		_ = w.Release()
+	})
+	logger.Debug("Workspace acquired: %s (reserved %d bytes)", dir, reserve)
+	return w, nil
+}
+
+// PurgeStale xóa các workspace bị bỏ lại (vd: process crash) cũ hơn maxAge
+func (m *Manager) PurgeStale(maxAge time.Duration) error {
+	entries, err := os.ReadDir(m.root)
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-maxAge)
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		p := filepath.Join(m.root, e.Name())
+		if err := os.RemoveAll(p); err != nil {
+			logger.Error(err, "Purge stale workspace failed: %s", p)
+			continue
+		}
+		logger.Info("Purged stale workspace: %s", p)
+	}
+	return nil
+}
+
+func (m *Manager) reserveBytes(n int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.maxBytes > 0 && m.reserved+n > m.maxBytes {
+		return fmt.Errorf("%w: budget %d, reserved %d, requested %d", ErrInsufficientSpace, m.maxBytes, m.reserved, n)
+	}
+	if free, err := freeBytes(m.root); err == nil && free-n < m.minFreeBytes {
+		return fmt.Errorf("%w: %d bytes free, requested %d", ErrInsufficientSpace, free, n)
+	}
+	m.reserved += n
+	return nil
+}
+
+func (m *Manager) releaseBytes(n int64) {
+	m.mu.Lock()
+	m.reserved -= n
+	m.mu.Unlock()
+}
+
+// Path trả về đường dẫn an toàn bên trong workspace cho tên file do người dùng cung cấp
+func (w *Workspace) Path(name string) string {
+	return filepath.Join(w.Dir, SanitizeFilename(name))
+}
+
+// Release xóa thư mục và trả lại dung lượng đã đặt trước. Gọi nhiều lần an toàn.
+func (w *Workspace) Release() error {
+	var err error
+	w.once.Do(func() {
+		if w.stop != nil {
+			w.stop()
+		}
+		err = os.RemoveAll(w.Dir)
+		w.manager.releaseBytes(w.reserve)
+		if err != nil {
+			logger.Error(err, "Workspace cleanup failed: %s", w.Dir)
+			return
+		}
+		logger.Debug("Workspace released: %s", w.Dir)
+	})
+	return err
+}
+
+// SanitizeFilename chỉ giữ lại basename với các ký tự an toàn, chặn path traversal
+func SanitizeFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	out := strings.TrimLeft(b.String(), ".")
+	if len(out) > 128 {
+		out = out[len(out)-128:]
+	}
+	if out == "" {
+		return "file"
+	}
+	return out
+}
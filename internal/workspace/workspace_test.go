@@ -0,0 +1,88 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSanitizeFilename tests that user supplied names cannot escape the workspace
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{"video.mp4", "video.mp4"},
+		{"../../etc/passwd", "passwd"},
+		{"..\\..\\boot.ini", "boot.ini"},
+		{"my clip (1).mov", "my_clip__1_.mov"},
+		{".hidden", "hidden"},
+		{"..", "file"},
+		{"", "file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			assert.Equal(t, tt.expected, SanitizeFilename(tt.in))
+		})
+	}
+}
+
+// TestAcquireRelease tests unique directories and cleanup on release
+func TestAcquireRelease(t *testing.T) {
+	m, err := NewManager(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+
+	a, err := m.Acquire(context.Background(), "upload", 10)
+	require.NoError(t, err)
+	b, err := m.Acquire(context.Background(), "upload", 10)
+	require.NoError(t, err)
+	assert.NotEqual(t, a.Dir, b.Dir)
+	assert.Equal(t, filepath.Join(a.Dir, "passwd"), a.Path("../passwd"))
+
+	require.NoError(t, a.Release())
+	require.NoError(t, a.Release())
+	_, err = os.Stat(a.Dir)
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, int64(10), m.reserved)
+
+	require.NoError(t, b.Release())
+	assert.Equal(t, int64(0), m.reserved)
+}
+
+// TestAcquireBudget tests that reservations beyond the budget are rejected
+func TestAcquireBudget(t *testing.T) {
+	m, err := NewManager(t.TempDir(), 100, 0)
+	require.NoError(t, err)
+
+	a, err := m.Acquire(context.Background(), "job", 80)
+	require.NoError(t, err)
+	_, err = m.Acquire(context.Background(), "job", 30)
+	assert.True(t, errors.Is(err, ErrInsufficientSpace))
+
+	require.NoError(t, a.Release())
+	b, err := m.Acquire(context.Background(), "job", 30)
+	require.NoError(t, err)
+	require.NoError(t, b.Release())
+}
+
+// TestAcquireCanceled tests that the workspace is removed when its context is canceled
+func TestAcquireCanceled(t *testing.T) {
+	m, err := NewManager(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err := m.Acquire(ctx, "job", 5)
+	require.NoError(t, err)
+	cancel()
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(w.Dir)
+		return os.IsNotExist(err)
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"photo-go/pkg/logger"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+)
+
+// BucketOptions cấu hình bucket khi khởi động
+type BucketOptions struct {
+	Region     string
+	Versioning bool
+	// Lifecycle nil giữ nguyên lifecycle hiện có của bucket, khác nil chỉ thay các rule cùng ID
+	// nên rule do admin đặt vẫn còn
+	Lifecycle *lifecycle.Configuration
+}
+
+// LifecycleOptions sinh rule lifecycle cho bucket, số ngày <= 0 bỏ qua rule tương ứng
+type LifecycleOptions struct {
+	// TempPrefixes là prefix chứa object tạm (chunk, upload dở) được xóa sau TempExpiryDays,
+	// chốt chặn cuối khi job dọn dẹp của app không chạy
+	TempPrefixes   []string
+	TempExpiryDays int
+	// NoncurrentExpiryDays xóa phiên bản cũ của object khi bucket bật versioning
+	NoncurrentExpiryDays int
+}
+
+// BucketLifecycle trả về cấu hình lifecycle theo opts, nil khi không có rule nào
+func BucketLifecycle(opts LifecycleOptions) *lifecycle.Configuration {
+	cfg := lifecycle.NewConfiguration()
+	if opts.TempExpiryDays > 0 {
+		for _, prefix := range opts.TempPrefixes {
+			if prefix == "" {
+				// rule không prefix sẽ xóa cả bucket
+				continue
+			}
+			cfg.Rules = append(cfg.Rules, lifecycle.Rule{
+				ID:         "expire-" + strings.Trim(prefix, "/"),
+				Status:     "Enabled",
+				RuleFilter: lifecycle.Filter{Prefix: prefix},
+				Expiration: lifecycle.Expiration{Days: lifecycle.ExpirationDays(opts.TempExpiryDays)},
+			})
+		}
+	}
+	if opts.NoncurrentExpiryDays > 0 {
+		cfg.Rules = append(cfg.Rules, lifecycle.Rule{
+			ID:     "expire-noncurrent",
+			Status: "Enabled",
+			NoncurrentVersionExpiration: lifecycle.NoncurrentVersionExpiration{
+				NoncurrentDays: lifecycle.ExpirationDays(opts.NoncurrentExpiryDays),
+			},
+		})
+	}
+	if len(cfg.Rules) == 0 {
+		return nil
+	}
+	return cfg
+}
+
+// EnsureBucket tạo bucket nếu chưa có, bật versioning và ghép lifecycle theo opts vào lifecycle hiện có.
+// Versioning đã bật không bị tắt lại vì bucket có versioning chỉ có thể suspend.
+func (m *MinioClient) EnsureBucket(ctx context.Context, opts BucketOptions) error {
+	exists, err := m.Client.BucketExists(ctx, m.Bucket)
+	if err != nil {
+		return fmt.Errorf("check bucket %s: %w", m.Bucket, err)
+	}
+	if !exists {
+		if err := m.Client.MakeBucket(ctx, m.Bucket, minio.MakeBucketOptions{Region: opts.Region}); err != nil {
+			// replica khác có thể vừa tạo cùng lúc
+			if code := minio.ToErrorResponse(err).Code; code != "BucketAlreadyOwnedByYou" && code != "BucketAlreadyExists" {
+				return fmt.Errorf("create bucket %s: %w", m.Bucket, err)
+			}
+		} else {
+			logger.Info("Minio bucket created: %s", m.Bucket)
+		}
+	}
+	if opts.Versioning {
+		if err := m.Client.EnableVersioning(ctx, m.Bucket); err != nil {
+			return fmt.Errorf("enable versioning on %s: %w", m.Bucket, err)
+		}
+		logger.Info("Minio bucket versioning enabled: %s", m.Bucket)
+	}
+	if opts.Lifecycle != nil {
+		current, err := m.Client.GetBucketLifecycle(ctx, m.Bucket)
+		if err != nil {
+			if minio.ToErrorResponse(err).Code != "NoSuchLifecycleConfiguration" {
+				return fmt.Errorf("get lifecycle of %s: %w", m.Bucket, err)
+			}
+			current = nil
+		}
+		merged := MergeLifecycle(current, opts.Lifecycle)
+		if err := m.Client.SetBucketLifecycle(ctx, m.Bucket, merged); err != nil {
+			return fmt.Errorf("set lifecycle on %s: %w", m.Bucket, err)
+		}
+		logger.Info("Minio bucket lifecycle set: %s (%d rules, %d managed)", m.Bucket, len(merged.Rules), len(opts.Lifecycle.Rules))
+	}
+	return nil
+}
+
+// MergeLifecycle ghép rule của generated vào current theo ID: rule cùng ID bị thay, rule khác
+// (vd: do admin thêm) giữ nguyên thứ tự, rule mới thêm vào cuối. current nil = bucket chưa có lifecycle.
+func MergeLifecycle(current, generated *lifecycle.Configuration) *lifecycle.Configuration {
+	merged := lifecycle.NewConfiguration()
+	byID := make(map[string]lifecycle.Rule, len(generated.Rules))
+	for _, r := range generated.Rules {
+		byID[r.ID] = r
+	}
+	if current != nil {
+		for _, r := range current.Rules {
+			if g, ok := byID[r.ID]; ok {
+				r = g
+				delete(byID, r.ID)
+			}
+			merged.Rules = append(merged.Rules, r)
+		}
+	}
+	for _, r := range generated.Rules {
+		if _, ok := byID[r.ID]; ok {
+			merged.Rules = append(merged.Rules, r)
+		}
+	}
+	return merged
+}
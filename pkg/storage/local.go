@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// localTempDir là thư mục con giữ file đang ghi dở, rename vào chỗ khi ghi xong để reader không thấy object thiếu
+const localTempDir = ".tmp"
+
+// LocalStorage lưu mỗi object thành một file dưới Root, dùng cho dev và deploy một node không có MinIO
+type LocalStorage struct {
+	Root string
+}
+
+func NewLocalStorage(root string) (*LocalStorage, error) {
+	if err := os.MkdirAll(filepath.Join(root, localTempDir), 0o755); err != nil {
+		return nil, fmt.Errorf("create storage dir: %w", err)
+	}
+	return &LocalStorage{Root: root}, nil
+}
+
+// path trả về key đã chuẩn hóa và đường dẫn file của object
+func (s *LocalStorage) path(name string) (string, string, error) {
+	key, err := cleanName(name)
+	if err != nil {
+		return "", "", err
+	}
+	if key == localTempDir || strings.HasPrefix(key, localTempDir+"/") {
+		return "", "", fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	return key, filepath.Join(s.Root, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, name string, r io.Reader, size int64, opts PutOptions) error {
+	_, dst, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Join(s.Root, localTempDir), "put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if size >= 0 && n != size {
+		return fmt.Errorf("put %s: wrote %d bytes, expected %d", name, n, size)
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+func (s *LocalStorage) PutFile(ctx context.Context, name, filePath string, opts PutOptions) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.Put(ctx, name, f, -1, opts)
+}
+
+func (s *LocalStorage) Get(ctx context.Context, name string, rng *Range) (io.ReadCloser, ObjectInfo, error) {
+	key, p, err := s.path(name)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, ObjectInfo{}, localError(err, name)
+	}
+	st, err := f.Stat()
+	if err != nil || st.IsDir() {
+		f.Close()
+		return nil, ObjectInfo{}, localError(err, name)
+	}
+	info := s.objectInfo(key, st)
+	if rng == nil {
+		return f, info, nil
+	}
+	if _, err := f.Seek(rng.Offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, ObjectInfo{}, err
+	}
+	var r io.Reader = f
+	if rng.Length > 0 {
+		r = io.LimitReader(f, rng.Length)
+	}
+	return readCloser{r, f}, info, nil
+}
+
+func (s *LocalStorage) Stat(ctx context.Context, name string) (ObjectInfo, error) {
+	key, p, err := s.path(name)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	st, err := os.Stat(p)
+	if err != nil || st.IsDir() {
+		return ObjectInfo{}, localError(err, name)
+	}
+	return s.objectInfo(key, st), nil
+}
+
+// Delete xóa file và các thư mục cha đã rỗng
+func (s *LocalStorage) Delete(ctx context.Context, names ...string) error {
+	for _, name := range names {
+		_, p, err := s.path(name)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		for dir := filepath.Dir(p); dir != filepath.Clean(s.Root); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (s *LocalStorage) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	return deleteListed(ctx, s, prefix)
+}
+
+func (s *LocalStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	// chỉ duyệt thư mục sâu nhất chứa prefix
+	dir := s.Root
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		_, p, err := s.path(prefix[:i])
+		if err != nil {
+			return nil, err
+		}
+		dir = p
+	}
+	var out []ObjectInfo
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		rel, _ := filepath.Rel(s.Root, p)
+		key := filepath.ToSlash(rel)
+		if d.IsDir() {
+			if key == localTempDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		st, err := d.Info()
+		if err != nil {
+			return err
+		}
+		out = append(out, s.objectInfo(key, st))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+// Presign không hỗ trợ: file local không có URL công khai, caller phục vụ qua API
+func (s *LocalStorage) Presign(ctx context.Context, method, name string, expiry time.Duration, headers http.Header) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (s *LocalStorage) Copy(ctx context.Context, src, dst string) error {
+	body, info, err := s.Get(ctx, src, nil)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return s.Put(ctx, dst, body, info.Size, PutOptions{})
+}
+
+func (s *LocalStorage) objectInfo(key string, st fs.FileInfo) ObjectInfo {
+	return ObjectInfo{
+		Key:         key,
+		Size:        st.Size(),
+		ETag:        fmt.Sprintf("%x-%x", st.ModTime().UnixNano(), st.Size()),
+		ContentType: ContentTypeFor(key),
+		ModTime:     st.ModTime(),
+	}
+}
+
+// localError chuyển file không tồn tại (hoặc là thư mục) thành ErrNotFound
+func localError(err error, name string) error {
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return err
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
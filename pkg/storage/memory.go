@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStorage giữ object trong bộ nhớ, dùng cho test và chạy thử không cần MinIO. Dữ liệu mất khi restart.
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data    []byte // không sửa sau khi ghi, Put thay slice mới
+	etag    string
+	opts    PutOptions
+	modTime time.Time
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{objects: map[string]memoryObject{}}
+}
+
+func (s *MemoryStorage) Put(ctx context.Context, name string, r io.Reader, size int64, opts PutOptions) error {
+	key, err := cleanName(name)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if size >= 0 && int64(len(data)) != size {
+		return fmt.Errorf("put %s: read %d bytes, expected %d", name, len(data), size)
+	}
+	sum := md5.Sum(data)
+	s.mu.Lock()
+	s.objects[key] = memoryObject{data: data, etag: hex.EncodeToString(sum[:]), opts: opts, modTime: time.Now()}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStorage) PutFile(ctx context.Context, name, filePath string, opts PutOptions) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.Put(ctx, name, f, -1, opts)
+}
+
+func (s *MemoryStorage) Get(ctx context.Context, name string, rng *Range) (io.ReadCloser, ObjectInfo, error) {
+	key, obj, err := s.get(name)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	data := obj.data
+	if rng != nil {
+		start := min(rng.Offset, int64(len(data)))
+		end := int64(len(data))
+		if rng.Length > 0 {
+			end = min(start+rng.Length, end)
+		}
+		data = data[start:end]
+	}
+	return io.NopCloser(bytes.NewReader(data)), obj.info(key), nil
+}
+
+func (s *MemoryStorage) Stat(ctx context.Context, name string) (ObjectInfo, error) {
+	key, obj, err := s.get(name)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return obj.info(key), nil
+}
+
+func (s *MemoryStorage) Delete(ctx context.Context, names ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, name := range names {
+		key, err := cleanName(name)
+		if err != nil {
+			return err
+		}
+		delete(s.objects, key)
+	}
+	return nil
+}
+
+func (s *MemoryStorage) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	return deleteListed(ctx, s, prefix)
+}
+
+func (s *MemoryStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	s.mu.RLock()
+	var out []ObjectInfo
+	for key, obj := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			out = append(out, obj.info(key))
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+// Presign không hỗ trợ: object trong bộ nhớ không có URL công khai, caller phục vụ qua API
+func (s *MemoryStorage) Presign(ctx context.Context, method, name string, expiry time.Duration, headers http.Header) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (s *MemoryStorage) Copy(ctx context.Context, src, dst string) error {
+	_, obj, err := s.get(src)
+	if err != nil {
+		return err
+	}
+	key, err := cleanName(dst)
+	if err != nil {
+		return err
+	}
+	// như S3 copy: Content-Type theo tên đích, metadata khác giữ nguyên
+	obj.opts.ContentType = ""
+	obj.modTime = time.Now()
+	s.mu.Lock()
+	s.objects[key] = obj
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStorage) get(name string) (string, memoryObject, error) {
+	key, err := cleanName(name)
+	if err != nil {
+		return "", memoryObject{}, err
+	}
+	s.mu.RLock()
+	obj, ok := s.objects[key]
+	s.mu.RUnlock()
+	if !ok {
+		return "", memoryObject{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return key, obj, nil
+}
+
+func (o memoryObject) info(key string) ObjectInfo {
+	return ObjectInfo{
+		Key:          key,
+		Size:         int64(len(o.data)),
+		ETag:         o.etag,
+		ContentType:  o.opts.contentType(key),
+		CacheControl: o.opts.CacheControl,
+		Metadata:     o.opts.Metadata,
+		ModTime:      o.modTime,
+	}
+}
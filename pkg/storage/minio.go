@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"photo-go/pkg/logger"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// streamPartSize là kích thước part khi Put không biết trước độ dài
+const streamPartSize = 16 << 20
+
+// MinioClient là Storage trên một bucket MinIO/S3, hỗ trợ cả presign và multipart
+type MinioClient struct {
+	Client *minio.Client
+	Bucket string
+}
+
+func NewMinioClient(endpoint, accessKey, secretKey, bucket string, useSSL bool) (*MinioClient, error) {
+	cli, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &MinioClient{Client: cli, Bucket: bucket}, nil
+}
+
+// Put stream r lên MinIO. Khi size < 0, dữ liệu được chia part streamPartSize byte
+// thay vì part mặc định của minio-go (đủ cho 5 TiB nên mỗi part chiếm hàng trăm MB bộ nhớ).
+func (m *MinioClient) Put(ctx context.Context, name string, r io.Reader, size int64, opts PutOptions) error {
+	po := putObjectOptions(name, opts)
+	if size < 0 {
+		po.PartSize = streamPartSize
+	}
+	_, err := m.Client.PutObject(ctx, m.Bucket, name, r, size, po)
+	if err != nil {
+		logger.Error(err, "Minio put failed: %s", name)
+	}
+	return err
+}
+
+func (m *MinioClient) PutFile(ctx context.Context, name, filePath string, opts PutOptions) error {
+	logger.Info("Uploading to Minio: %s from %s", name, filePath)
+	_, err := m.Client.FPutObject(ctx, m.Bucket, name, filePath, putObjectOptions(name, opts))
+	if err != nil {
+		logger.Error(err, "Minio upload failed: %s", name)
+	} else {
+		logger.Info("Minio upload success: %s", name)
+	}
+	return err
+}
+
+func (m *MinioClient) Get(ctx context.Context, name string, rng *Range) (io.ReadCloser, ObjectInfo, error) {
+	opts := minio.GetObjectOptions{}
+	// SetRange(0, 0) gửi bytes=0-0 (1 byte), range từ đầu tới hết object thì không cần header
+	if rng != nil && (rng.Offset > 0 || rng.Length > 0) {
+		end := int64(0) // 0 = tới hết object
+		if rng.Length > 0 {
+			end = rng.Offset + rng.Length - 1
+		}
+		if err := opts.SetRange(rng.Offset, end); err != nil {
+			return nil, ObjectInfo{}, err
+		}
+	}
+	obj, err := m.Client.GetObject(ctx, m.Bucket, name, opts)
+	if err != nil {
+		return nil, ObjectInfo{}, minioError(err, name)
+	}
+	// GetObject lười, Stat mới thực sự gọi MinIO và báo lỗi object không tồn tại
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, ObjectInfo{}, minioError(err, name)
+	}
+	return obj, toObjectInfo(info), nil
+}
+
+// Stat đọc metadata của object kèm checksum (x-amz-checksum-*) nếu object được ghi với checksum
+func (m *MinioClient) Stat(ctx context.Context, name string) (ObjectInfo, error) {
+	info, err := m.Client.StatObject(ctx, m.Bucket, name, minio.StatObjectOptions{Checksum: true})
+	if err != nil {
+		return ObjectInfo{}, minioError(err, name)
+	}
+	return toObjectInfo(info), nil
+}
+
+// Delete xóa nhiều object trong một batch, bỏ qua object không tồn tại
+func (m *MinioClient) Delete(ctx context.Context, names ...string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	logger.Info("Removing %d objects from Minio", len(names))
+	objectsCh := make(chan minio.ObjectInfo, len(names))
+	for _, name := range names {
+		objectsCh <- minio.ObjectInfo{Key: name}
+	}
+	close(objectsCh)
+	var firstErr error
+	for rErr := range m.Client.RemoveObjects(ctx, m.Bucket, objectsCh, minio.RemoveObjectsOptions{}) {
+		logger.Error(rErr.Err, "Minio remove failed: %s", rErr.ObjectName)
+		if firstErr == nil {
+			firstErr = rErr.Err
+		}
+	}
+	return firstErr
+}
+
+// DeletePrefix xóa mọi object dưới prefix, list và remove chạy song song theo batch
+// nên không cần giữ cả danh sách object trong bộ nhớ
+func (m *MinioClient) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	if prefix == "" {
+		return 0, fmt.Errorf("%w: empty prefix", ErrInvalidName)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		listErr error
+		count   int
+	)
+	objectsCh := make(chan minio.ObjectInfo)
+	listDone := make(chan struct{})
+	go func() {
+		defer close(listDone)
+		defer close(objectsCh)
+		for obj := range m.Client.ListObjects(ctx, m.Bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+			if obj.Err != nil {
+				listErr = obj.Err
+				return
+			}
+			count++
+			select {
+			case objectsCh <- obj:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var firstErr error
+	for rErr := range m.Client.RemoveObjects(ctx, m.Bucket, objectsCh, minio.RemoveObjectsOptions{}) {
+		logger.Error(rErr.Err, "Minio remove failed: %s", rErr.ObjectName)
+		if firstErr == nil {
+			firstErr = rErr.Err
+			cancel()
+		}
+	}
+	cancel()
+	<-listDone
+	if firstErr == nil {
+		firstErr = listErr
+	}
+	if firstErr != nil {
+		logger.Error(firstErr, "Minio delete prefix failed: %s", prefix)
+		return 0, firstErr
+	}
+	logger.Info("Removed %d objects under Minio prefix: %s", count, prefix)
+	return count, nil
+}
+
+func (m *MinioClient) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var out []ObjectInfo
+	for obj := range m.Client.ListObjects(ctx, m.Bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			logger.Error(obj.Err, "Minio list failed: %s", prefix)
+			return nil, obj.Err
+		}
+		out = append(out, toObjectInfo(obj))
+	}
+	return out, nil
+}
+
+func (m *MinioClient) Presign(ctx context.Context, method, name string, expiry time.Duration, headers http.Header) (string, error) {
+	u, err := m.Client.PresignHeader(ctx, method, m.Bucket, name, expiry, nil, headers)
+	if err != nil {
+		logger.Error(err, "Minio presign %s failed: %s", method, name)
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// Copy sao chép object phía server, dữ liệu không đi qua API.
+// Dùng ComposeObject vì CopyObject giới hạn 5 GiB, compose tự chia thành multipart copy.
+func (m *MinioClient) Copy(ctx context.Context, src, dst string) error {
+	return m.Compose(ctx, dst, PutOptions{}, src)
+}
+
+// Compose ghép các object srcs theo thứ tự thành dst phía server. Mọi src trừ cái cuối phải >= 5 MiB.
+func (m *MinioClient) Compose(ctx context.Context, dst string, opts PutOptions, srcs ...string) error {
+	sources := make([]minio.CopySrcOptions, 0, len(srcs))
+	for _, src := range srcs {
+		sources = append(sources, minio.CopySrcOptions{Bucket: m.Bucket, Object: src})
+	}
+	_, err := m.Client.ComposeObject(ctx, minio.CopyDestOptions{
+		Bucket:          m.Bucket,
+		Object:          dst,
+		ContentType:     opts.contentType(dst),
+		CacheControl:    opts.CacheControl,
+		UserMetadata:    opts.Metadata,
+		ReplaceMetadata: true,
+	}, sources...)
+	if err != nil {
+		logger.Error(err, "Minio compose failed: %v -> %s", srcs, dst)
+		return minioError(err, dst)
+	}
+	logger.Info("Minio compose success: %v -> %s", srcs, dst)
+	return nil
+}
+
+// NewMultipartUpload khởi tạo multipart upload để client PUT từng part bằng PresignPart
+func (m *MinioClient) NewMultipartUpload(ctx context.Context, name string) (string, error) {
+	core := minio.Core{Client: m.Client}
+	id, err := core.NewMultipartUpload(ctx, m.Bucket, name, minio.PutObjectOptions{ContentType: ContentTypeFor(name)})
+	if err != nil {
+		logger.Error(err, "Minio new multipart upload failed: %s", name)
+	}
+	return id, err
+}
+
+func (m *MinioClient) PresignPart(ctx context.Context, name, uploadID string, partNumber int, expiry time.Duration) (string, error) {
+	params := url.Values{}
+	params.Set("partNumber", strconv.Itoa(partNumber))
+	params.Set("uploadId", uploadID)
+	u, err := m.Client.PresignHeader(ctx, http.MethodPut, m.Bucket, name, expiry, params, nil)
+	if err != nil {
+		logger.Error(err, "Minio presign part failed: %s #%d", name, partNumber)
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// CompleteMultipartUpload trả về ErrInvalidPart khi part thiếu, sai ETag hoặc multipart upload không còn
+func (m *MinioClient) CompleteMultipartUpload(ctx context.Context, name, uploadID string, parts []Part) error {
+	completed := make([]minio.CompletePart, 0, len(parts))
+	for _, p := range parts {
+		completed = append(completed, minio.CompletePart{PartNumber: p.PartNumber, ETag: p.ETag})
+	}
+	core := minio.Core{Client: m.Client}
+	_, err := core.CompleteMultipartUpload(ctx, m.Bucket, name, uploadID, completed, minio.PutObjectOptions{})
+	if err != nil {
+		logger.Error(err, "Minio complete multipart upload failed: %s", name)
+		switch minio.ToErrorResponse(err).Code {
+		case "InvalidPart", "InvalidPartOrder", "EntityTooSmall", "NoSuchUpload":
+			return fmt.Errorf("%w: %w", ErrInvalidPart, err)
+		}
+	}
+	return err
+}
+
+// AbortMultipartUpload hủy multipart upload và giải phóng các part đã nhận, không lỗi nếu upload không còn
+func (m *MinioClient) AbortMultipartUpload(ctx context.Context, name, uploadID string) error {
+	core := minio.Core{Client: m.Client}
+	err := core.AbortMultipartUpload(ctx, m.Bucket, name, uploadID)
+	if err != nil && minio.ToErrorResponse(err).Code != "NoSuchUpload" {
+		logger.Error(err, "Abort multipart upload failed: %s", name)
+		return err
+	}
+	return nil
+}
+
+// minioError chuyển lỗi object/bucket không tồn tại thành ErrNotFound
+func minioError(err error, name string) error {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NoSuchBucket":
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return err
+}
+
+func putObjectOptions(name string, opts PutOptions) minio.PutObjectOptions {
+	return minio.PutObjectOptions{
+		ContentType:  opts.contentType(name),
+		CacheControl: opts.CacheControl,
+		UserMetadata: opts.Metadata,
+	}
+}
+
+func toObjectInfo(info minio.ObjectInfo) ObjectInfo {
+	return ObjectInfo{
+		Key:            info.Key,
+		Size:           info.Size,
+		ETag:           info.ETag,
+		ContentType:    info.ContentType,
+		CacheControl:   info.Metadata.Get("Cache-Control"),
+		Metadata:       info.UserMetadata,
+		ModTime:        info.LastModified,
+		ChecksumSHA256: info.ChecksumSHA256,
+	}
+}
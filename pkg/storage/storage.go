@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Backend của Storage theo STORAGE_BACKEND
+const (
+	BackendMinio  = "minio"
+	BackendLocal  = "local"
+	BackendMemory = "memory"
+)
+
+var (
+	// ErrNotFound trả về khi object không tồn tại
+	ErrNotFound = errors.New("object not found")
+	// ErrUnsupported trả về khi backend không hỗ trợ thao tác (vd: presign trên local/memory)
+	ErrUnsupported = errors.New("operation not supported by storage backend")
+	// ErrInvalidName trả về khi tên object rỗng hoặc thoát ra ngoài storage ("..")
+	ErrInvalidName = errors.New("invalid object name")
+	// ErrInvalidPart trả về khi complete multipart với part thiếu, sai ETag hoặc upload không còn
+	ErrInvalidPart = errors.New("invalid multipart part")
+)
+
+// Storage lưu object theo tên dạng path "a/b/c.ext". Mọi service chỉ phụ thuộc interface này,
+// backend (MinIO, filesystem, memory) được chọn theo settings.
+type Storage interface {
+	// Put ghi object từ r, size < 0 khi chưa biết độ dài
+	Put(ctx context.Context, name string, r io.Reader, size int64, opts PutOptions) error
+	// PutFile ghi object từ file local
+	PutFile(ctx context.Context, name, filePath string, opts PutOptions) error
+	// Get mở object để đọc, rng nil đọc cả object. Caller phải Close.
+	Get(ctx context.Context, name string, rng *Range) (io.ReadCloser, ObjectInfo, error)
+	Stat(ctx context.Context, name string) (ObjectInfo, error)
+	// Delete xóa các object, bỏ qua object không tồn tại
+	Delete(ctx context.Context, names ...string) error
+	// DeletePrefix xóa mọi object dưới prefix (khác rỗng), trả về số object đã xóa
+	DeletePrefix(ctx context.Context, prefix string) (int, error)
+	// List trả về mọi object dưới prefix, sắp theo key
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// Presign tạo URL có chữ ký cho method (GET/PUT), headers được ký kèm nên client phải gửi đúng.
+	// Backend không có URL công khai trả về ErrUnsupported.
+	Presign(ctx context.Context, method, name string, expiry time.Duration, headers http.Header) (string, error)
+	// Copy sao chép object src sang dst trong cùng storage
+	Copy(ctx context.Context, src, dst string) error
+}
+
+// Multipart là phần mở rộng cho backend hỗ trợ multipart upload qua presigned URL (MinIO/S3)
+type Multipart interface {
+	NewMultipartUpload(ctx context.Context, name string) (string, error)
+	// PresignPart tạo URL PUT cho part thứ partNumber (bắt đầu từ 1)
+	PresignPart(ctx context.Context, name, uploadID string, partNumber int, expiry time.Duration) (string, error)
+	// CompleteMultipartUpload ghép các part (đã sắp theo PartNumber) thành object
+	CompleteMultipartUpload(ctx context.Context, name, uploadID string, parts []Part) error
+	AbortMultipartUpload(ctx context.Context, name, uploadID string) error
+}
+
+// Part là một part đã upload của multipart upload
+type Part struct {
+	PartNumber int
+	ETag       string
+}
+
+// PutOptions là metadata ghi kèm object. ContentType rỗng thì đoán theo phần mở rộng của tên.
+// Backend local chỉ giữ nội dung file, bỏ qua CacheControl và Metadata.
+type PutOptions struct {
+	ContentType  string
+	CacheControl string
+	Metadata     map[string]string
+}
+
+func (o PutOptions) contentType(name string) string {
+	if o.ContentType != "" {
+		return o.ContentType
+	}
+	return ContentTypeFor(name)
+}
+
+// Range là đoạn byte cần đọc, Length <= 0 đọc tới hết object
+type Range struct {
+	Offset int64
+	Length int64
+}
+
+// ObjectInfo là metadata của object
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ETag         string
+	ContentType  string
+	CacheControl string
+	Metadata     map[string]string
+	ModTime      time.Time
+	// ChecksumSHA256 là base64 sha256 backend đã kiểm khi ghi, rỗng nếu backend không lưu.
+	// Object multipart có dạng composite "<base64>-<parts>".
+	ChecksumSHA256 string
+}
+
+// IsNotFound trả về true nếu err là object không tồn tại
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// deleteListed là DeletePrefix cho backend không có xóa theo prefix riêng
+func deleteListed(ctx context.Context, s Storage, prefix string) (int, error) {
+	if prefix == "" {
+		return 0, fmt.Errorf("%w: empty prefix", ErrInvalidName)
+	}
+	objects, err := s.List(ctx, prefix)
+	if err != nil || len(objects) == 0 {
+		return 0, err
+	}
+	names := make([]string, 0, len(objects))
+	for _, o := range objects {
+		names = append(names, o.Key)
+	}
+	if err := s.Delete(ctx, names...); err != nil {
+		return 0, err
+	}
+	return len(names), nil
+}
+
+// GetFile tải object về file local filePath
+func GetFile(ctx context.Context, s Storage, name, filePath string) error {
+	body, _, err := s.Get(ctx, name, nil)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("download %s: %w", name, err)
+	}
+	return nil
+}
+
+// cleanName chuẩn hóa tên object, chặn path traversal cho backend dùng tên làm đường dẫn
+func cleanName(name string) (string, error) {
+	clean := strings.TrimPrefix(strings.ReplaceAll(name, "\\", "/"), "/")
+	for _, seg := range strings.Split(clean, "/") {
+		if seg == ".." {
+			return "", fmt.Errorf("%w: %q", ErrInvalidName, name)
+		}
+	}
+	if clean = path.Clean(clean); clean == "." {
+		return "", fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	return clean, nil
+}
+
+// ContentTypeFor trả về Content-Type theo phần mở rộng của object
+func ContentTypeFor(name string) string {
+	switch ext := strings.ToLower(path.Ext(name)); ext {
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl"
+	case ".ts":
+		return "video/mp2t"
+	case ".m4s":
+		return "video/iso.segment"
+	case ".vtt":
+		return "text/vtt"
+	default:
+		if t := mime.TypeByExtension(ext); t != "" {
+			return t
+		}
+		return "application/octet-stream"
+	}
+}
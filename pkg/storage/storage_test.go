@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBackends tests that the local and memory backends behave the same through the Storage interface
+func TestBackends(t *testing.T) {
+	local, err := NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+	for name, s := range map[string]Storage{"local": local, "memory": NewMemoryStorage()} {
+		t.Run(name, func(t *testing.T) {
+			testStorage(t, s)
+		})
+	}
+}
+
+func testStorage(t *testing.T, s Storage) {
+	ctx := context.Background()
+	require.NoError(t, s.Put(ctx, "hls/1/master.m3u8", strings.NewReader("#EXTM3U"), 7, PutOptions{}))
+	require.NoError(t, s.Put(ctx, "hls/1/720p/seg_000.ts", strings.NewReader("0123456789"), -1, PutOptions{}))
+	assert.Error(t, s.Put(ctx, "hls/1/short.ts", strings.NewReader("abc"), 5, PutOptions{}))
+
+	file := filepath.Join(t.TempDir(), "src.jpg")
+	require.NoError(t, os.WriteFile(file, []byte("jpeg"), 0o600))
+	require.NoError(t, s.PutFile(ctx, "img/2/original.jpg", file, PutOptions{}))
+
+	info, err := s.Stat(ctx, "hls/1/master.m3u8")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), info.Size)
+	assert.Equal(t, "application/vnd.apple.mpegurl", info.ContentType)
+	assert.NotEmpty(t, info.ETag)
+
+	body, info, err := s.Get(ctx, "hls/1/720p/seg_000.ts", &Range{Offset: 2, Length: 3})
+	require.NoError(t, err)
+	data, _ := io.ReadAll(body)
+	body.Close()
+	assert.Equal(t, "234", string(data))
+	assert.Equal(t, int64(10), info.Size)
+
+	body, _, err = s.Get(ctx, "hls/1/720p/seg_000.ts", &Range{Offset: 7})
+	require.NoError(t, err)
+	data, _ = io.ReadAll(body)
+	body.Close()
+	assert.Equal(t, "789", string(data))
+
+	// Range: bytes=0- của player là cả object
+	body, _, err = s.Get(ctx, "hls/1/720p/seg_000.ts", &Range{})
+	require.NoError(t, err)
+	data, _ = io.ReadAll(body)
+	body.Close()
+	assert.Equal(t, "0123456789", string(data))
+
+	_, _, err = s.Get(ctx, "hls/1/missing.ts", nil)
+	assert.True(t, IsNotFound(err))
+	_, err = s.Stat(ctx, "hls/1")
+	assert.True(t, IsNotFound(err), "directory-like prefix is not an object")
+	_, err = s.Stat(ctx, "../etc/passwd")
+	assert.True(t, errors.Is(err, ErrInvalidName))
+
+	require.NoError(t, s.Copy(ctx, "img/2/original.jpg", "img/3/original.jpg"))
+	err = GetFile(ctx, s, "img/3/original.jpg", filepath.Join(t.TempDir(), "dst.jpg"))
+	require.NoError(t, err)
+
+	objects, err := s.List(ctx, "hls/1/")
+	require.NoError(t, err)
+	keys := make([]string, 0, len(objects))
+	for _, o := range objects {
+		keys = append(keys, o.Key)
+	}
+	assert.Equal(t, []string{"hls/1/720p/seg_000.ts", "hls/1/master.m3u8"}, keys)
+	objects, err = s.List(ctx, "img/")
+	require.NoError(t, err)
+	assert.Len(t, objects, 2)
+	objects, err = s.List(ctx, "nope/")
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+
+	_, err = s.Presign(ctx, http.MethodGet, "hls/1/master.m3u8", time.Minute, nil)
+	assert.True(t, errors.Is(err, ErrUnsupported))
+
+	require.NoError(t, s.Delete(ctx, "hls/1/master.m3u8", "hls/1/missing.ts"))
+	objects, err = s.List(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, objects, 3)
+
+	n, err := s.DeletePrefix(ctx, "img/")
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	_, err = s.DeletePrefix(ctx, "")
+	assert.True(t, errors.Is(err, ErrInvalidName))
+	objects, err = s.List(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, "hls/1/720p/seg_000.ts", objects[0].Key)
+}
+
+// TestMemoryPutOptions tests that object metadata set on upload is returned by Stat
+func TestMemoryPutOptions(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStorage()
+	opts := PutOptions{ContentType: "image/avif", CacheControl: "public, max-age=60", Metadata: map[string]string{"media-id": "7"}}
+	require.NoError(t, s.Put(ctx, "img/7/a.bin", strings.NewReader("x"), 1, opts))
+	info, err := s.Stat(ctx, "img/7/a.bin")
+	require.NoError(t, err)
+	assert.Equal(t, "image/avif", info.ContentType)
+	assert.Equal(t, "public, max-age=60", info.CacheControl)
+	assert.Equal(t, "7", info.Metadata["media-id"])
+}
+
+// TestBucketLifecycle tests lifecycle rules generated for bucket bootstrap
+func TestBucketLifecycle(t *testing.T) {
+	assert.Nil(t, BucketLifecycle(LifecycleOptions{TempPrefixes: []string{"uploads/"}}))
+
+	cfg := BucketLifecycle(LifecycleOptions{
+		TempPrefixes:         []string{"uploads/", "", "incoming/"},
+		TempExpiryDays:       2,
+		NoncurrentExpiryDays: 30,
+	})
+	require.NotNil(t, cfg)
+	require.Len(t, cfg.Rules, 3)
+	assert.Equal(t, "expire-uploads", cfg.Rules[0].ID)
+	assert.Equal(t, "incoming/", cfg.Rules[1].RuleFilter.Prefix)
+	assert.EqualValues(t, 2, cfg.Rules[1].Expiration.Days)
+	assert.EqualValues(t, 30, cfg.Rules[2].NoncurrentVersionExpiration.NoncurrentDays)
+}
+
+// TestMergeLifecycle tests that generated rules replace rules with the same ID and keep the others
+func TestMergeLifecycle(t *testing.T) {
+	generated := BucketLifecycle(LifecycleOptions{TempPrefixes: []string{"uploads/", "incoming/"}, TempExpiryDays: 2})
+	require.NotNil(t, generated)
+
+	merged := MergeLifecycle(nil, generated)
+	assert.Equal(t, generated.Rules, merged.Rules)
+
+	current := lifecycle.NewConfiguration()
+	current.Rules = []lifecycle.Rule{
+		{ID: "admin-logs", Status: "Enabled", RuleFilter: lifecycle.Filter{Prefix: "logs/"}, Expiration: lifecycle.Expiration{Days: 7}},
+		{ID: "expire-uploads", Status: "Enabled", RuleFilter: lifecycle.Filter{Prefix: "uploads/"}, Expiration: lifecycle.Expiration{Days: 9}},
+	}
+	merged = MergeLifecycle(current, generated)
+	require.Len(t, merged.Rules, 3)
+	assert.Equal(t, "admin-logs", merged.Rules[0].ID)
+	assert.Equal(t, "expire-uploads", merged.Rules[1].ID)
+	assert.EqualValues(t, 2, merged.Rules[1].Expiration.Days)
+	assert.Equal(t, "expire-incoming", merged.Rules[2].ID)
+	assert.EqualValues(t, 9, current.Rules[1].Expiration.Days, "current is not modified")
+}
@@ -0,0 +1,13 @@
+package types
+
+// JobDTO là trạng thái job xử lý nền trả về cho client
+type JobDTO struct {
+	ID       uint    `json:"id"`
+	Type     string  `json:"type"`
+	MediaID  uint    `json:"media_id"`
+	State    string  `json:"state"`
+	Stage    string  `json:"stage,omitempty"`
+	Progress float64 `json:"progress"` // 0-100
+	Attempts int     `json:"attempts"`
+	Error    string  `json:"error,omitempty"`
+}
@@ -7,9 +7,85 @@ const (
 	MediaTypeImage MediaType = "image"
 )
 
+// MediaStatus là vòng đời xử lý của media
+type MediaStatus string
+
+const (
+	MediaStatusPending    MediaStatus = "pending"
+	MediaStatusProcessing MediaStatus = "processing"
+	MediaStatusReady      MediaStatus = "ready"
+	MediaStatusFailed     MediaStatus = "failed"
+)
+
 type MediaDTO struct {
-	ID   uint      `json:"id"`
-	Type MediaType `json:"type"`
-	URL  string    `json:"url"`
-	// TODO: Thêm các trường metadata (duration, quality, ...)
+	ID            uint           `json:"id"`
+	Type          MediaType      `json:"type"`
+	Status        MediaStatus    `json:"status"`
+	FailureReason string         `json:"failure_reason,omitempty"`
+	URL           string         `json:"url"` // URL phát/xem được, rỗng khi chưa ready
+	Size          int64          `json:"size"`
+	Tags          []string       `json:"tags"`
+	Metadata      *MediaMetadata `json:"metadata,omitempty"`
+	BlurHash      string         `json:"blurhash,omitempty"` // placeholder hiển thị trước khi thumbnail tải xong
+	Colors        []string       `json:"colors,omitempty"`   // màu chủ đạo #rrggbb, nhiều nhất trước
+	Thumbnails    []ThumbnailDTO `json:"thumbnails"`
+	Renditions    []RenditionDTO `json:"renditions"`
+	PreviewTrack  string         `json:"preview_track,omitempty"` // WebVTT trỏ tới sprite cho preview trên thanh seek
+	DeletedAt     int64          `json:"deleted_at,omitempty"`    // chỉ có khi media nằm trong thùng rác
+	PurgeAt       int64          `json:"purge_at,omitempty"`      // thời điểm bị xóa vĩnh viễn, restore được tới lúc này
+	CreatedAt     int64          `json:"created_at"`
+	UpdatedAt     int64          `json:"updated_at"`
+}
+
+// MediaListDTO là một trang của danh sách media
+type MediaListDTO struct {
+	Items      []MediaDTO `json:"items"`
+	Total      int64      `json:"total"`          // tổng số media khớp bộ lọc
+	Page       int        `json:"page,omitempty"` // chỉ có khi phân trang theo page
+	Size       int        `json:"size"`
+	NextCursor string     `json:"next_cursor,omitempty"` // rỗng khi đã hết
+}
+
+// ThumbnailDTO là một ảnh xem trước của media
+type ThumbnailDTO struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// RenditionDTO là một luồng HLS của video
+type RenditionDTO struct {
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Bandwidth int64  `json:"bandwidth"` // bit/s
+	Codecs    string `json:"codecs,omitempty"`
+}
+
+// MediaMetadata là metadata kỹ thuật của file gốc
+type MediaMetadata struct {
+	Container     string  `json:"container,omitempty"`
+	Duration      float64 `json:"duration,omitempty"` // giây
+	Width         int     `json:"width,omitempty"`
+	Height        int     `json:"height,omitempty"`
+	FrameRate     float64 `json:"frame_rate,omitempty"`
+	VideoCodec    string  `json:"video_codec,omitempty"`
+	AudioCodec    string  `json:"audio_codec,omitempty"`
+	Bitrate       int64   `json:"bitrate,omitempty"` // bit/s
+	AudioChannels int     `json:"audio_channels,omitempty"`
+	Rotation      int     `json:"rotation,omitempty"`
+
+	CameraMake   string   `json:"camera_make,omitempty"`
+	CameraModel  string   `json:"camera_model,omitempty"`
+	LensModel    string   `json:"lens_model,omitempty"`
+	ExposureTime string   `json:"exposure_time,omitempty"`
+	FNumber      float64  `json:"f_number,omitempty"`
+	ISO          int      `json:"iso,omitempty"`
+	FocalLength  float64  `json:"focal_length,omitempty"` // mm
+	CapturedAt   int64    `json:"captured_at,omitempty"`
+	Latitude     *float64 `json:"latitude,omitempty"` // chỉ có khi IMAGE_METADATA_POLICY=keep_all
+	Longitude    *float64 `json:"longitude,omitempty"`
+	Altitude     *float64 `json:"altitude,omitempty"`
 }
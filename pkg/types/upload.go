@@ -0,0 +1,36 @@
+package types
+
+// PresignUploadRequest xin URL để client upload thẳng lên MinIO
+type PresignUploadRequest struct {
+	Filename string   `json:"filename"`
+	Size     int64    `json:"size"`
+	SHA256   string   `json:"sha256,omitempty"` // hex, có thì được kiểm khi complete
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// PresignUploadDTO là hướng dẫn upload: một PUT tới URL, hoặc PUT từng part khi file lớn
+type PresignUploadDTO struct {
+	ID        string            `json:"id"`
+	URL       string            `json:"url,omitempty"`     // một PUT cho cả file
+	Headers   map[string]string `json:"headers,omitempty"` // header đã ký, client phải gửi kèm PUT
+	Parts     []PresignPartDTO  `json:"parts,omitempty"`   // multipart: part i gồm PartSize byte, part cuối có thể ngắn hơn
+	PartSize  int64             `json:"part_size,omitempty"`
+	ExpiresAt int64             `json:"expires_at"`
+}
+
+// PresignPartDTO là URL PUT cho một part
+type PresignPartDTO struct {
+	PartNumber int    `json:"part_number"`
+	URL        string `json:"url"`
+}
+
+// CompleteUploadRequest báo upload đã xong, multipart cần ETag của từng part từ response PUT
+type CompleteUploadRequest struct {
+	Parts []CompletedPartDTO `json:"parts,omitempty"`
+}
+
+// CompletedPartDTO là một part đã upload
+type CompletedPartDTO struct {
+	PartNumber int    `json:"part_number"`
+	ETag       string `json:"etag"`
+}